@@ -0,0 +1,233 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kurtosis-tech/stacktrace"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// Posts scoring below this fail the accessibility check (0-100; unset means the score never fails it)
+	MinAccessibilityScoreEnvVar = "MIN_ACCESSIBILITY_SCORE"
+
+	// Relative to the repo's .git directory
+	accessibilityReportsDirpath = "opwriting/accessibility"
+
+	// Points taken off a perfect score of 100 for each accessibility issue
+	accessibilityErrorPenalty   = 15
+	accessibilityWarningPenalty = 5
+)
+
+// AccessibilityReport is a post's accessibility score and the issues behind it, as of the last time it was checked
+type AccessibilityReport struct {
+	Post   string      `json:"post"`
+	Score  int         `json:"score"`
+	Issues []LintIssue `json:"issues"`
+
+	// Hash of the post file that was checked, so that the report can be recognized as stale
+	PostHash  string    `json:"post_hash"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+var a11yCmd = &cobra.Command{
+	Use:     "a11y [post_dir...]",
+	Aliases: []string{"accessibility"},
+	Short:   "Score posts' accessibility",
+	Long: `Check posts for accessibility problems: images without alt text or whose alt text is just the
+file name, link text like 'click here', skipped heading levels and extra top-level headings, and tables
+without headers. Each post gets a score out of 100, which is saved so that 'opwriting publish' can warn
+about it. The rules are lint rules, so ` + LintRulesEnvVar + ` changes their severity in the same way.
+Picks posts the same way 'opwriting lint' does, and exits non-zero if a post scores below
+` + MinAccessibilityScoreEnvVar + `, if set.`,
+	RunE: runA11yCommand,
+}
+
+func runA11yCommand(cmd *cobra.Command, args []string) error {
+	writingRepoPath := os.Getenv(WritingDirEnvVar)
+	if writingRepoPath == "" {
+		return stacktrace.NewError("writing directory not configured: %s environment variable not set", WritingDirEnvVar)
+	}
+
+	severities, err := getLintSeverities()
+	if err != nil {
+		return err
+	}
+	minScore, err := getMinAccessibilityScore()
+	if err != nil {
+		return err
+	}
+
+	postDirs, err := resolvePostDirs(writingRepoPath, args)
+	if err != nil {
+		return err
+	}
+	if len(postDirs) == 0 {
+		fmt.Println("No posts to check")
+		return nil
+	}
+
+	failureCount := 0
+	for _, postDir := range postDirs {
+		report, err := checkPostAccessibility(writingRepoPath, postDir, severities)
+		if err != nil {
+			return stacktrace.Propagate(err, "failed to check the accessibility of post '%s'", postDir)
+		}
+
+		emoji := "✅"
+		if report.Score < minScore {
+			emoji = "❌"
+			failureCount++
+		} else if len(report.Issues) > 0 {
+			emoji = "⚠️ "
+		}
+		fmt.Printf("%s %s: %d/100\n", emoji, postDir, report.Score)
+		for _, issue := range report.Issues {
+			fmt.Printf("     %s:%d: %s: %s\n", PostFilename, issue.Line, issue.Rule, issue.Message)
+		}
+	}
+
+	if failureCount > 0 {
+		return stacktrace.NewError("%d post(s) scored below the minimum of %d set with %s", failureCount, minScore, MinAccessibilityScoreEnvVar)
+	}
+	return nil
+}
+
+func isAccessibilityRule(ruleID string) bool {
+	for _, rule := range lintRules {
+		if rule.ID == ruleID {
+			return rule.Accessibility
+		}
+	}
+	return false
+}
+
+func getMinAccessibilityScore() (int, error) {
+	value := getConfigValue(MinAccessibilityScoreEnvVar)
+	if value == "" {
+		return 0, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 || parsed > 100 {
+		return 0, stacktrace.NewError("%s must be a number from 0 to 100, but was '%s'", MinAccessibilityScoreEnvVar, value)
+	}
+	return parsed, nil
+}
+
+// checkPostAccessibility runs the accessibility rules against the post, scores it, and saves the report
+func checkPostAccessibility(writingRepoPath string, postDir string, severities map[string]string) (*AccessibilityReport, error) {
+	issues, err := lintPost(writingRepoPath, postDir, severities)
+	if err != nil {
+		return nil, err
+	}
+	report := &AccessibilityReport{
+		Post:      postDir,
+		Score:     100,
+		Issues:    []LintIssue{},
+		CheckedAt: time.Now(),
+	}
+	for _, issue := range issues {
+		if !isAccessibilityRule(issue.Rule) {
+			continue
+		}
+		report.Issues = append(report.Issues, issue)
+		if issue.Severity == LintSeverityError {
+			report.Score -= accessibilityErrorPenalty
+		} else {
+			report.Score -= accessibilityWarningPenalty
+		}
+	}
+	report.Score = max(report.Score, 0)
+
+	if report.PostHash, err = hashPostFile(writingRepoPath, postDir); err != nil {
+		return nil, err
+	}
+	if err := saveAccessibilityReport(writingRepoPath, report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// getAccessibilityReport returns the post's saved report, checking the post again if it's changed since
+func getAccessibilityReport(writingRepoPath string, postDir string) (*AccessibilityReport, error) {
+	reportFilepath, err := getAccessibilityReportFilepath(writingRepoPath, postDir)
+	if err != nil {
+		return nil, err
+	}
+	postHash, err := hashPostFile(writingRepoPath, postDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if reportBytes, err := os.ReadFile(reportFilepath); err == nil {
+		report := &AccessibilityReport{}
+		if err := json.Unmarshal(reportBytes, report); err == nil && report.PostHash == postHash {
+			return report, nil
+		}
+	}
+
+	severities, err := getLintSeverities()
+	if err != nil {
+		return nil, err
+	}
+	return checkPostAccessibility(writingRepoPath, postDir, severities)
+}
+
+// warnAboutAccessibility prints a warning if the post's accessibility isn't perfect, without stopping the publish
+func warnAboutAccessibility(postDir string) {
+	report, err := getAccessibilityReport(os.Getenv(WritingDirEnvVar), postDir)
+	if err != nil {
+		fmt.Printf("⚠️  Couldn't check the post's accessibility: %v\n", err)
+		return
+	}
+	if report.Score < 100 {
+		fmt.Printf("⚠️  Accessibility score is %d/100 with %d issue(s); run 'opwriting a11y %s' to see them\n", report.Score, len(report.Issues), postDir)
+	}
+}
+
+func saveAccessibilityReport(writingRepoPath string, report *AccessibilityReport) error {
+	reportFilepath, err := getAccessibilityReportFilepath(writingRepoPath, report.Post)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(reportFilepath), 0755); err != nil {
+		return stacktrace.Propagate(err, "failed to create accessibility reports directory")
+	}
+	reportBytes, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return stacktrace.Propagate(err, "failed to serialize accessibility report")
+	}
+	if err := os.WriteFile(reportFilepath, reportBytes, 0644); err != nil {
+		return stacktrace.Propagate(err, "failed to write accessibility report: %s", reportFilepath)
+	}
+	return nil
+}
+
+// getAccessibilityReportFilepath returns where the post's report is kept, inside the repo's .git directory
+func getAccessibilityReportFilepath(writingRepoPath string, postDir string) (string, error) {
+	gitDirpath, err := getRepoGitCommonDirpath(writingRepoPath)
+	if err != nil {
+		return "", err
+	}
+	// Nested post directories are flattened into the file name
+	reportFilename := strings.ReplaceAll(filepath.ToSlash(filepath.Clean(postDir)), "/", "_") + ".json"
+	return filepath.Join(gitDirpath, accessibilityReportsDirpath, reportFilename), nil
+}
+
+func hashPostFile(writingRepoPath string, postDir string) (string, error) {
+	postFilepath := filepath.Join(writingRepoPath, postDir, PostFilename)
+	content, err := os.ReadFile(postFilepath)
+	if err != nil {
+		return "", stacktrace.Propagate(err, "failed to read post: %s", postFilepath)
+	}
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:]), nil
+}
@@ -0,0 +1,514 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/kurtosis-tech/stacktrace"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// Average adult silent reading speed
+	readingWordsPerMinute = 238
+
+	// Sentences at or above these Flesch-Kincaid grades are flagged as hard to read, as long as they're long enough
+	// for the grade to mean something
+	hardSentenceGrade     = 10
+	veryHardSentenceGrade = 14
+	hardSentenceMinWords  = 14
+
+	// How many overused words and repeated phrases to show
+	analysisListLimit = 10
+
+	// Words used more often than this (per word of the post) are overused, as long as they're used at least
+	// overusedWordMinCount times
+	overusedWordFrequency = 0.005
+	overusedWordMinCount  = 3
+
+	// Lengths of the phrases checked for repeats, in words
+	repeatedPhraseMinWords = 3
+	repeatedPhraseMaxWords = 6
+
+	sentenceIssueHard         = "hard"
+	sentenceIssueVeryHard     = "very-hard"
+	sentenceIssuePassiveVoice = "passive-voice"
+	sentenceIssueAdverb       = "adverb"
+	sentenceIssueHedgeWord    = "hedge-word"
+)
+
+// Words that qualify what they're attached to without adding anything, weakening the writing
+var hedgeWords = []string{
+	"just", "really", "very", "quite", "rather", "somewhat", "perhaps", "maybe", "basically", "actually",
+	"literally", "honestly", "simply", "probably", "possibly", "fairly", "arguably", "pretty much", "kind of",
+	"sort of", "a bit", "a little", "I think", "I guess", "I feel like", "seems", "seemed", "seem to",
+}
+
+// Words ending in 'ly' that aren't adverbs
+var nonAdverbs = map[string]bool{
+	"only": true, "family": true, "early": true, "reply": true, "apply": true, "supply": true, "imply": true,
+	"rely": true, "comply": true, "multiply": true, "ally": true, "rally": true, "belly": true, "bully": true,
+	"jelly": true, "holy": true, "ugly": true, "lonely": true, "friendly": true, "lovely": true, "likely": true,
+	"unlikely": true, "daily": true, "weekly": true, "monthly": true, "yearly": true, "hourly": true, "silly": true,
+	"july": true, "assembly": true, "anomaly": true, "butterfly": true, "elderly": true, "costly": true, "curly": true,
+	"deadly": true, "jolly": true, "lively": true, "lowly": true, "orderly": true, "scholarly": true, "sickly": true,
+	"timely": true, "chilly": true, "hilly": true, "folly": true, "melancholy": true, "bubbly": true, "wobbly": true,
+	"homily": true, "italy": true, "fly": true, "sly": true, "ply": true, "doily": true, "gully": true, "lily": true,
+	"manly": true, "cowardly": true, "worldly": true, "heavenly": true, "ghastly": true, "smelly": true, "woolly": true,
+	"burly": true, "surly": true, "bodily": true, "comely": true, "homely": true, "kindly": true, "shapely": true,
+	"stately": true, "motherly": true, "fatherly": true, "brotherly": true, "sisterly": true, "neighborly": true,
+}
+
+// Words too common to count as overused, or as making a phrase worth reporting
+var analysisStopWords = map[string]bool{}
+
+// Past participles that don't end in 'ed', for finding the passive voice
+var irregularParticiples = []string{
+	"arisen", "awoken", "borne", "beaten", "become", "begun", "bent", "bitten", "bled", "blown", "broken", "bred",
+	"brought", "built", "burnt", "bought", "caught", "chosen", "clung", "crept", "dealt", "dug", "done", "drawn",
+	"driven", "drunk", "eaten", "fallen", "fed", "felt", "fought", "found", "fled", "flung", "forbidden",
+	"forgotten", "forgiven", "frozen", "given", "ground", "grown", "hung", "heard", "hidden", "held", "hurt",
+	"kept", "known", "laid", "led", "left", "lent", "lit", "lost", "made", "meant", "met", "paid", "proven", "read",
+	"ridden", "rung", "said", "seen", "sought", "sold", "sent", "sewn", "shaken", "shed", "shot", "shown",
+	"shrunk", "shut", "sung", "sunk", "slain", "slung", "spoken", "spent", "spun", "split", "spread", "stolen",
+	"stuck", "stung", "struck", "strung", "sworn", "swept", "taken", "taught", "torn", "told", "thought", "thrown",
+	"thrust", "understood", "undone", "woken", "worn", "woven", "won", "wound", "withdrawn", "written",
+}
+
+// Words ending in 'ed' that aren't past participles
+var nonParticiples = map[string]bool{
+	"need": true, "indeed": true, "seed": true, "feed": true, "speed": true, "bed": true, "red": true, "hundred": true,
+	"naked": true, "wicked": true, "sacred": true, "rugged": true, "beloved": true, "ragged": true, "crooked": true,
+	"wretched": true, "kindred": true, "shred": true, "sled": true, "bred": true, "breed": true, "greed": true,
+	"weed": true, "deed": true, "creed": true, "embed": true,
+}
+
+var (
+	hedgeWordRegex    *regexp.Regexp
+	passiveVoiceRegex = regexp.MustCompile(`(?i)\b(?:am|is|are|was|were|be|been|being|get|gets|got|gotten|getting)(?:\s+\w+ly)?\s+(\w+ed|` + strings.Join(irregularParticiples, "|") + `)\b`)
+)
+
+func init() {
+	hedgeAlternatives := make([]string, len(hedgeWords))
+	for idx, hedgeWord := range hedgeWords {
+		hedgeAlternatives[idx] = regexp.QuoteMeta(hedgeWord)
+	}
+	// Longest first, so that 'a little' is found rather than a word inside it
+	sort.Slice(hedgeAlternatives, func(i, j int) bool { return len(hedgeAlternatives[i]) > len(hedgeAlternatives[j]) })
+	hedgeWordRegex = regexp.MustCompile(`(?i)\b(?:` + strings.Join(hedgeAlternatives, "|") + `)\b`)
+
+	for _, word := range strings.Fields(`a about above after again against all also am an and any are as at be because
+		been before being below between both but by can could did do does doing down during each few for from further had
+		has have having he her here hers herself him himself his how i if in into is it its itself just me more most my
+		myself no nor not now of off on once only or other our ours ourselves out over own same she should so some such
+		than that the their theirs them themselves then there these they this those through to too under until up us very
+		was we were what when where which while who whom why will with would you your yours yourself yourselves it's i'm
+		don't can't that's there's i've i'd you're they're we're isn't wasn't didn't doesn't won't`) {
+		analysisStopWords[word] = true
+	}
+}
+
+// PostAnalysis is a post's readability and style report
+type PostAnalysis struct {
+	Post               string `json:"post"`
+	Words              int    `json:"words"`
+	Sentences          int    `json:"sentences"`
+	Paragraphs         int    `json:"paragraphs"`
+	ReadingTimeMinutes int    `json:"reading_time_minutes"`
+
+	AverageSentenceLength float64           `json:"average_sentence_length"`
+	Readability           ReadabilityScores `json:"readability"`
+
+	HardSentences     int `json:"hard_sentences"`
+	VeryHardSentences int `json:"very_hard_sentences"`
+	PassiveVoice      int `json:"passive_voice"`
+	Adverbs           int `json:"adverbs"`
+
+	// Adverbs as a percentage of the post's words
+	AdverbDensity float64 `json:"adverb_density"`
+
+	HedgeWords      []PhraseCount `json:"hedge_words"`
+	OverusedWords   []PhraseCount `json:"overused_words"`
+	RepeatedPhrases []PhraseCount `json:"repeated_phrases"`
+
+	// Only the sentences with something to fix
+	FlaggedSentences []AnalyzedSentence `json:"flagged_sentences"`
+}
+
+// PhraseCount is how many times a word or phrase appears in a post
+type PhraseCount struct {
+	Phrase string `json:"phrase"`
+	Count  int    `json:"count"`
+}
+
+// AnalyzedSentence is a sentence with the problems found in it
+type AnalyzedSentence struct {
+	Line       int                 `json:"line"`
+	Text       string              `json:"text"`
+	Grade      float64             `json:"grade"`
+	Issues     []string            `json:"issues"`
+	Highlights []SentenceHighlight `json:"highlights"`
+}
+
+// SentenceHighlight is the part of a sentence that one of its problems is about
+type SentenceHighlight struct {
+	Issue string `json:"issue"`
+	Text  string `json:"text"`
+
+	// Byte offsets in the sentence's text
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+var analyzeFormat string
+
+var analyzeCmd = &cobra.Command{
+	Use:   "analyze [post_dir...]",
+	Short: "Report on posts' readability and style",
+	Long: `Report on the readability and style of posts' prose, skipping code, HTML, tables and the front matter:
+word count and reading time, Flesch reading ease, Flesch-Kincaid grade and other readability scores,
+average sentence length, sentences that are hard to read or use the passive voice, adverbs, hedge words
+like 'just' and 'really', overused words, and repeated phrases. Sentences worth another look are shown
+with their problems marked «like this». Picks posts the same way 'opwriting lint' does.`,
+	RunE: runAnalyzeCommand,
+}
+
+func init() {
+	analyzeCmd.Flags().StringVar(&analyzeFormat, "format", lintFormatHuman, "Output format: 'human' or 'json'")
+}
+
+func runAnalyzeCommand(cmd *cobra.Command, args []string) error {
+	if analyzeFormat != lintFormatHuman && analyzeFormat != lintFormatJSON {
+		return stacktrace.NewError("unrecognized format '%s'; use '%s' or '%s'", analyzeFormat, lintFormatHuman, lintFormatJSON)
+	}
+
+	writingRepoPath := os.Getenv(WritingDirEnvVar)
+	if writingRepoPath == "" {
+		return stacktrace.NewError("writing directory not configured: %s environment variable not set", WritingDirEnvVar)
+	}
+	absWritingRepoPath, err := filepath.Abs(writingRepoPath)
+	if err != nil {
+		return stacktrace.Propagate(err, "failed to get absolute path for writing directory")
+	}
+
+	// Tells adverbs starting a sentence from names
+	dictionary, err := loadSpellingDictionary(absWritingRepoPath)
+	if err != nil {
+		return err
+	}
+	postDirs, err := resolvePostDirs(absWritingRepoPath, args)
+	if err != nil {
+		return err
+	}
+
+	analyses := []*PostAnalysis{}
+	for _, postDir := range postDirs {
+		postFilepath := filepath.Join(absWritingRepoPath, postDir, PostFilename)
+		content, err := os.ReadFile(postFilepath)
+		if err != nil {
+			return stacktrace.Propagate(err, "failed to read post: %s", postFilepath)
+		}
+		analysis := analyzePost(strings.ReplaceAll(string(content), "\r\n", "\n"), dictionary)
+		analysis.Post = postDir
+		analyses = append(analyses, analysis)
+	}
+
+	if analyzeFormat == lintFormatJSON {
+		encoded, err := json.MarshalIndent(analyses, "", "  ")
+		if err != nil {
+			return stacktrace.Propagate(err, "failed to encode analyses")
+		}
+		fmt.Println(string(encoded))
+		return nil
+	}
+
+	if len(analyses) == 0 {
+		fmt.Println("No posts to analyze")
+	}
+	for idx, analysis := range analyses {
+		if idx > 0 {
+			fmt.Println()
+		}
+		printPostAnalysis(analysis)
+	}
+	return nil
+}
+
+// analyzePost analyzes a post's prose, given the post's contents with normalized line endings
+func analyzePost(content string, dictionary *Dictionary) *PostAnalysis {
+	analysis := &PostAnalysis{
+		HedgeWords:       []PhraseCount{},
+		OverusedWords:    []PhraseCount{},
+		RepeatedPhrases:  []PhraseCount{},
+		FlaggedSentences: []AnalyzedSentence{},
+	}
+
+	sentenceWords, syllables, letters, polysyllables := 0, 0, 0, 0
+	wordCounts := map[string]int{}
+	phraseCounts := map[string]int{}
+	hedgeCounts := map[string]int{}
+	for _, block := range extractProse(content) {
+		if block.heading {
+			// Headings are read, but they aren't sentences
+			analysis.Words += len(spellWordRegex.FindAllString(block.text, -1))
+			continue
+		}
+		analysis.Paragraphs++
+
+		for _, span := range splitSentences(block.text) {
+			sentenceText := block.text[span.start:span.end]
+			words := spellWordRegex.FindAllString(sentenceText, -1)
+			analysis.Words += len(words)
+			analysis.Sentences++
+			sentenceWords += len(words)
+
+			sentenceSyllables := 0
+			normalizedWords := make([]string, len(words))
+			for idx, word := range words {
+				wordSyllables := countSyllables(word)
+				sentenceSyllables += wordSyllables
+				if wordSyllables >= 3 {
+					polysyllables++
+				}
+				letters += len([]rune(word))
+
+				normalizedWords[idx] = strings.ToLower(strings.ReplaceAll(word, "’", "'"))
+				if !analysisStopWords[normalizedWords[idx]] && len([]rune(word)) > 2 && strings.IndexFunc(word, unicode.IsDigit) < 0 {
+					wordCounts[normalizedWords[idx]]++
+				}
+			}
+			syllables += sentenceSyllables
+			countPhrases(normalizedWords, phraseCounts)
+
+			sentence := analyzeSentence(sentenceText, len(words), sentenceSyllables, dictionary)
+			sentence.Line = strings.Count(content[:block.fileOffset(span.start)], "\n") + 1
+			for _, highlight := range sentence.Highlights {
+				switch highlight.Issue {
+				case sentenceIssuePassiveVoice:
+					analysis.PassiveVoice++
+				case sentenceIssueAdverb:
+					analysis.Adverbs++
+				case sentenceIssueHedgeWord:
+					hedgeCounts[strings.ToLower(highlight.Text)]++
+				}
+			}
+			if containsString(sentence.Issues, sentenceIssueVeryHard) {
+				analysis.VeryHardSentences++
+			} else if containsString(sentence.Issues, sentenceIssueHard) {
+				analysis.HardSentences++
+			}
+			if len(sentence.Issues) > 0 {
+				analysis.FlaggedSentences = append(analysis.FlaggedSentences, sentence)
+			}
+		}
+	}
+
+	analysis.ReadingTimeMinutes = int(math.Ceil(float64(analysis.Words) / readingWordsPerMinute))
+	if analysis.Sentences > 0 {
+		analysis.AverageSentenceLength = roundScore(float64(sentenceWords) / float64(analysis.Sentences))
+	}
+	if analysis.Words > 0 {
+		analysis.AdverbDensity = roundScore(100 * float64(analysis.Adverbs) / float64(analysis.Words))
+	}
+	analysis.Readability = computeReadability(sentenceWords, analysis.Sentences, syllables, letters, polysyllables)
+
+	analysis.HedgeWords = sortPhraseCounts(hedgeCounts, 1, len(hedgeWords))
+	minOverusedCount := max(overusedWordMinCount, int(math.Ceil(overusedWordFrequency*float64(analysis.Words))))
+	analysis.OverusedWords = sortPhraseCounts(wordCounts, minOverusedCount, analysisListLimit)
+	analysis.RepeatedPhrases = sortPhraseCounts(dropContainedPhrases(phraseCounts), 2, analysisListLimit)
+	return analysis
+}
+
+// analyzeSentence grades a sentence and finds its passive voice, adverbs and hedge words
+func analyzeSentence(sentenceText string, wordCount int, syllables int, dictionary *Dictionary) AnalyzedSentence {
+	sentence := AnalyzedSentence{
+		Text:       sentenceText,
+		Grade:      roundScore(fleschKincaidGrade(float64(wordCount), float64(syllables)/float64(max(wordCount, 1)))),
+		Issues:     []string{},
+		Highlights: []SentenceHighlight{},
+	}
+	if wordCount >= hardSentenceMinWords {
+		if sentence.Grade >= veryHardSentenceGrade {
+			sentence.Issues = append(sentence.Issues, sentenceIssueVeryHard)
+		} else if sentence.Grade >= hardSentenceGrade {
+			sentence.Issues = append(sentence.Issues, sentenceIssueHard)
+		}
+	}
+
+	addHighlight := func(issue string, start int, end int) {
+		// Each part of the sentence is only about one thing, the first one found
+		for _, existing := range sentence.Highlights {
+			if start < existing.End && existing.Start < end {
+				return
+			}
+		}
+		sentence.Highlights = append(sentence.Highlights, SentenceHighlight{Issue: issue, Text: sentenceText[start:end], Start: start, End: end})
+		if !containsString(sentence.Issues, issue) {
+			sentence.Issues = append(sentence.Issues, issue)
+		}
+	}
+
+	for _, match := range passiveVoiceRegex.FindAllStringSubmatchIndex(sentenceText, -1) {
+		if !nonParticiples[strings.ToLower(sentenceText[match[2]:match[3]])] {
+			addHighlight(sentenceIssuePassiveVoice, match[0], match[1])
+		}
+	}
+	for _, match := range hedgeWordRegex.FindAllStringIndex(sentenceText, -1) {
+		addHighlight(sentenceIssueHedgeWord, match[0], match[1])
+	}
+	for idx, match := range spellWordRegex.FindAllStringIndex(sentenceText, -1) {
+		if isAdverb(sentenceText[match[0]:match[1]], idx == 0, dictionary) {
+			addHighlight(sentenceIssueAdverb, match[0], match[1])
+		}
+	}
+
+	sort.Slice(sentence.Highlights, func(i, j int) bool { return sentence.Highlights[i].Start < sentence.Highlights[j].Start })
+	return sentence
+}
+
+// isAdverb guesses whether a word is an adverb from its 'ly' ending. Capitalized words are names, unless they
+// start the sentence and the dictionary knows them in lowercase.
+func isAdverb(word string, startsSentence bool, dictionary *Dictionary) bool {
+	lower := strings.ToLower(word)
+	if !strings.HasSuffix(lower, "ly") || len([]rune(word)) < 5 || nonAdverbs[lower] {
+		return false
+	}
+	if !startsWithUpper(word) {
+		return true
+	}
+	return startsSentence && dictionary.Check(lower)
+}
+
+// countPhrases counts every run of words in a sentence that's long enough to be a repeated phrase, skipping ones
+// made only of stop words
+func countPhrases(words []string, phraseCounts map[string]int) {
+	for length := repeatedPhraseMinWords; length <= repeatedPhraseMaxWords; length++ {
+		for start := 0; start+length <= len(words); start++ {
+			phraseWords := words[start : start+length]
+			for _, word := range phraseWords {
+				if !analysisStopWords[word] {
+					phraseCounts[strings.Join(phraseWords, " ")]++
+					break
+				}
+			}
+		}
+	}
+}
+
+// dropContainedPhrases leaves out phrases that only appear as part of a longer repeated phrase
+func dropContainedPhrases(phraseCounts map[string]int) map[string]int {
+	kept := map[string]int{}
+	for phrase, count := range phraseCounts {
+		if count < 2 {
+			continue
+		}
+		contained := false
+		for other, otherCount := range phraseCounts {
+			if otherCount == count && len(other) > len(phrase) && strings.Contains(" "+other+" ", " "+phrase+" ") {
+				contained = true
+				break
+			}
+		}
+		if !contained {
+			kept[phrase] = count
+		}
+	}
+	return kept
+}
+
+// sortPhraseCounts returns the phrases appearing at least minCount times, most common (then longest) first
+func sortPhraseCounts(counts map[string]int, minCount int, limit int) []PhraseCount {
+	sorted := []PhraseCount{}
+	for phrase, count := range counts {
+		if count >= minCount {
+			sorted = append(sorted, PhraseCount{Phrase: phrase, Count: count})
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Count != sorted[j].Count {
+			return sorted[i].Count > sorted[j].Count
+		}
+		if len(sorted[i].Phrase) != len(sorted[j].Phrase) {
+			return len(sorted[i].Phrase) > len(sorted[j].Phrase)
+		}
+		return sorted[i].Phrase < sorted[j].Phrase
+	})
+	if len(sorted) > limit {
+		sorted = sorted[:limit]
+	}
+	return sorted
+}
+
+func printPostAnalysis(analysis *PostAnalysis) {
+	fmt.Printf("📊 %s\n", filepath.Join(analysis.Post, PostFilename))
+	if analysis.Words == 0 {
+		fmt.Println("   No prose to analyze")
+		return
+	}
+	fmt.Printf("   %d words, %d min read, %d sentences, %d paragraphs\n", analysis.Words, analysis.ReadingTimeMinutes, analysis.Sentences, analysis.Paragraphs)
+	fmt.Printf("   Average sentence length: %.1f words\n", analysis.AverageSentenceLength)
+
+	readability := analysis.Readability
+	fmt.Println()
+	fmt.Println("   Readability")
+	fmt.Printf("     Flesch reading ease          %5.1f (%s)\n", readability.FleschReadingEase, describeReadingEase(readability.FleschReadingEase))
+	fmt.Printf("     Flesch-Kincaid grade         %5.1f\n", readability.FleschKincaidGrade)
+	fmt.Printf("     Gunning fog                  %5.1f\n", readability.GunningFog)
+	fmt.Printf("     SMOG                         %5.1f\n", readability.SMOG)
+	fmt.Printf("     Coleman-Liau                 %5.1f\n", readability.ColemanLiau)
+	fmt.Printf("     Automated readability index  %5.1f\n", readability.AutomatedReadabilityIndex)
+
+	fmt.Println()
+	fmt.Println("   Style")
+	fmt.Printf("     Hard to read:      %d sentence(s), %d very hard\n", analysis.HardSentences+analysis.VeryHardSentences, analysis.VeryHardSentences)
+	fmt.Printf("     Passive voice:     %d\n", analysis.PassiveVoice)
+	fmt.Printf("     Adverbs:           %d (%.1f%% of words)\n", analysis.Adverbs, analysis.AdverbDensity)
+	fmt.Printf("     Hedge words:       %s\n", formatPhraseCounts(analysis.HedgeWords))
+	fmt.Printf("     Overused words:    %s\n", formatPhraseCounts(analysis.OverusedWords))
+	fmt.Printf("     Repeated phrases:  %s\n", formatPhraseCounts(analysis.RepeatedPhrases))
+
+	if len(analysis.FlaggedSentences) == 0 {
+		return
+	}
+	fmt.Println()
+	fmt.Println("   Sentences to look at")
+	for _, sentence := range analysis.FlaggedSentences {
+		fmt.Printf("     %4d  %s\n", sentence.Line, strings.Join(sentence.Issues, ", "))
+		fmt.Printf("           %s\n", highlightSentence(sentence))
+	}
+}
+
+func formatPhraseCounts(phraseCounts []PhraseCount) string {
+	if len(phraseCounts) == 0 {
+		return "none"
+	}
+	formatted := make([]string, len(phraseCounts))
+	for idx, phraseCount := range phraseCounts {
+		formatted[idx] = fmt.Sprintf("'%s' (%d)", phraseCount.Phrase, phraseCount.Count)
+	}
+	return strings.Join(formatted, ", ")
+}
+
+// highlightSentence marks the highlighted parts of a sentence «like this», or the whole sentence if it's only
+// hard to read
+func highlightSentence(sentence AnalyzedSentence) string {
+	if len(sentence.Highlights) == 0 {
+		return "«" + sentence.Text + "»"
+	}
+	var highlighted strings.Builder
+	previousEnd := 0
+	for _, highlight := range sentence.Highlights {
+		highlighted.WriteString(sentence.Text[previousEnd:highlight.Start])
+		highlighted.WriteString("«" + highlight.Text + "»")
+		previousEnd = highlight.End
+	}
+	highlighted.WriteString(sentence.Text[previousEnd:])
+	return highlighted.String()
+}
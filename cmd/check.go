@@ -0,0 +1,376 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kurtosis-tech/stacktrace"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// Word count bounds for a post's body; unset means unbounded
+	MinWordCountEnvVar = "MIN_WORD_COUNT"
+	MaxWordCountEnvVar = "MAX_WORD_COUNT"
+
+	// Comma-separated front matter fields that every post must fill in; unset means none are required
+	RequiredFrontMatterEnvVar = "REQUIRED_FRONT_MATTER"
+
+	// Comma-separated names of local checks to skip (e.g. 'spelling,word-count')
+	SkipChecksEnvVar = "SKIP_CHECKS"
+)
+
+// LocalCheck is a check run against a post on the user's machine, before it goes anywhere near the forge
+type LocalCheck struct {
+	Name string
+	run  func(ctx *localCheckContext, postDir string) (LocalCheckResult, error)
+}
+
+// LocalCheckResult is the outcome of running a local check against a post
+type LocalCheckResult struct {
+	State   CheckStateEnum
+	Summary string
+
+	// One line per problem found, e.g. 'post.md:12: ...'
+	Problems []string
+}
+
+// localCheckContext holds the configuration shared by every local check, loaded once per run
+type localCheckContext struct {
+	writingRepoPath     string
+	lintSeverities      map[string]string
+	minWordCount        int
+	maxWordCount        int
+	requiredFrontMatter []string
+	minAccessibility    int
+
+	// Loaded by the spelling check the first time it runs
+	dictionary *Dictionary
+}
+
+var localChecks = []LocalCheck{
+	{Name: "lint", run: runLintCheck},
+	{Name: "accessibility", run: runAccessibilityCheck},
+	{Name: "images", run: runImagesCheck},
+	{Name: "spelling", run: runSpellingCheck},
+	{Name: "word-count", run: runWordCountCheck},
+	{Name: "front-matter", run: runFrontMatterCheck},
+}
+
+var checkCmd = &cobra.Command{
+	Use:   "check [post_dir...]",
+	Short: "Run the local checks that publish runs before creating a PR",
+	Long: `Run every local check against posts: lint errors, accessibility, broken image references, spelling,
+word-count bounds (` + MinWordCountEnvVar + `/` + MaxWordCountEnvVar + `), and required front matter fields
+(` + RequiredFrontMatterEnvVar + `, none by default). Checks can be turned off with
+` + SkipChecksEnvVar + ` in ` + EnvFilename + `. With no posts given, checks the post in the current directory,
+else the posts changed on the current branch (or every post, on main). Exits non-zero if any
+check fails, so CI can run it too.`,
+	RunE: runCheck,
+}
+
+func runCheck(cmd *cobra.Command, args []string) error {
+	writingRepoPath := os.Getenv(WritingDirEnvVar)
+	if writingRepoPath == "" {
+		return stacktrace.NewError("writing directory not configured: %s environment variable not set", WritingDirEnvVar)
+	}
+
+	postDirs, err := resolvePostDirs(writingRepoPath, args)
+	if err != nil {
+		return err
+	}
+	if len(postDirs) == 0 {
+		fmt.Println("No posts to check")
+		return nil
+	}
+
+	passed, err := runLocalChecks(writingRepoPath, postDirs)
+	if err != nil {
+		return err
+	}
+	if !passed {
+		return stacktrace.NewError("local checks failed")
+	}
+	return nil
+}
+
+// runLocalChecks runs every enabled local check against the posts and prints the results, returning true
+// if none of them failed
+func runLocalChecks(writingRepoPath string, postDirs []string) (bool, error) {
+	ctx, err := newLocalCheckContext(writingRepoPath)
+	if err != nil {
+		return false, err
+	}
+
+	skippedChecks := map[string]bool{}
+	for _, name := range splitConfigList(getConfigValue(SkipChecksEnvVar)) {
+		skippedChecks[name] = true
+	}
+
+	failureCount := 0
+	for _, postDir := range postDirs {
+		fmt.Printf("🔎 %s\n", postDir)
+		for _, check := range localChecks {
+			result := LocalCheckResult{State: CheckSkipped, Summary: "turned off in " + SkipChecksEnvVar}
+			if !skippedChecks[check.Name] {
+				result, err = check.run(ctx, postDir)
+				if err != nil {
+					return false, stacktrace.Propagate(err, "failed to run the '%s' check on '%s'", check.Name, postDir)
+				}
+			}
+
+			if result.State == CheckFailed {
+				failureCount++
+			}
+			printLocalCheckResult(check.Name, result)
+		}
+	}
+
+	if failureCount > 0 {
+		fmt.Printf("\n❌ %d check(s) failed\n", failureCount)
+		return false, nil
+	}
+	fmt.Println("\n✅ All checks passed")
+	return true, nil
+}
+
+func newLocalCheckContext(writingRepoPath string) (*localCheckContext, error) {
+	lintSeverities, err := getLintSeverities()
+	if err != nil {
+		return nil, err
+	}
+
+	parseWordCount := func(key string) (int, error) {
+		value := getConfigValue(key)
+		if value == "" {
+			return 0, nil
+		}
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			return 0, stacktrace.NewError("%s must be a non-negative number, but was '%s'", key, value)
+		}
+		return parsed, nil
+	}
+	minWordCount, err := parseWordCount(MinWordCountEnvVar)
+	if err != nil {
+		return nil, err
+	}
+	maxWordCount, err := parseWordCount(MaxWordCountEnvVar)
+	if err != nil {
+		return nil, err
+	}
+
+	minAccessibility, err := getMinAccessibilityScore()
+	if err != nil {
+		return nil, err
+	}
+
+	return &localCheckContext{
+		writingRepoPath:     writingRepoPath,
+		lintSeverities:      lintSeverities,
+		minWordCount:        minWordCount,
+		maxWordCount:        maxWordCount,
+		requiredFrontMatter: splitConfigList(getConfigValue(RequiredFrontMatterEnvVar)),
+		minAccessibility:    minAccessibility,
+	}, nil
+}
+
+func printLocalCheckResult(name string, result LocalCheckResult) {
+	emoji := "⏳"
+	switch result.State {
+	case CheckPassed:
+		emoji = "✅"
+	case CheckFailed:
+		emoji = "❌"
+	case CheckSkipped:
+		emoji = "⏭️ "
+	}
+
+	line := fmt.Sprintf("  %s %s", emoji, name)
+	if result.Summary != "" {
+		line += ": " + result.Summary
+	}
+	fmt.Println(line)
+	for _, problem := range result.Problems {
+		fmt.Printf("       %s\n", problem)
+	}
+}
+
+func runLintCheck(ctx *localCheckContext, postDir string) (LocalCheckResult, error) {
+	issues, err := lintPost(ctx.writingRepoPath, postDir, ctx.lintSeverities)
+	if err != nil {
+		return LocalCheckResult{}, err
+	}
+
+	// The accessibility check reports the accessibility rules' issues
+	var problems []string
+	var nonAccessibilityIssues []LintIssue
+	for _, issue := range issues {
+		if isAccessibilityRule(issue.Rule) {
+			continue
+		}
+		nonAccessibilityIssues = append(nonAccessibilityIssues, issue)
+		problems = append(problems, formatLintIssueProblem(issue))
+	}
+	issues = nonAccessibilityIssues
+
+	errorCount := countLintErrors(issues)
+	switch {
+	case errorCount > 0:
+		return LocalCheckResult{State: CheckFailed, Summary: fmt.Sprintf("%d error(s), %d warning(s)", errorCount, len(issues)-errorCount), Problems: problems}, nil
+	case len(issues) > 0:
+		// Warnings are shown, but don't hold the post back
+		return LocalCheckResult{State: CheckPassed, Summary: fmt.Sprintf("%d warning(s)", len(issues)), Problems: problems}, nil
+	default:
+		return LocalCheckResult{State: CheckPassed}, nil
+	}
+}
+
+// runAccessibilityCheck scores the post's accessibility, failing it if it has accessibility lint errors or
+// scores below the configured minimum
+func runAccessibilityCheck(ctx *localCheckContext, postDir string) (LocalCheckResult, error) {
+	report, err := checkPostAccessibility(ctx.writingRepoPath, postDir, ctx.lintSeverities)
+	if err != nil {
+		return LocalCheckResult{}, err
+	}
+
+	var problems []string
+	for _, issue := range report.Issues {
+		problems = append(problems, formatLintIssueProblem(issue))
+	}
+
+	summary := fmt.Sprintf("score %d/100", report.Score)
+	switch {
+	case countLintErrors(report.Issues) > 0:
+		return LocalCheckResult{State: CheckFailed, Summary: summary, Problems: problems}, nil
+	case report.Score < ctx.minAccessibility:
+		summary += fmt.Sprintf(", below the minimum of %d", ctx.minAccessibility)
+		return LocalCheckResult{State: CheckFailed, Summary: summary, Problems: problems}, nil
+	default:
+		// Warnings are shown, but don't hold the post back
+		return LocalCheckResult{State: CheckPassed, Summary: summary, Problems: problems}, nil
+	}
+}
+
+func formatLintIssueProblem(issue LintIssue) string {
+	return fmt.Sprintf("%s:%d: %s %s: %s", PostFilename, issue.Line, issue.Severity, issue.Rule, issue.Message)
+}
+
+func runImagesCheck(ctx *localCheckContext, postDir string) (LocalCheckResult, error) {
+	report, err := checkPostImages(ctx.writingRepoPath, postDir)
+	if err != nil {
+		return LocalCheckResult{}, err
+	}
+
+	var problems []string
+	for _, problem := range report.Problems {
+		problems = append(problems, fmt.Sprintf("%s:%d: %s", PostFilename, problem.Reference.Line, problem.Message))
+	}
+	// Unused images don't affect the published post, so they're only pointed out
+	for _, orphan := range report.Orphans {
+		problems = append(problems, fmt.Sprintf("%s isn't referenced ('opwriting images check --fix' deletes it)", orphan))
+	}
+
+	switch {
+	case len(report.Problems) > 0:
+		return LocalCheckResult{State: CheckFailed, Summary: fmt.Sprintf("%d broken image reference(s)", len(report.Problems)), Problems: problems}, nil
+	case len(report.Orphans) > 0:
+		return LocalCheckResult{State: CheckPassed, Summary: fmt.Sprintf("%d unreferenced image(s)", len(report.Orphans)), Problems: problems}, nil
+	default:
+		return LocalCheckResult{State: CheckPassed}, nil
+	}
+}
+
+// runSpellingCheck checks the post's spelling the same way 'opwriting spell' does
+func runSpellingCheck(ctx *localCheckContext, postDir string) (LocalCheckResult, error) {
+	// Only loaded when it's needed, since it takes a moment
+	if ctx.dictionary == nil {
+		absWritingRepoPath, err := filepath.Abs(ctx.writingRepoPath)
+		if err != nil {
+			return LocalCheckResult{}, stacktrace.Propagate(err, "failed to get absolute path for writing directory")
+		}
+		if ctx.dictionary, err = loadSpellingDictionary(absWritingRepoPath); err != nil {
+			return LocalCheckResult{}, err
+		}
+	}
+
+	misspellings, err := spellCheckPost(ctx.writingRepoPath, postDir, ctx.dictionary)
+	if err != nil {
+		return LocalCheckResult{}, err
+	}
+	if len(misspellings) == 0 {
+		return LocalCheckResult{State: CheckPassed}, nil
+	}
+
+	var problems []string
+	for _, misspelling := range misspellings {
+		problem := fmt.Sprintf("%s:%d: '%s'", PostFilename, misspelling.Line, misspelling.Word)
+		if len(misspelling.Suggestions) > 0 {
+			problem += fmt.Sprintf(" (did you mean '%s'?)", misspelling.Suggestions[0])
+		}
+		problems = append(problems, problem)
+	}
+	return LocalCheckResult{
+		State:    CheckFailed,
+		Summary:  fmt.Sprintf("%d misspelled word(s)", len(misspellings)),
+		Problems: problems,
+	}, nil
+}
+
+func runWordCountCheck(ctx *localCheckContext, postDir string) (LocalCheckResult, error) {
+	if ctx.minWordCount == 0 && ctx.maxWordCount == 0 {
+		return LocalCheckResult{State: CheckSkipped, Summary: fmt.Sprintf("no bounds set (%s/%s)", MinWordCountEnvVar, MaxWordCountEnvVar)}, nil
+	}
+
+	postFilepath := filepath.Join(ctx.writingRepoPath, postDir, PostFilename)
+	content, err := os.ReadFile(postFilepath)
+	if err != nil {
+		return LocalCheckResult{}, stacktrace.Propagate(err, "failed to read post: %s", postFilepath)
+	}
+	_, body, _ := splitFrontMatter(string(content))
+	wordCount := countWords(body)
+
+	summary := fmt.Sprintf("%d words", wordCount)
+	if ctx.minWordCount > 0 && wordCount < ctx.minWordCount {
+		return LocalCheckResult{State: CheckFailed, Summary: fmt.Sprintf("%s, below the minimum of %d", summary, ctx.minWordCount)}, nil
+	}
+	if ctx.maxWordCount > 0 && wordCount > ctx.maxWordCount {
+		return LocalCheckResult{State: CheckFailed, Summary: fmt.Sprintf("%s, above the maximum of %d", summary, ctx.maxWordCount)}, nil
+	}
+	return LocalCheckResult{State: CheckPassed, Summary: summary}, nil
+}
+
+func runFrontMatterCheck(ctx *localCheckContext, postDir string) (LocalCheckResult, error) {
+	if len(ctx.requiredFrontMatter) == 0 {
+		return LocalCheckResult{State: CheckSkipped, Summary: fmt.Sprintf("no fields required (%s)", RequiredFrontMatterEnvVar)}, nil
+	}
+
+	postFilepath := filepath.Join(ctx.writingRepoPath, postDir, PostFilename)
+	content, err := os.ReadFile(postFilepath)
+	if err != nil {
+		return LocalCheckResult{}, stacktrace.Propagate(err, "failed to read post: %s", postFilepath)
+	}
+
+	// Read into a map so that any field can be required, not just the ones opwriting knows about
+	rawFrontMatter, _, _ := splitFrontMatter(string(content))
+	fields := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(rawFrontMatter), &fields); err != nil {
+		return LocalCheckResult{State: CheckFailed, Summary: "front matter isn't valid YAML", Problems: []string{err.Error()}}, nil
+	}
+
+	var missingFields []string
+	for _, field := range ctx.requiredFrontMatter {
+		value, found := fields[field]
+		if !found || value == nil || strings.TrimSpace(fmt.Sprint(value)) == "" {
+			missingFields = append(missingFields, field)
+		}
+	}
+	if len(missingFields) > 0 {
+		return LocalCheckResult{State: CheckFailed, Summary: fmt.Sprintf("missing %s", strings.Join(missingFields, ", "))}, nil
+	}
+	return LocalCheckResult{State: CheckPassed}, nil
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+type CheckStateEnum int
+
+const (
+	CheckPending CheckStateEnum = iota
+	CheckPassed
+	CheckFailed
+	// CheckSkipped covers checks that didn't run or finished without a verdict (skipped, neutral, etc.),
+	// which don't block merging
+	CheckSkipped
+)
+
+// StatusCheck is a single CI check (a commit status, check run, or pipeline job) run against a merge request
+type StatusCheck struct {
+	Context string
+	State   CheckStateEnum
+
+	// The state as reported by the forge, for display
+	RawState string
+
+	// Whether the check must pass before merging; optional checks are reported but never block
+	Required bool
+
+	// Link to the check's logs, if the forge provides one
+	URL string
+}
+
+// checkStateFromForgeState normalizes the state/conclusion vocabularies of the supported forges
+// (GitHub commit statuses and check run conclusions, GitLab job statuses, Gitea commit statuses)
+func checkStateFromForgeState(forgeState string) CheckStateEnum {
+	switch strings.ToLower(forgeState) {
+	case "success":
+		return CheckPassed
+	case "neutral", "skipped", "warning", "manual":
+		return CheckSkipped
+	case "failure", "failed", "error", "cancelled", "canceled", "timed_out", "action_required":
+		return CheckFailed
+	default:
+		// Queued, in progress, stale, etc.
+		return CheckPending
+	}
+}
+
+// markRequiredChecks marks which checks are required given the required check names from the base
+// branch's protection rules, which may be glob patterns. When there are no protection rules, every check
+// is required. Required checks that haven't reported yet are added as pending, since the forge won't
+// allow merging without them.
+func markRequiredChecks(checks []StatusCheck, requiredContexts []string) []StatusCheck {
+	if len(requiredContexts) == 0 {
+		for idx := range checks {
+			checks[idx].Required = true
+		}
+		return checks
+	}
+
+	matchedContexts := make(map[string]bool)
+	for idx := range checks {
+		for _, requiredContext := range requiredContexts {
+			if checkContextMatches(requiredContext, checks[idx].Context) {
+				checks[idx].Required = true
+				matchedContexts[requiredContext] = true
+			}
+		}
+	}
+
+	for _, requiredContext := range requiredContexts {
+		if !matchedContexts[requiredContext] {
+			checks = append(checks, StatusCheck{
+				Context:  requiredContext,
+				State:    CheckPending,
+				RawState: "NOT REPORTED",
+				Required: true,
+			})
+		}
+	}
+
+	return checks
+}
+
+func checkContextMatches(pattern string, context string) bool {
+	if pattern == context {
+		return true
+	}
+	matched, err := path.Match(pattern, context)
+	return err == nil && matched
+}
+
+// evaluateChecks decides whether the checks allow merging: any failed required check fails the PR, and
+// otherwise every required check must have passed (or been skipped). Also returns the checks that are
+// holding things up, i.e. the failed ones on failure and the pending ones while pending.
+func evaluateChecks(checks []StatusCheck) (PRStatusEnum, []StatusCheck) {
+	var failedChecks []StatusCheck
+	var pendingChecks []StatusCheck
+	for _, check := range checks {
+		if !check.Required {
+			continue
+		}
+		switch check.State {
+		case CheckFailed:
+			failedChecks = append(failedChecks, check)
+		case CheckPending:
+			pendingChecks = append(pendingChecks, check)
+		}
+	}
+
+	if len(failedChecks) > 0 {
+		return StatusFailure, failedChecks
+	}
+	if len(pendingChecks) > 0 {
+		return StatusPending, pendingChecks
+	}
+	return StatusSuccess, nil
+}
+
+func printCheck(check StatusCheck) {
+	emoji := "⏳"
+	switch check.State {
+	case CheckPassed:
+		emoji = "✅"
+	case CheckFailed:
+		emoji = "❌"
+	case CheckSkipped:
+		emoji = "⏭️ "
+	}
+
+	optionalSuffix := ""
+	if !check.Required {
+		optionalSuffix = " (optional)"
+	}
+	fmt.Printf("%s %s: %s%s\n", emoji, check.Context, check.RawState, optionalSuffix)
+}
+
+// printFailureReport lists the failed checks along with links to their logs
+func printFailureReport(failedChecks []StatusCheck) {
+	fmt.Println("\nThe following required checks failed:")
+	for _, check := range failedChecks {
+		fmt.Printf("  ❌ %s: %s\n", check.Context, check.RawState)
+		if check.URL != "" {
+			fmt.Printf("     Logs: %s\n", check.URL)
+		}
+	}
+}
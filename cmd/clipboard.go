@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/kurtosis-tech/stacktrace"
+)
+
+const (
+	// Optional command that prints the image on the clipboard, for when the OS's default way of reading it
+	// doesn't work (e.g. 'pngpaste -')
+	ClipboardImageCommandEnvVar = "CLIPBOARD_IMAGE_COMMAND"
+)
+
+// AppleScript prints clipboard data as e.g. '«data PNGf89504E47...»'
+var appleScriptDataRegex = regexp.MustCompile(`«data PNGf([0-9A-Fa-f]*)»`)
+
+// Saves the clipboard's image as a PNG and prints it as base64, since PowerShell can't write binary to stdout
+const windowsClipboardImageScript = `Add-Type -AssemblyName System.Windows.Forms, System.Drawing
+$image = [System.Windows.Forms.Clipboard]::GetImage()
+if ($image -eq $null) { exit 1 }
+$stream = New-Object System.IO.MemoryStream
+$image.Save($stream, [System.Drawing.Imaging.ImageFormat]::Png)
+[Convert]::ToBase64String($stream.ToArray())`
+
+// readClipboardImage returns the image on the clipboard, using the configured command if there is one and the
+// current OS's clipboard tools otherwise
+func readClipboardImage() ([]byte, error) {
+	if fields := splitCommandLine(getConfigValue(ClipboardImageCommandEnvVar)); len(fields) > 0 {
+		return runClipboardCommand(fields[0], fields[1:]...)
+	}
+
+	switch runtime.GOOS {
+	case "darwin":
+		output, err := runClipboardCommand("osascript", "-e", "get the clipboard as «class PNGf»")
+		if err != nil {
+			return nil, err
+		}
+		match := appleScriptDataRegex.FindSubmatch(output)
+		if match == nil {
+			return nil, stacktrace.NewError("the clipboard doesn't have an image on it")
+		}
+		data, err := hex.DecodeString(string(match[1]))
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "failed to decode the clipboard's image")
+		}
+		return data, nil
+	case "windows":
+		output, err := runClipboardCommand("powershell", "-NoProfile", "-Command", windowsClipboardImageScript)
+		if err != nil {
+			return nil, err
+		}
+		data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(output)))
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "failed to decode the clipboard's image")
+		}
+		return data, nil
+	default:
+		if _, err := exec.LookPath("wl-paste"); err == nil && os.Getenv("WAYLAND_DISPLAY") != "" {
+			return runClipboardCommand("wl-paste", "--type", "image/png")
+		}
+		if _, err := exec.LookPath("xclip"); err == nil && os.Getenv("DISPLAY") != "" {
+			return runClipboardCommand("xclip", "-selection", "clipboard", "-target", "image/png", "-out")
+		}
+	}
+
+	return nil, stacktrace.NewError(
+		"no way to read images from the clipboard; install wl-clipboard or xclip, or set %s in %s to a command that prints the clipboard's image",
+		ClipboardImageCommandEnvVar,
+		EnvFilename,
+	)
+}
+
+// runClipboardCommand runs a command that prints the clipboard's contents
+func runClipboardCommand(commandName string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command(commandName, args...)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to read the clipboard using '%s' (is there an image on it?): %s", commandName, strings.TrimSpace(stderr.String()))
+	}
+	if len(output) == 0 {
+		return nil, stacktrace.NewError("the clipboard doesn't have an image on it")
+	}
+	return output, nil
+}
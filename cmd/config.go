@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/joho/godotenv"
+)
+
+// getConfigValue returns the value of the given key from the env file in the root of the writing repo,
+// or the empty string if the file or key doesn't exist
+func getConfigValue(key string) string {
+	// Fall back to the current directory when the writing repo isn't configured
+	envFilepath := EnvFilename
+	if writingRepoPath := os.Getenv(WritingDirEnvVar); writingRepoPath != "" {
+		envFilepath = filepath.Join(writingRepoPath, EnvFilename)
+	}
+
+	// Check if env file exists
+	if _, err := os.Stat(envFilepath); os.IsNotExist(err) {
+		return ""
+	}
+
+	// Load env file using godotenv
+	envVars, err := godotenv.Read(envFilepath)
+	if err != nil {
+		return ""
+	}
+
+	return envVars[key]
+}
@@ -0,0 +1,542 @@
+package cmd
+
+import (
+	"bufio"
+	_ "embed"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/kurtosis-tech/stacktrace"
+)
+
+// The built-in English dictionary, in Hunspell's format, which tools/gendictionary generates
+//
+//go:generate go run ../tools/gendictionary -words ../tools/gendictionary/words -out en_US.dic
+//go:embed en_US.dic
+var builtInDictionaryWords string
+
+//go:embed en_US.aff
+var builtInDictionaryAffixes string
+
+const (
+	// Suggestions further than this many edits from the misspelled word aren't worth showing
+	maxSuggestionDistance = 2
+	maxSuggestions        = 5
+)
+
+// Dictionary is a Hunspell-style word list, with every word's affixed forms expanded up front
+type Dictionary struct {
+	// Word -> its position in the word list, which is roughly how common it is
+	words map[string]int
+
+	// Characters to try when looking for suggestions, most common first
+	tryChars []rune
+
+	// Common mistakes, as pairs of (what was typed, what was meant)
+	replacements [][2]string
+}
+
+// hunspellAffix is a single prefix or suffix rule from an .aff file
+type hunspellAffix struct {
+	isPrefix     bool
+	crossProduct bool
+	strip        string
+	add          string
+	condition    []hunspellConditionChar
+}
+
+// hunspellConditionChar matches one character of an affix rule's condition, e.g. '[^aeiou]'
+type hunspellConditionChar struct {
+	chars  string
+	negate bool
+	any    bool
+}
+
+// loadBuiltInDictionary returns the dictionary that's embedded in the binary
+func loadBuiltInDictionary() (*Dictionary, error) {
+	return parseHunspellDictionary(strings.NewReader(builtInDictionaryWords), strings.NewReader(builtInDictionaryAffixes))
+}
+
+// loadHunspellDictionary reads a .dic file and the .aff file next to it
+func loadHunspellDictionary(dicFilepath string) (*Dictionary, error) {
+	affFilepath := strings.TrimSuffix(dicFilepath, ".dic") + ".aff"
+	dicFile, err := os.Open(dicFilepath)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to open dictionary: %s", dicFilepath)
+	}
+	defer dicFile.Close()
+	affFile, err := os.Open(affFilepath)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to open the dictionary's affix file: %s", affFilepath)
+	}
+	defer affFile.Close()
+
+	dictionary, err := parseHunspellDictionary(dicFile, affFile)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to parse dictionary: %s", dicFilepath)
+	}
+	return dictionary, nil
+}
+
+// parseHunspellDictionary reads a Hunspell dictionary. Only what's needed to check words is supported: the
+// affix rules (one level deep, without continuation classes), TRY, REP, FORBIDDENWORD, and NEEDAFFIX.
+func parseHunspellDictionary(dicReader io.Reader, affReader io.Reader) (*Dictionary, error) {
+	dictionary := &Dictionary{words: map[string]int{}}
+	affixes := map[string][]hunspellAffix{}
+	crossProducts := map[string]bool{}
+	flagType := "char"
+	forbiddenFlag := ""
+	needAffixFlag := ""
+
+	scanner := bufio.NewScanner(affReader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		switch fields[0] {
+		case "FLAG":
+			flagType = fields[1]
+		case "TRY":
+			dictionary.tryChars = []rune(fields[1])
+		case "REP":
+			if len(fields) >= 3 {
+				dictionary.replacements = append(dictionary.replacements, [2]string{
+					strings.ReplaceAll(fields[1], "_", " "),
+					strings.ReplaceAll(fields[2], "_", " "),
+				})
+			}
+		case "FORBIDDENWORD":
+			forbiddenFlag = fields[1]
+		case "NEEDAFFIX":
+			needAffixFlag = fields[1]
+		case "PFX", "SFX":
+			// The header line is 'SFX flag cross_product count'; rules are 'SFX flag strip add condition'
+			if len(fields) == 4 {
+				if _, err := strconv.Atoi(fields[3]); err == nil && (fields[2] == "Y" || fields[2] == "N") {
+					crossProducts[fields[1]] = fields[2] == "Y"
+					continue
+				}
+			}
+			if len(fields) < 4 {
+				return nil, stacktrace.NewError("invalid affix rule '%s'", scanner.Text())
+			}
+			affix := hunspellAffix{
+				isPrefix:     fields[0] == "PFX",
+				crossProduct: crossProducts[fields[1]],
+				strip:        fields[2],
+				add:          strings.SplitN(fields[3], "/", 2)[0],
+			}
+			if affix.strip == "0" {
+				affix.strip = ""
+			}
+			if affix.add == "0" {
+				affix.add = ""
+			}
+			condition := "."
+			if len(fields) >= 5 {
+				condition = fields[4]
+			}
+			affix.condition = parseHunspellCondition(condition)
+			affixes[fields[1]] = append(affixes[fields[1]], affix)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, stacktrace.Propagate(err, "failed to read affix file")
+	}
+
+	scanner = bufio.NewScanner(dicReader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	isFirstLine := true
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if isFirstLine {
+			isFirstLine = false
+			// The first line is the number of words
+			if _, err := strconv.Atoi(line); err == nil {
+				continue
+			}
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// Anything after whitespace is morphological data
+		if idx := strings.IndexAny(line, " \t"); idx >= 0 {
+			line = line[:idx]
+		}
+		word, flagString := splitHunspellEntry(line)
+		flags := parseHunspellFlags(flagString, flagType)
+
+		if containsString(flags, forbiddenFlag) {
+			continue
+		}
+		if !containsString(flags, needAffixFlag) {
+			dictionary.addWord(word)
+		}
+
+		var prefixes, suffixes []hunspellAffix
+		for _, flag := range flags {
+			for _, affix := range affixes[flag] {
+				if affix.isPrefix {
+					prefixes = append(prefixes, affix)
+				} else {
+					suffixes = append(suffixes, affix)
+				}
+			}
+		}
+		for _, suffix := range suffixes {
+			suffixed, ok := suffix.apply(word)
+			if !ok {
+				continue
+			}
+			dictionary.addWord(suffixed)
+			for _, prefix := range prefixes {
+				if prefix.crossProduct && suffix.crossProduct {
+					if both, ok := prefix.apply(suffixed); ok {
+						dictionary.addWord(both)
+					}
+				}
+			}
+		}
+		for _, prefix := range prefixes {
+			if prefixed, ok := prefix.apply(word); ok {
+				dictionary.addWord(prefixed)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, stacktrace.Propagate(err, "failed to read word list")
+	}
+	return dictionary, nil
+}
+
+// splitHunspellEntry splits a word list entry into the word and its flags, allowing for escaped slashes
+func splitHunspellEntry(entry string) (string, string) {
+	for idx := 0; idx < len(entry); idx++ {
+		if entry[idx] == '\\' {
+			idx++
+			continue
+		}
+		if entry[idx] == '/' && idx > 0 {
+			return strings.ReplaceAll(entry[:idx], `\/`, "/"), entry[idx+1:]
+		}
+	}
+	return strings.ReplaceAll(entry, `\/`, "/"), ""
+}
+
+// parseHunspellFlags splits a word's flags according to the .aff file's FLAG setting
+func parseHunspellFlags(flagString string, flagType string) []string {
+	var flags []string
+	switch flagType {
+	case "long":
+		for idx := 0; idx+1 < len(flagString); idx += 2 {
+			flags = append(flags, flagString[idx:idx+2])
+		}
+	case "num":
+		for _, flag := range strings.Split(flagString, ",") {
+			if flag != "" {
+				flags = append(flags, flag)
+			}
+		}
+	default:
+		for _, flag := range flagString {
+			flags = append(flags, string(flag))
+		}
+	}
+	return flags
+}
+
+// parseHunspellCondition parses a rule's condition, a simplified regex like 'e' or '[^aeiou]y'
+func parseHunspellCondition(condition string) []hunspellConditionChar {
+	var result []hunspellConditionChar
+	runes := []rune(condition)
+	for idx := 0; idx < len(runes); idx++ {
+		switch runes[idx] {
+		case '.':
+			result = append(result, hunspellConditionChar{any: true})
+		case '[':
+			end := idx + 1
+			for end < len(runes) && runes[end] != ']' {
+				end++
+			}
+			chars := string(runes[idx+1 : end])
+			negate := strings.HasPrefix(chars, "^")
+			result = append(result, hunspellConditionChar{chars: strings.TrimPrefix(chars, "^"), negate: negate})
+			idx = end
+		default:
+			result = append(result, hunspellConditionChar{chars: string(runes[idx])})
+		}
+	}
+	// A lone '.' means the rule applies to any word
+	if len(result) == 1 && result[0].any {
+		return nil
+	}
+	return result
+}
+
+func (char hunspellConditionChar) matches(r rune) bool {
+	if char.any {
+		return true
+	}
+	return strings.ContainsRune(char.chars, r) != char.negate
+}
+
+// apply returns the word with the affix added, if the rule applies to it
+func (affix hunspellAffix) apply(word string) (string, bool) {
+	runes := []rune(word)
+	if len(affix.condition) > len(runes) {
+		return "", false
+	}
+	for idx, char := range affix.condition {
+		var r rune
+		if affix.isPrefix {
+			r = runes[idx]
+		} else {
+			r = runes[len(runes)-len(affix.condition)+idx]
+		}
+		if !char.matches(r) {
+			return "", false
+		}
+	}
+
+	if affix.isPrefix {
+		if !strings.HasPrefix(word, affix.strip) {
+			return "", false
+		}
+		return affix.add + strings.TrimPrefix(word, affix.strip), true
+	}
+	if !strings.HasSuffix(word, affix.strip) {
+		return "", false
+	}
+	return strings.TrimSuffix(word, affix.strip) + affix.add, true
+}
+
+func (dictionary *Dictionary) addWord(word string) {
+	if _, found := dictionary.words[word]; !found {
+		dictionary.words[word] = len(dictionary.words)
+	}
+}
+
+// AddWords adds words that aren't in the dictionary, e.g. from the personal dictionary, as if they were
+func (dictionary *Dictionary) AddWords(words []string) {
+	for _, word := range words {
+		dictionary.addWord(strings.ReplaceAll(word, "’", "'"))
+	}
+}
+
+// Check returns true if the word is spelled correctly. Like Hunspell, a lowercase dictionary word may be
+// capitalized or written in all caps, but a capitalized one (e.g. a name) must stay capitalized.
+func (dictionary *Dictionary) Check(word string) bool {
+	word = strings.ReplaceAll(word, "’", "'")
+	if _, found := dictionary.words[word]; found {
+		return true
+	}
+
+	lower := strings.ToLower(word)
+	if lower != word {
+		if _, found := dictionary.words[lower]; found {
+			return true
+		}
+		if capitalized := capitalize(lower); capitalized != word {
+			if _, found := dictionary.words[capitalized]; found {
+				return true
+			}
+		}
+	}
+
+	// Possessives
+	for _, ending := range []string{"'s", "'S"} {
+		if stem, found := strings.CutSuffix(word, ending); found && stem != "" {
+			return dictionary.Check(stem)
+		}
+	}
+	return false
+}
+
+// Suggest returns the dictionary words closest to a misspelled one, best first
+func (dictionary *Dictionary) Suggest(word string) []string {
+	word = strings.ReplaceAll(word, "’", "'")
+	lower := strings.ToLower(word)
+
+	type candidate struct {
+		word     string
+		distance int
+		rank     int
+	}
+	candidates := map[string]candidate{}
+	consider := func(edited string, distance int) {
+		for _, form := range []string{edited, capitalize(edited)} {
+			rank, found := dictionary.words[form]
+			if !found {
+				continue
+			}
+			if existing, seen := candidates[form]; seen && existing.distance <= distance {
+				continue
+			}
+			candidates[form] = candidate{word: form, distance: distance, rank: rank}
+		}
+	}
+
+	// Known mistakes count as a single edit, however many characters they change
+	for _, replacement := range dictionary.replacements {
+		for offset := 0; offset < len(lower); {
+			idx := strings.Index(lower[offset:], replacement[0])
+			if idx < 0 {
+				break
+			}
+			idx += offset
+			consider(lower[:idx]+replacement[1]+lower[idx+len(replacement[0]):], 1)
+			offset = idx + 1
+		}
+	}
+	for _, edited := range dictionary.edits(lower) {
+		consider(edited, 1)
+	}
+
+	// Two words run together
+	runes := []rune(lower)
+	for idx := 1; idx < len(runes); idx++ {
+		before, after := string(runes[:idx]), string(runes[idx:])
+		if dictionary.Check(before) && dictionary.Check(after) {
+			candidates[before+" "+after] = candidate{
+				word:     before + " " + after,
+				distance: 1,
+				rank:     max(dictionary.words[before], dictionary.words[after]),
+			}
+		}
+	}
+
+	// Generating every string two edits away takes far longer than comparing against every word of
+	// about the right length
+	if len(candidates) == 0 {
+		for dictionaryWord, rank := range dictionary.words {
+			dictionaryRunes := []rune(strings.ToLower(dictionaryWord))
+			if abs(len(dictionaryRunes)-len(runes)) > maxSuggestionDistance {
+				continue
+			}
+			if distance := editDistance(runes, dictionaryRunes); distance <= maxSuggestionDistance {
+				candidates[dictionaryWord] = candidate{word: dictionaryWord, distance: distance, rank: rank}
+			}
+		}
+	}
+
+	sorted := make([]candidate, 0, len(candidates))
+	for _, c := range candidates {
+		if c.word != word {
+			sorted = append(sorted, c)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].distance != sorted[j].distance {
+			return sorted[i].distance < sorted[j].distance
+		}
+		return sorted[i].rank < sorted[j].rank
+	})
+
+	var suggestions []string
+	for _, c := range sorted {
+		if len(suggestions) == maxSuggestions {
+			break
+		}
+		suggestions = append(suggestions, matchCase(c.word, word))
+	}
+	return suggestions
+}
+
+// edits returns every string one deletion, transposition, replacement, or insertion away from the word
+func (dictionary *Dictionary) edits(word string) []string {
+	runes := []rune(word)
+	var result []string
+	for idx := 0; idx <= len(runes); idx++ {
+		before, after := string(runes[:idx]), string(runes[idx:])
+		if idx < len(runes) {
+			result = append(result, before+string(runes[idx+1:]))
+		}
+		if idx+1 < len(runes) {
+			result = append(result, before+string(runes[idx+1])+string(runes[idx])+string(runes[idx+2:]))
+		}
+		for _, char := range dictionary.tryChars {
+			if unicode.IsUpper(char) {
+				continue
+			}
+			if idx < len(runes) && char != runes[idx] {
+				result = append(result, before+string(char)+string(runes[idx+1:]))
+			}
+			result = append(result, before+string(char)+after)
+		}
+	}
+	return result
+}
+
+// editDistance returns the number of deletions, insertions, replacements, and transpositions of adjacent
+// characters needed to turn one word into the other
+func editDistance(a []rune, b []rune) int {
+	rows := make([][]int, len(a)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(b)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			rows[i][j] = min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				rows[i][j] = min(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+	return rows[len(a)][len(b)]
+}
+
+func capitalize(word string) string {
+	first, size := utf8.DecodeRuneInString(word)
+	return string(unicode.ToUpper(first)) + word[size:]
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
+
+// matchCase makes a suggestion's case match the misspelled word's, e.g. so 'Teh' suggests 'The'
+func matchCase(suggestion string, original string) string {
+	switch {
+	case len(original) > 1 && strings.ToUpper(original) == original:
+		return strings.ToUpper(suggestion)
+	case startsWithUpper(original):
+		return capitalize(suggestion)
+	default:
+		return suggestion
+	}
+}
+
+func startsWithUpper(word string) bool {
+	first, _ := utf8.DecodeRuneInString(word)
+	return unicode.IsUpper(first)
+}
+
+func containsString(values []string, target string) bool {
+	if target == "" {
+		return false
+	}
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+	return false
+}
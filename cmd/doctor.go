@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kurtosis-tech/stacktrace"
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Report problems with the writing repo",
+	Long: `Inspect the writing repo for problems, such as posts that have diverging versions
+on several branches (e.g. a published post being revised on a branch, or the same
+post directory being written on two unmerged branches).`,
+	RunE: runDoctor,
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	// Get writing directory from environment variable
+	writingRepoPath := os.Getenv(WritingDirEnvVar)
+	if writingRepoPath == "" {
+		return stacktrace.NewError("writing directory not configured: %s environment variable not set", WritingDirEnvVar)
+	}
+
+	postEntries, err := collectPostEntries(writingRepoPath)
+	if err != nil {
+		return stacktrace.Propagate(err, "failed to collect posts across branches")
+	}
+
+	problemCount := 0
+	dirs, entriesByDir := groupPostEntriesByDir(postEntries)
+	for _, dir := range dirs {
+		versions := entriesByDir[dir]
+		if len(versions) <= 1 {
+			continue
+		}
+		problemCount++
+
+		// Main's version always comes first when it exists
+		if versions[0].Branch == MainBranchName {
+			fmt.Printf("⚠️  %s is published on %s but has %d diverging version(s):\n", dir, MainBranchName, len(versions)-1)
+		} else {
+			fmt.Printf("⚠️  %s exists on %d unmerged branches with different content:\n", dir, len(versions))
+		}
+		for _, version := range versions {
+			fmt.Printf("    - %s\n", version.Branch)
+		}
+	}
+
+	if problemCount == 0 {
+		fmt.Println("✅ No problems found")
+		return nil
+	}
+
+	return stacktrace.NewError("found %d post(s) duplicated across branches", problemCount)
+}
@@ -17,8 +17,9 @@ import (
 )
 
 type PostEntry struct {
-	Dir    string
-	Branch string
+	Dir      string
+	Branch   string
+	TreeHash string
 }
 
 type BranchDistance struct {
@@ -30,10 +31,18 @@ var findCmd = &cobra.Command{
 	Use:   "find [search_terms...]",
 	Short: "Find and select a post directory from any branch",
 	Long: `Find post directories across all Git branches, sorted by commit distance from main,
-and allow interactive selection with fzf.`,
+and allow interactive selection with fzf. When a post has different versions on
+several branches, each version is listed separately so the branch can be chosen.
+With --tag, only posts whose front matter has the tag are listed.`,
 	RunE: findPosts,
 }
 
+var findTags []string
+
+func init() {
+	findCmd.Flags().StringArrayVar(&findTags, "tag", nil, "Only show posts with this tag (can be given more than once, to require several)")
+}
+
 func findPosts(cmd *cobra.Command, args []string) error {
 	// Get writing directory from environment variable
 	writingRepoPath := os.Getenv(WritingDirEnvVar)
@@ -48,54 +57,31 @@ func findPosts(cmd *cobra.Command, args []string) error {
 		return stacktrace.NewError("writing repo path does not exist: %s", writingRepoPath)
 	}
 
-	seenDirs := make(map[string]bool)
-	branchMapping := make(map[string]string)
-	var entries []string
-
-	// Get main branch post directories first (they take precedence)
-	mainPosts, err := getPostDirsFromBranch(writingRepoPath, "main")
+	postEntries, err := collectPostEntries(writingRepoPath)
 	if err != nil {
-		return stacktrace.Propagate(err, "failed to get posts from main branch")
+		return stacktrace.Propagate(err, "failed to collect posts across branches")
 	}
 
-	for _, dir := range mainPosts {
-		if !seenDirs[dir] {
-			entries = append(entries, dir)
-			branchMapping[dir] = "main"
-			seenDirs[dir] = true
-		}
+	// Count how many distinct versions of each post directory exist
+	versionCounts := make(map[string]int)
+	for _, entry := range postEntries {
+		versionCounts[entry.Dir]++
 	}
 
-	// Get all non-main branches sorted by distance from main
-	sortedBranches, err := getBranchesSortedByDistance(writingRepoPath)
+	// Sort entries by last commit date
+	sortedEntries, err := sortEntriesByCommitDate(writingRepoPath, postEntries)
 	if err != nil {
-		return stacktrace.Propagate(err, "failed to get sorted branches")
-	}
-
-	// Process each branch in order
-	for _, branchDist := range sortedBranches {
-		posts, err := getPostDirsFromBranch(writingRepoPath, branchDist.Branch)
-		if err != nil {
-			return stacktrace.Propagate(err, "failed to get posts from branch %s", branchDist.Branch)
-		}
-
-		for _, dir := range posts {
-			if !seenDirs[dir] {
-				entries = append(entries, dir)
-				branchMapping[dir] = branchDist.Branch
-				seenDirs[dir] = true
-			}
-		}
+		return stacktrace.Propagate(err, "failed to sort entries by commit date")
 	}
 
-	// Sort entries by last commit date
-	sortedEntries, err := sortEntriesByCommitDate(writingRepoPath, entries, branchMapping)
+	// Filter entries based on tags if provided
+	sortedEntries, err = filterEntriesByTags(writingRepoPath, sortedEntries, findTags)
 	if err != nil {
-		return stacktrace.Propagate(err, "failed to sort entries by commit date")
+		return stacktrace.Propagate(err, "failed to filter entries by tags")
 	}
 
 	// Filter entries based on search terms if provided
-	var filteredEntries []string
+	var filteredEntries []PostEntry
 	if searchTerms != "" {
 		filteredEntries, err = filterEntriesBySearchTerms(sortedEntries, searchTerms)
 		if err != nil {
@@ -105,13 +91,22 @@ func findPosts(cmd *cobra.Command, args []string) error {
 		filteredEntries = sortedEntries
 	}
 
+	// Build the display lines, remembering which entry each one refers to
+	displayMapping := make(map[string]PostEntry)
+	var displayLines []string
+	for _, entry := range filteredEntries {
+		line := formatPostEntry(entry, versionCounts[entry.Dir])
+		displayMapping[line] = entry
+		displayLines = append(displayLines, line)
+	}
+
 	// If exactly one match, skip fzf and use it directly
 	var selection string
-	if len(filteredEntries) == 1 {
-		selection = filteredEntries[0]
+	if len(displayLines) == 1 {
+		selection = displayLines[0]
 	} else {
 		// Launch fzf for selection
-		selection, err = runFzf(filteredEntries, searchTerms)
+		selection, err = runFzf(displayLines, searchTerms)
 		if err != nil {
 			return stacktrace.Propagate(err, "fzf selection failed")
 		}
@@ -121,37 +116,144 @@ func findPosts(cmd *cobra.Command, args []string) error {
 		os.Exit(2) // User cancelled - exit with status 2
 	}
 
-	// Look up the branch for this selection
-	branch, exists := branchMapping[selection]
+	// Look up the entry for this selection
+	entry, exists := displayMapping[selection]
 	if !exists {
 		return stacktrace.NewError("no branch mapping found for selection: %s", selection)
 	}
 
 	// Output the result
-	fmt.Printf("%s %s\n", branch, selection)
+	fmt.Printf("%s %s\n", entry.Branch, entry.Dir)
 	return nil
 }
 
-func getPostDirsFromBranch(repoPath, branch string) ([]string, error) {
-	cmd := exec.Command("git", "-C", repoPath, "ls-tree", "-r", "--name-only", branch)
+// collectPostEntries returns every distinct version of every post directory across main and the
+// branches not yet merged into it. Main's versions come first, followed by the branches in order of
+// distance from main. A branch's copy of a post is only included when the branch changed the post since it
+// forked from main, and its content differs from every version already collected, so branches that merely
+// inherited a post from main don't duplicate it (even after main has changed the post since).
+func collectPostEntries(repoPath string) ([]PostEntry, error) {
+	seenVersions := make(map[string]map[string]bool)
+	var entries []PostEntry
+
+	addBranchPosts := func(branch string) error {
+		posts, err := getPostDirsFromBranch(repoPath, branch)
+		if err != nil {
+			return stacktrace.Propagate(err, "failed to get posts from branch %s", branch)
+		}
+
+		var changedDirs map[string]bool
+		if branch != MainBranchName {
+			affectedPosts, err := getRepoAffectedPosts(repoPath, branch)
+			if err != nil {
+				return stacktrace.Propagate(err, "failed to find the posts changed on branch %s", branch)
+			}
+			changedDirs = make(map[string]bool)
+			for _, post := range affectedPosts {
+				changedDirs[post.Dir] = true
+			}
+		}
+
+		for _, post := range posts {
+			if changedDirs != nil && !changedDirs[post.Dir] {
+				continue
+			}
+			if seenVersions[post.Dir] == nil {
+				seenVersions[post.Dir] = make(map[string]bool)
+			}
+			if seenVersions[post.Dir][post.TreeHash] {
+				continue
+			}
+			seenVersions[post.Dir][post.TreeHash] = true
+			entries = append(entries, post)
+		}
+		return nil
+	}
+
+	// Get main branch post directories first (they take precedence)
+	if err := addBranchPosts(MainBranchName); err != nil {
+		return nil, err
+	}
+
+	// Get all non-main branches sorted by distance from main
+	sortedBranches, err := getBranchesSortedByDistance(repoPath)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to get sorted branches")
+	}
+
+	// Process each branch in order
+	for _, branchDist := range sortedBranches {
+		if err := addBranchPosts(branchDist.Branch); err != nil {
+			return nil, err
+		}
+	}
+
+	return entries, nil
+}
+
+// groupPostEntriesByDir groups post entries by directory, returning the directories in the order in
+// which they were first seen
+func groupPostEntriesByDir(entries []PostEntry) ([]string, map[string][]PostEntry) {
+	var dirs []string
+	grouped := make(map[string][]PostEntry)
+	for _, entry := range entries {
+		if _, exists := grouped[entry.Dir]; !exists {
+			dirs = append(dirs, entry.Dir)
+		}
+		grouped[entry.Dir] = append(grouped[entry.Dir], entry)
+	}
+	return dirs, grouped
+}
+
+func formatPostEntry(entry PostEntry, versionCount int) string {
+	if versionCount <= 1 {
+		return entry.Dir
+	}
+	return fmt.Sprintf("%s [%s] (%d versions)", entry.Dir, entry.Branch, versionCount)
+}
+
+func getPostDirsFromBranch(repoPath, branch string) ([]PostEntry, error) {
+	// Include tree entries so that each post directory's content hash is available. Without '--full-tree', running
+	// from a subdirectory (e.g. inside a post) would only list that subdirectory, with paths relative to it.
+	cmd := exec.Command("git", "-C", repoPath, "ls-tree", "-r", "-t", "--full-tree", branch)
 	output, err := cmd.Output()
 	if err != nil {
 		return nil, stacktrace.Propagate(err, "failed to run git ls-tree for branch %s", branch)
 	}
 
-	var dirs []string
+	var postDirs []string
+	treeHashes := make(map[string]string)
 	postRegex := regexp.MustCompile(`/post\.md$`)
-	
+
 	scanner := bufio.NewScanner(strings.NewReader(string(output)))
 	for scanner.Scan() {
-		file := scanner.Text()
-		if postRegex.MatchString(file) {
-			dir := filepath.Dir(file)
-			dirs = append(dirs, dir)
+		// Lines look like '<mode> <type> <hash>\t<path>'
+		metadata, file, found := strings.Cut(scanner.Text(), "\t")
+		if !found {
+			continue
 		}
+		fields := strings.Fields(metadata)
+		if len(fields) != 3 {
+			continue
+		}
+
+		if fields[1] == "tree" {
+			treeHashes[file] = fields[2]
+		} else if postRegex.MatchString(file) {
+			postDirs = append(postDirs, filepath.Dir(file))
+		}
+	}
+
+	var posts []PostEntry
+	for _, dir := range postDirs {
+		posts = append(posts, PostEntry{
+			Dir:      dir,
+			Branch:   branch,
+			TreeHash: treeHashes[dir],
+		})
 	}
 
-	return dirs, nil
+	return posts, nil
 }
 
 func getBranchesSortedByDistance(repoPath string) ([]BranchDistance, error) {
@@ -211,9 +313,9 @@ func getBranchesSortedByDistance(repoPath string) ([]BranchDistance, error) {
 	return distances, nil
 }
 
-func sortEntriesByCommitDate(repoPath string, entries []string, branchMapping map[string]string) ([]string, error) {
+func sortEntriesByCommitDate(repoPath string, entries []PostEntry) ([]PostEntry, error) {
 	type entryWithTimestamp struct {
-		dir       string
+		entry     PostEntry
 		timestamp int64
 	}
 
@@ -221,31 +323,30 @@ func sortEntriesByCommitDate(repoPath string, entries []string, branchMapping ma
 	var wg sync.WaitGroup
 	var mu sync.Mutex
 
-	for _, dir := range entries {
+	for _, entry := range entries {
 		wg.Add(1)
-		go func(directory string) {
+		go func(postEntry PostEntry) {
 			defer wg.Done()
-			
-			branch := branchMapping[directory]
-			cmd := exec.Command("git", "-C", repoPath, "log", "--max-count=1", "--format=%ct", branch, "--", directory)
+
+			cmd := exec.Command("git", "-C", repoPath, "log", "--max-count=1", "--format=%ct", postEntry.Branch, "--", postEntry.Dir)
 			output, err := cmd.Output()
-			
+
 			timestamp := int64(0) // Default for error cases
 			if err == nil {
 				if ts, parseErr := strconv.ParseInt(strings.TrimSpace(string(output)), 10, 64); parseErr == nil {
 					timestamp = ts
 				}
 			}
-			
+
 			mu.Lock()
 			entriesWithTimestamps = append(entriesWithTimestamps, entryWithTimestamp{
-				dir:       directory,
+				entry:     postEntry,
 				timestamp: timestamp,
 			})
 			mu.Unlock()
-		}(dir)
+		}(entry)
 	}
-	
+
 	wg.Wait()
 
 	// Sort by timestamp (most recent first)
@@ -253,9 +354,9 @@ func sortEntriesByCommitDate(repoPath string, entries []string, branchMapping ma
 		return entriesWithTimestamps[i].timestamp > entriesWithTimestamps[j].timestamp
 	})
 
-	var sortedEntries []string
-	for _, entry := range entriesWithTimestamps {
-		sortedEntries = append(sortedEntries, entry.dir)
+	var sortedEntries []PostEntry
+	for _, entryWithTs := range entriesWithTimestamps {
+		sortedEntries = append(sortedEntries, entryWithTs.entry)
 	}
 
 	return sortedEntries, nil
@@ -308,7 +409,7 @@ func runFzf(entries []string, query string) (string, error) {
 	return strings.TrimSpace(output), nil
 }
 
-func filterEntriesBySearchTerms(entries []string, searchTerms string) ([]string, error) {
+func filterEntriesBySearchTerms(entries []PostEntry, searchTerms string) ([]PostEntry, error) {
 	if searchTerms == "" {
 		return entries, nil
 	}
@@ -336,9 +437,9 @@ func filterEntriesBySearchTerms(entries []string, searchTerms string) ([]string,
 	}
 
 	// Filter entries
-	var filtered []string
+	var filtered []PostEntry
 	for _, entry := range entries {
-		if regex.MatchString(entry) {
+		if regex.MatchString(entry.Dir) {
 			filtered = append(filtered, entry)
 		}
 	}
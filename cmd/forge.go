@@ -0,0 +1,354 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/kurtosis-tech/stacktrace"
+)
+
+const (
+	ForgeTypeEnvVar = "FORGE_TYPE"
+
+	GitHubForgeType = "github"
+	GitLabForgeType = "gitlab"
+	GiteaForgeType  = "gitea"
+
+	MergeMethodMerge  = "merge"
+	MergeMethodSquash = "squash"
+	MergeMethodRebase = "rebase"
+
+	forgeRequestTimeout = 30 * time.Second
+)
+
+// Matches 'git@github.com:owner/repo.git', 'ssh://git@github.com/owner/repo', 'https://github.com/owner/repo.git', etc.
+var remoteURLRegex = regexp.MustCompile(`^(?:[a-z+]+://)?(?:[^@/]+@)?([^:/]+)(?::\d+)?[:/](.+?)/([^/]+?)(?:\.git)?/?$`)
+
+// Forge is a Git hosting service (GitHub, GitLab, Gitea, etc.) that hosts the writing repo and its merge requests
+type Forge interface {
+	// Name returns the human-readable name of the forge
+	Name() string
+
+	// FindMergeRequest returns the most recent open or merged merge request for the branch, or nil if there is none
+	FindMergeRequest(branch string) (*MergeRequest, error)
+
+	GetMergeRequest(number int) (*MergeRequest, error)
+
+	// CreateMergeRequest opens a merge request from the branch into the base branch, applying the options'
+	// labels and reviewers
+	CreateMergeRequest(branch string, baseBranch string, options MergeRequestOptions) (*MergeRequest, error)
+
+	// MarkReadyForReview takes the merge request out of draft
+	MarkReadyForReview(number int) error
+
+	// GetChecks returns the CI checks that have run (or are running) against the merge request's head commit,
+	// including required checks that haven't reported yet
+	GetChecks(mergeRequest *MergeRequest) ([]StatusCheck, error)
+
+	// GetRequiredChecks returns the names (or glob patterns) of the checks that the branch's protection rules
+	// require to pass before merging, or nothing if every check is required
+	GetRequiredChecks(branch string) ([]string, error)
+
+	// GetReviews returns the reviews that have been left on the merge request, oldest first
+	GetReviews(number int) ([]Review, error)
+
+	// RequestReviewers asks the given users to review the merge request
+	RequestReviewers(number int, reviewers []string) error
+
+	// GetReviewComments returns every comment left on the merge request, whether on a line of a file or on
+	// the merge request as a whole
+	GetReviewComments(number int) ([]ReviewComment, error)
+
+	// Merge merges the merge request using the given merge method ('merge', 'squash', or 'rebase')
+	Merge(number int, mergeMethod string) error
+
+	// EnableAutoMerge has the forge merge the merge request itself, using the given merge method, as soon as
+	// its required checks pass
+	EnableAutoMerge(number int, mergeMethod string) error
+
+	// DeleteBranch deletes the remote branch, succeeding if it has already been deleted
+	DeleteBranch(branch string) error
+}
+
+type MergeRequestStateEnum int
+
+const (
+	MergeRequestOpen MergeRequestStateEnum = iota
+	MergeRequestMerged
+	MergeRequestClosed
+)
+
+// MergeRequest is a forge-agnostic pull/merge request
+type MergeRequest struct {
+	Number     int
+	URL        string
+	State      MergeRequestStateEnum
+	HeadSHA    string
+	BaseBranch string
+	Draft      bool
+}
+
+// MergeRequestOptions describes the merge request to create
+type MergeRequestOptions struct {
+	Title     string
+	Body      string
+	Draft     bool
+	Labels    []string
+	Reviewers []string
+}
+
+type Review struct {
+	Author string
+	State  string
+}
+
+// ReviewComment is a comment left on a merge request
+type ReviewComment struct {
+	Author string
+	Body   string
+
+	// The file the comment is on, or empty for comments on the merge request as a whole
+	Path string
+
+	// The line of the file the comment is on as of the merge request's latest commit, or 0 if it's not on a
+	// line or the line has since changed
+	Line int
+
+	CreatedAt time.Time
+}
+
+type ForgeErrorKindEnum int
+
+const (
+	// ForgeErrorNotFound means the requested resource doesn't exist (or isn't visible with the token)
+	ForgeErrorNotFound ForgeErrorKindEnum = iota
+	// ForgeErrorAuth means the token is missing, invalid, or lacks the necessary permissions
+	ForgeErrorAuth
+	// ForgeErrorNetwork means the forge couldn't be reached at all
+	ForgeErrorNetwork
+	// ForgeErrorAPI means the forge rejected the request for some other reason
+	ForgeErrorAPI
+)
+
+// ForgeError is the root cause of every failed request made to a forge's API, so callers can tell what
+// kind of failure happened using isForgeError
+type ForgeError struct {
+	Forge      string
+	Kind       ForgeErrorKindEnum
+	StatusCode int
+	Message    string
+}
+
+func (err *ForgeError) Error() string {
+	if err.StatusCode == 0 {
+		return err.Message
+	}
+	return fmt.Sprintf("%s API returned status %d: %s", err.Forge, err.StatusCode, err.Message)
+}
+
+// isForgeError returns true if the root cause of the error is a ForgeError of the given kind
+func isForgeError(err error, kind ForgeErrorKindEnum) bool {
+	forgeErr, ok := stacktrace.RootCause(err).(*ForgeError)
+	return ok && forgeErr.Kind == kind
+}
+
+// newForgeForCurrentRepo creates a client for the forge that the 'origin' remote of the current Git repo
+// points to. The forge type is guessed from the remote's host, and can be set explicitly in the config
+// for self-hosted instances.
+func newForgeForCurrentRepo() (Forge, error) {
+	host, owner, repo, err := getOriginRepo()
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to determine the repository from the origin remote")
+	}
+
+	forgeType := getConfigValue(ForgeTypeEnvVar)
+	if forgeType == "" {
+		switch {
+		case host == DefaultGitHubHost:
+			forgeType = GitHubForgeType
+		case strings.Contains(host, "gitlab"):
+			forgeType = GitLabForgeType
+		case strings.Contains(host, "gitea") || strings.Contains(host, "forgejo") || host == "codeberg.org":
+			forgeType = GiteaForgeType
+		default:
+			return nil, stacktrace.NewError(
+				"couldn't tell which kind of forge hosts '%s'; set %s to one of '%s', '%s', or '%s' in %s",
+				host,
+				ForgeTypeEnvVar,
+				GitHubForgeType,
+				GitLabForgeType,
+				GiteaForgeType,
+				EnvFilename,
+			)
+		}
+	}
+
+	switch forgeType {
+	case GitHubForgeType:
+		return newGitHubClientForRepo(host, owner, repo)
+	case GitLabForgeType:
+		return newGitLabClientForRepo(host, owner, repo)
+	case GiteaForgeType:
+		return newGiteaClientForRepo(host, owner, repo)
+	default:
+		return nil, stacktrace.NewError("unrecognized forge type '%s' in %s", forgeType, ForgeTypeEnvVar)
+	}
+}
+
+// validateMergeMethod errors if the merge method isn't one that every forge supports
+func validateMergeMethod(mergeMethod string) error {
+	switch mergeMethod {
+	case MergeMethodMerge, MergeMethodSquash, MergeMethodRebase:
+		return nil
+	default:
+		return stacktrace.NewError(
+			"unrecognized merge method '%s'; must be one of '%s', '%s', or '%s'",
+			mergeMethod,
+			MergeMethodMerge,
+			MergeMethodSquash,
+			MergeMethodRebase,
+		)
+	}
+}
+
+// getOriginRepo parses the host, owner, and repo name out of the current repo's 'origin' remote URL
+func getOriginRepo() (string, string, string, error) {
+	cmd := exec.Command("git", "remote", "get-url", "origin")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", "", "", stacktrace.Propagate(err, "failed to get the URL of the origin remote")
+	}
+	remoteURL := strings.TrimSpace(string(output))
+
+	matches := remoteURLRegex.FindStringSubmatch(remoteURL)
+	if matches == nil {
+		return "", "", "", stacktrace.NewError("couldn't parse the origin remote URL: %s", remoteURL)
+	}
+
+	return matches[1], matches[2], matches[3], nil
+}
+
+// getForgeToken gets a token from the writing repo's config or environment, falling back to the given
+// CLI command (if any) that prints the token the user is logged in with
+func getForgeToken(forgeName string, tokenEnvVar string, fallbackCmdArgs ...string) (string, error) {
+	if token := getConfigValue(tokenEnvVar); token != "" {
+		return token, nil
+	}
+	if token := os.Getenv(tokenEnvVar); token != "" {
+		return token, nil
+	}
+
+	if len(fallbackCmdArgs) > 0 {
+		cmd := exec.Command(fallbackCmdArgs[0], fallbackCmdArgs[1:]...)
+		if output, err := cmd.Output(); err == nil && strings.TrimSpace(string(output)) != "" {
+			return strings.TrimSpace(string(output)), nil
+		}
+	}
+
+	return "", &ForgeError{
+		Forge:   forgeName,
+		Kind:    ForgeErrorAuth,
+		Message: fmt.Sprintf("no %s token found; set %s in %s or in your environment", forgeName, tokenEnvVar, EnvFilename),
+	}
+}
+
+// forgeAPIClient sends JSON requests to a forge's REST API
+type forgeAPIClient struct {
+	forgeName  string
+	httpClient *http.Client
+	apiURL     string
+	headers    map[string]string
+}
+
+func newForgeAPIClient(forgeName string, apiURL string, headers map[string]string) *forgeAPIClient {
+	return &forgeAPIClient{
+		forgeName:  forgeName,
+		httpClient: &http.Client{Timeout: forgeRequestTimeout},
+		apiURL:     strings.TrimSuffix(apiURL, "/"),
+		headers:    headers,
+	}
+}
+
+// doRequest sends a request to the forge's API, JSON-encoding the request body (if any) and decoding the
+// response into the result (if any). Failed requests are returned as a *ForgeError.
+func (client *forgeAPIClient) doRequest(method string, path string, requestBody interface{}, result interface{}) error {
+	var bodyReader io.Reader
+	if requestBody != nil {
+		bodyBytes, err := json.Marshal(requestBody)
+		if err != nil {
+			return stacktrace.Propagate(err, "failed to serialize request body for %s %s", method, path)
+		}
+		bodyReader = bytes.NewReader(bodyBytes)
+	}
+
+	request, err := http.NewRequest(method, client.apiURL+path, bodyReader)
+	if err != nil {
+		return stacktrace.Propagate(err, "failed to build request for %s %s", method, path)
+	}
+	for header, value := range client.headers {
+		request.Header.Set(header, value)
+	}
+	if requestBody != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := client.httpClient.Do(request)
+	if err != nil {
+		return &ForgeError{
+			Forge:   client.forgeName,
+			Kind:    ForgeErrorNetwork,
+			Message: fmt.Sprintf("couldn't reach %s at %s: %v", client.forgeName, client.apiURL, err),
+		}
+	}
+	defer response.Body.Close()
+
+	responseBytes, err := io.ReadAll(response.Body)
+	if err != nil {
+		return &ForgeError{
+			Forge:   client.forgeName,
+			Kind:    ForgeErrorNetwork,
+			Message: fmt.Sprintf("failed to read response for %s %s: %v", method, path, err),
+		}
+	}
+
+	if response.StatusCode >= 300 {
+		// Error responses generally look like '{"message": "..."}'
+		var errorResponse struct {
+			Message interface{} `json:"message"`
+		}
+		message := strings.TrimSpace(string(responseBytes))
+		if err := json.Unmarshal(responseBytes, &errorResponse); err == nil && errorResponse.Message != nil {
+			message = fmt.Sprintf("%v", errorResponse.Message)
+		}
+
+		kind := ForgeErrorAPI
+		switch response.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			kind = ForgeErrorAuth
+		case http.StatusNotFound:
+			kind = ForgeErrorNotFound
+		}
+		return &ForgeError{
+			Forge:      client.forgeName,
+			Kind:       kind,
+			StatusCode: response.StatusCode,
+			Message:    fmt.Sprintf("%s %s: %s", method, path, message),
+		}
+	}
+
+	if result == nil || len(responseBytes) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(responseBytes, result); err != nil {
+		return stacktrace.Propagate(err, "failed to parse response JSON for %s %s", method, path)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// newFakeForge starts a fake forge API that serves the given handlers, keyed by 'METHOD /escaped/path', returning
+// its URL. Requests without the given auth header, or that no handler matches, fail the test.
+func newFakeForge(t *testing.T, authHeader string, authValue string, handlers map[string]http.HandlerFunc) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.Header.Get(authHeader) != authValue {
+			t.Errorf("request to %s had %s '%s'", request.URL.Path, authHeader, request.Header.Get(authHeader))
+		}
+		handler, found := handlers[request.Method+" "+request.URL.EscapedPath()]
+		if !found {
+			t.Errorf("unexpected request: %s %s", request.Method, request.URL.EscapedPath())
+			http.NotFound(writer, request)
+			return
+		}
+		handler(writer, request)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+// newClosedServerURL returns the URL of a server that's been shut down, so connections to it are refused
+func newClosedServerURL() string {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	return server.URL
+}
+
+func respondJSON(writer http.ResponseWriter, statusCode int, body interface{}) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(statusCode)
+	json.NewEncoder(writer).Encode(body)
+}
+
+func respondStatus(statusCode int, message string) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		respondJSON(writer, statusCode, map[string]string{"message": message})
+	}
+}
+
+func respondNoContent(writer http.ResponseWriter, request *http.Request) {
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+// respondPage responds with the requested page (from the 'page' query parameter) of a list of total items
+func respondPage(writer http.ResponseWriter, request *http.Request, total int, pageSize int, item func(idx int) map[string]interface{}) {
+	page, err := strconv.Atoi(request.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	items := []map[string]interface{}{}
+	for idx := (page - 1) * pageSize; idx < min(page*pageSize, total); idx++ {
+		items = append(items, item(idx))
+	}
+	respondJSON(writer, http.StatusOK, items)
+}
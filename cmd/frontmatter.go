@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/kurtosis-tech/stacktrace"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	frontMatterDelimiter = "---"
+)
+
+// PostFrontMatter is the YAML metadata at the top of a post.md file, between two '---' lines
+type PostFrontMatter struct {
+	Title   string `yaml:"title"`
+	Summary string `yaml:"summary"`
+
+	// When the post should be published, parsed by parsePublishAt
+	PublishAt string `yaml:"publish_at"`
+
+	// Word count to aim for, and when to hit it by (parsed by parsePublishAt), for 'opwriting goal'
+	TargetWords int    `yaml:"target_words"`
+	Deadline    string `yaml:"deadline"`
+
+	Tags PostTags `yaml:"tags"`
+
+	// The series of posts that this post is part of, and its place in it; posts without a part number come after
+	// the numbered ones, in the order they're published
+	Series     string `yaml:"series"`
+	SeriesPart int    `yaml:"series_part"`
+
+	// How many times the post has been revised since it was first published, kept up to date by 'opwriting revise'
+	Revision int `yaml:"revision"`
+
+	// Where the post was published, for wiki links from other posts; only needed if it isn't at the Substack URL
+	// for the post's directory name
+	URL string `yaml:"url"`
+}
+
+// splitFrontMatter separates a post's front matter from its body, returning false if the post doesn't start
+// with a front matter
+func splitFrontMatter(content string) (string, string, bool) {
+	normalized := strings.ReplaceAll(content, "\r\n", "\n")
+	if !strings.HasPrefix(normalized, frontMatterDelimiter+"\n") {
+		return "", content, false
+	}
+
+	// The front matter may be empty, in which case the closing delimiter immediately follows the opening one
+	rest := "\n" + strings.TrimPrefix(normalized, frontMatterDelimiter+"\n")
+	endIdx := strings.Index(rest, "\n"+frontMatterDelimiter)
+	if endIdx == -1 {
+		return "", content, false
+	}
+
+	frontMatter := strings.TrimPrefix(rest[:endIdx], "\n")
+	body := rest[endIdx+len("\n"+frontMatterDelimiter):]
+	// Drop the rest of the closing delimiter's line
+	if newlineIdx := strings.Index(body, "\n"); newlineIdx != -1 {
+		body = body[newlineIdx+1:]
+	} else {
+		body = ""
+	}
+	return frontMatter, body, true
+}
+
+// setFrontMatterField sets the top-level front matter field to the given (already YAML-formatted) value,
+// replacing the field's existing line if there is one, and adding a front matter if there isn't one.
+// An empty value removes the field.
+func setFrontMatterField(content string, key string, value string) string {
+	rawFrontMatter, body, hasFrontMatter := splitFrontMatter(content)
+	if !hasFrontMatter && value == "" {
+		return content
+	}
+
+	var lines []string
+	if rawFrontMatter != "" {
+		lines = strings.Split(rawFrontMatter, "\n")
+	}
+
+	var updatedLines []string
+	replaced := false
+	for _, line := range lines {
+		if strings.HasPrefix(line, key+":") {
+			if value != "" && !replaced {
+				updatedLines = append(updatedLines, fmt.Sprintf("%s: %s", key, value))
+			}
+			replaced = true
+			continue
+		}
+		updatedLines = append(updatedLines, line)
+	}
+	if !replaced && value != "" {
+		updatedLines = append(updatedLines, fmt.Sprintf("%s: %s", key, value))
+	}
+
+	if !hasFrontMatter {
+		body = "\n" + content
+	}
+	updatedFrontMatter := ""
+	for _, line := range updatedLines {
+		updatedFrontMatter += line + "\n"
+	}
+	return frontMatterDelimiter + "\n" + updatedFrontMatter + frontMatterDelimiter + "\n" + body
+}
+
+// parsePost parses a post's front matter, returning it along with the post's body
+func parsePost(content string) (*PostFrontMatter, string, error) {
+	rawFrontMatter, body, _ := splitFrontMatter(content)
+
+	var frontMatter PostFrontMatter
+	if err := yaml.Unmarshal([]byte(rawFrontMatter), &frontMatter); err != nil {
+		return nil, "", stacktrace.Propagate(err, "failed to parse front matter")
+	}
+	return &frontMatter, body, nil
+}
+
+// readPostFromBranch returns the contents of the post's post.md as of the given branch
+func readPostFromBranch(repoPath string, branch string, postDir string) (string, error) {
+	cmd := exec.Command("git", "-C", repoPath, "show", fmt.Sprintf("%s:%s/%s", branch, postDir, PostFilename))
+	output, err := cmd.Output()
+	if err != nil {
+		return "", stacktrace.Propagate(err, "failed to read '%s/%s' on branch '%s'", postDir, PostFilename, branch)
+	}
+	return string(output), nil
+}
+
+// countWords counts the words in a post's body
+func countWords(body string) int {
+	return len(strings.Fields(body))
+}
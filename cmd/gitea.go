@@ -0,0 +1,428 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/kurtosis-tech/stacktrace"
+)
+
+const (
+	GiteaTokenEnvVar  = "GITEA_TOKEN"
+	GiteaAPIURLEnvVar = "GITEA_API_URL"
+
+	// Gitea caps page sizes at 50 by default
+	giteaPageSize = 50
+
+	// Gitea marks pull requests as work in progress by their title
+	giteaDraftPrefix = "WIP: "
+)
+
+// Matches the title prefixes that Gitea treats as marking a work-in-progress pull request by default
+var giteaDraftPrefixRegex = regexp.MustCompile(`(?i)^\s*(\[wip\]|wip:)\s*`)
+
+type giteaPullRequest struct {
+	Number  int    `json:"number"`
+	HTMLURL string `json:"html_url"`
+	Title   string `json:"title"`
+	State   string `json:"state"`
+	Merged  bool   `json:"merged"`
+	Head    struct {
+		Ref string `json:"ref"`
+		SHA string `json:"sha"`
+	} `json:"head"`
+	Base struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+}
+
+type giteaCombinedStatus struct {
+	Statuses []struct {
+		Context   string `json:"context"`
+		Status    string `json:"status"`
+		TargetURL string `json:"target_url"`
+	} `json:"statuses"`
+}
+
+type giteaBranchProtection struct {
+	EnableStatusCheck   bool     `json:"enable_status_check"`
+	StatusCheckContexts []string `json:"status_check_contexts"`
+}
+
+type giteaLabel struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+type giteaReview struct {
+	ID            int64     `json:"id"`
+	State         string    `json:"state"`
+	Body          string    `json:"body"`
+	CommentsCount int       `json:"comments_count"`
+	SubmittedAt   time.Time `json:"submitted_at"`
+	User          struct {
+		Login string `json:"login"`
+	} `json:"user"`
+}
+
+// giteaComment is either a comment on a line of a pull request's diff or a comment on its conversation
+type giteaComment struct {
+	Body string `json:"body"`
+	Path string `json:"path"`
+	// The line in the latest version of the file, or 0 if the line has since changed
+	Position  int       `json:"position"`
+	CreatedAt time.Time `json:"created_at"`
+	User      struct {
+		Login string `json:"login"`
+	} `json:"user"`
+}
+
+// GiteaClient talks to the Gitea REST API for a single repository. Forgejo shares Gitea's API, so it's
+// supported by this client too.
+type GiteaClient struct {
+	api   *forgeAPIClient
+	owner string
+	repo  string
+}
+
+func NewGiteaClient(apiURL string, token string, owner string, repo string) *GiteaClient {
+	headers := map[string]string{
+		"Accept": "application/json",
+	}
+	if token != "" {
+		headers["Authorization"] = "token " + token
+	}
+
+	return &GiteaClient{
+		api:   newForgeAPIClient("Gitea", apiURL, headers),
+		owner: owner,
+		repo:  repo,
+	}
+}
+
+func newGiteaClientForRepo(host string, owner string, repo string) (*GiteaClient, error) {
+	apiURL := getConfigValue(GiteaAPIURLEnvVar)
+	if apiURL == "" {
+		apiURL = fmt.Sprintf("https://%s/api/v1", host)
+	}
+
+	token, err := getForgeToken("Gitea", GiteaTokenEnvVar)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to get a Gitea token")
+	}
+
+	return NewGiteaClient(apiURL, token, owner, repo), nil
+}
+
+func (client *GiteaClient) Name() string {
+	return "Gitea"
+}
+
+func (client *GiteaClient) FindMergeRequest(branch string) (*MergeRequest, error) {
+	// Gitea can't filter pull requests by head branch, so page through them all (newest first)
+	for page := 1; ; page++ {
+		query := url.Values{}
+		query.Set("state", "all")
+		query.Set("sort", "newest")
+		query.Set("limit", fmt.Sprintf("%d", giteaPageSize))
+		query.Set("page", fmt.Sprintf("%d", page))
+
+		var pullRequests []giteaPullRequest
+		if err := client.api.doRequest(http.MethodGet, client.repoPath("pulls")+"?"+query.Encode(), nil, &pullRequests); err != nil {
+			return nil, stacktrace.Propagate(err, "failed to list pull requests")
+		}
+
+		for _, pullRequest := range pullRequests {
+			if pullRequest.Head.Ref != branch {
+				continue
+			}
+			mergeRequest := pullRequest.toMergeRequest()
+			if mergeRequest.State != MergeRequestClosed {
+				return mergeRequest, nil
+			}
+		}
+
+		if len(pullRequests) < giteaPageSize {
+			return nil, nil
+		}
+	}
+}
+
+func (client *GiteaClient) GetMergeRequest(number int) (*MergeRequest, error) {
+	var pullRequest giteaPullRequest
+	if err := client.api.doRequest(http.MethodGet, client.repoPath(fmt.Sprintf("pulls/%d", number)), nil, &pullRequest); err != nil {
+		return nil, stacktrace.Propagate(err, "failed to get pull request #%d", number)
+	}
+	return pullRequest.toMergeRequest(), nil
+}
+
+func (client *GiteaClient) CreateMergeRequest(branch string, baseBranch string, options MergeRequestOptions) (*MergeRequest, error) {
+	title := options.Title
+	if options.Draft {
+		title = giteaDraftPrefix + title
+	}
+
+	request := map[string]interface{}{
+		"head":  branch,
+		"base":  baseBranch,
+		"title": title,
+		"body":  options.Body,
+	}
+
+	// Labels are added by ID rather than name
+	if len(options.Labels) > 0 {
+		labelIDs, err := client.getLabelIDs(options.Labels)
+		if err != nil {
+			return nil, err
+		}
+		request["labels"] = labelIDs
+	}
+
+	var pullRequest giteaPullRequest
+	if err := client.api.doRequest(http.MethodPost, client.repoPath("pulls"), request, &pullRequest); err != nil {
+		return nil, stacktrace.Propagate(err, "failed to create pull request for branch '%s'", branch)
+	}
+
+	if len(options.Reviewers) > 0 {
+		if err := client.RequestReviewers(pullRequest.Number, options.Reviewers); err != nil {
+			return nil, err
+		}
+	}
+
+	return pullRequest.toMergeRequest(), nil
+}
+
+func (client *GiteaClient) MarkReadyForReview(number int) error {
+	var pullRequest giteaPullRequest
+	if err := client.api.doRequest(http.MethodGet, client.repoPath(fmt.Sprintf("pulls/%d", number)), nil, &pullRequest); err != nil {
+		return stacktrace.Propagate(err, "failed to get pull request #%d", number)
+	}
+
+	request := map[string]interface{}{
+		"title": giteaDraftPrefixRegex.ReplaceAllString(pullRequest.Title, ""),
+	}
+	if err := client.api.doRequest(http.MethodPatch, client.repoPath(fmt.Sprintf("pulls/%d", number)), request, nil); err != nil {
+		return stacktrace.Propagate(err, "failed to mark pull request #%d as ready", number)
+	}
+	return nil
+}
+
+// GetChecks returns the commit statuses of the head commit, which is how both Gitea Actions and external
+// CI systems report their results
+func (client *GiteaClient) GetChecks(mergeRequest *MergeRequest) ([]StatusCheck, error) {
+	var combinedStatus giteaCombinedStatus
+	if err := client.api.doRequest(http.MethodGet, client.repoPath(fmt.Sprintf("commits/%s/status", mergeRequest.HeadSHA)), nil, &combinedStatus); err != nil {
+		return nil, stacktrace.Propagate(err, "failed to get commit statuses for '%s'", mergeRequest.HeadSHA)
+	}
+
+	var checks []StatusCheck
+	for _, commitStatus := range combinedStatus.Statuses {
+		checks = append(checks, StatusCheck{
+			Context:  commitStatus.Context,
+			State:    checkStateFromForgeState(commitStatus.Status),
+			RawState: strings.ToUpper(commitStatus.Status),
+			URL:      commitStatus.TargetURL,
+		})
+	}
+
+	// The base branch's protection rules (if any) determine which checks must pass
+	requiredChecks, err := client.GetRequiredChecks(mergeRequest.BaseBranch)
+	if err != nil {
+		return nil, err
+	}
+
+	return markRequiredChecks(checks, requiredChecks), nil
+}
+
+func (client *GiteaClient) GetRequiredChecks(branch string) ([]string, error) {
+	var protection giteaBranchProtection
+	protectionPath := client.repoPath("branch_protections/" + url.PathEscape(branch))
+	if err := client.api.doRequest(http.MethodGet, protectionPath, nil, &protection); err != nil {
+		// Unprotected branches have no protection rules at all
+		if isForgeError(err, ForgeErrorNotFound) {
+			return nil, nil
+		}
+		return nil, stacktrace.Propagate(err, "failed to get protection rules for branch '%s'", branch)
+	}
+
+	if !protection.EnableStatusCheck {
+		return nil, nil
+	}
+	return protection.StatusCheckContexts, nil
+}
+
+func (client *GiteaClient) GetReviews(number int) ([]Review, error) {
+	var giteaReviews []giteaReview
+	if err := client.api.doRequest(http.MethodGet, client.repoPath(fmt.Sprintf("pulls/%d/reviews", number)), nil, &giteaReviews); err != nil {
+		return nil, stacktrace.Propagate(err, "failed to get reviews for pull request #%d", number)
+	}
+
+	var reviews []Review
+	for _, review := range giteaReviews {
+		reviews = append(reviews, Review{
+			Author: review.User.Login,
+			State:  review.State,
+		})
+	}
+	return reviews, nil
+}
+
+func (client *GiteaClient) RequestReviewers(number int, reviewers []string) error {
+	request := map[string]interface{}{
+		"reviewers": reviewers,
+	}
+	if err := client.api.doRequest(http.MethodPost, client.repoPath(fmt.Sprintf("pulls/%d/requested_reviewers", number)), request, nil); err != nil {
+		return stacktrace.Propagate(err, "failed to request reviewers for pull request #%d", number)
+	}
+	return nil
+}
+
+// GetReviewComments returns the bodies of the pull request's reviews and the comments within them, plus
+// the comments on its conversation
+func (client *GiteaClient) GetReviewComments(number int) ([]ReviewComment, error) {
+	var giteaReviews []giteaReview
+	if err := client.api.doRequest(http.MethodGet, client.repoPath(fmt.Sprintf("pulls/%d/reviews", number)), nil, &giteaReviews); err != nil {
+		return nil, stacktrace.Propagate(err, "failed to get reviews for pull request #%d", number)
+	}
+
+	var comments []ReviewComment
+	for _, review := range giteaReviews {
+		if strings.TrimSpace(review.Body) != "" {
+			comments = append(comments, ReviewComment{
+				Author:    review.User.Login,
+				Body:      review.Body,
+				CreatedAt: review.SubmittedAt,
+			})
+		}
+		if review.CommentsCount == 0 {
+			continue
+		}
+
+		var reviewComments []giteaComment
+		reviewCommentsPath := client.repoPath(fmt.Sprintf("pulls/%d/reviews/%d/comments", number, review.ID))
+		if err := client.api.doRequest(http.MethodGet, reviewCommentsPath, nil, &reviewComments); err != nil {
+			return nil, stacktrace.Propagate(err, "failed to get comments for review %d on pull request #%d", review.ID, number)
+		}
+		for _, reviewComment := range reviewComments {
+			comments = append(comments, ReviewComment{
+				Author:    reviewComment.User.Login,
+				Body:      reviewComment.Body,
+				Path:      reviewComment.Path,
+				Line:      reviewComment.Position,
+				CreatedAt: reviewComment.CreatedAt,
+			})
+		}
+	}
+
+	// Pull requests are issues as far as their conversation is concerned
+	for page := 1; ; page++ {
+		query := url.Values{}
+		query.Set("limit", fmt.Sprintf("%d", giteaPageSize))
+		query.Set("page", fmt.Sprintf("%d", page))
+
+		var conversationComments []giteaComment
+		if err := client.api.doRequest(http.MethodGet, client.repoPath(fmt.Sprintf("issues/%d/comments", number))+"?"+query.Encode(), nil, &conversationComments); err != nil {
+			return nil, stacktrace.Propagate(err, "failed to get comments for pull request #%d", number)
+		}
+		for _, conversationComment := range conversationComments {
+			comments = append(comments, ReviewComment{
+				Author:    conversationComment.User.Login,
+				Body:      conversationComment.Body,
+				CreatedAt: conversationComment.CreatedAt,
+			})
+		}
+
+		if len(conversationComments) < giteaPageSize {
+			return comments, nil
+		}
+	}
+}
+
+func (client *GiteaClient) Merge(number int, mergeMethod string) error {
+	request := map[string]interface{}{
+		"Do": mergeMethod,
+	}
+
+	if err := client.api.doRequest(http.MethodPost, client.repoPath(fmt.Sprintf("pulls/%d/merge", number)), request, nil); err != nil {
+		return stacktrace.Propagate(err, "failed to merge pull request #%d", number)
+	}
+	return nil
+}
+
+func (client *GiteaClient) EnableAutoMerge(number int, mergeMethod string) error {
+	request := map[string]interface{}{
+		"Do":                        mergeMethod,
+		"merge_when_checks_succeed": true,
+	}
+
+	if err := client.api.doRequest(http.MethodPost, client.repoPath(fmt.Sprintf("pulls/%d/merge", number)), request, nil); err != nil {
+		return stacktrace.Propagate(err, "failed to schedule pull request #%d to merge once checks succeed", number)
+	}
+	return nil
+}
+
+func (client *GiteaClient) DeleteBranch(branch string) error {
+	err := client.api.doRequest(http.MethodDelete, client.repoPath("branches/"+url.PathEscape(branch)), nil, nil)
+	if err != nil && !isForgeError(err, ForgeErrorNotFound) {
+		return stacktrace.Propagate(err, "failed to delete remote branch '%s'", branch)
+	}
+	return nil
+}
+
+// getLabelIDs looks up the IDs of the repo's labels with the given names, erroring if any don't exist
+func (client *GiteaClient) getLabelIDs(names []string) ([]int64, error) {
+	labelIDsByName := map[string]int64{}
+	for page := 1; ; page++ {
+		query := url.Values{}
+		query.Set("limit", fmt.Sprintf("%d", giteaPageSize))
+		query.Set("page", fmt.Sprintf("%d", page))
+
+		var labels []giteaLabel
+		if err := client.api.doRequest(http.MethodGet, client.repoPath("labels")+"?"+query.Encode(), nil, &labels); err != nil {
+			return nil, stacktrace.Propagate(err, "failed to list labels")
+		}
+		for _, label := range labels {
+			labelIDsByName[label.Name] = label.ID
+		}
+
+		if len(labels) < giteaPageSize {
+			break
+		}
+	}
+
+	var labelIDs []int64
+	for _, name := range names {
+		labelID, found := labelIDsByName[name]
+		if !found {
+			return nil, stacktrace.NewError("no label named '%s' exists in the repo", name)
+		}
+		labelIDs = append(labelIDs, labelID)
+	}
+	return labelIDs, nil
+}
+
+func (client *GiteaClient) repoPath(path string) string {
+	return fmt.Sprintf("/repos/%s/%s/%s", client.owner, client.repo, path)
+}
+
+func (pullRequest giteaPullRequest) toMergeRequest() *MergeRequest {
+	state := MergeRequestOpen
+	if pullRequest.Merged {
+		state = MergeRequestMerged
+	} else if pullRequest.State != "open" {
+		state = MergeRequestClosed
+	}
+
+	return &MergeRequest{
+		Number:     pullRequest.Number,
+		URL:        pullRequest.HTMLURL,
+		State:      state,
+		HeadSHA:    pullRequest.Head.SHA,
+		BaseBranch: pullRequest.Base.Ref,
+		Draft:      giteaDraftPrefixRegex.MatchString(pullRequest.Title),
+	}
+}
@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+const (
+	testGiteaOwner = "owner"
+	testGiteaRepo  = "repo"
+	testGiteaToken = "test-token"
+)
+
+// newFakeGitea starts a fake Gitea API that serves the given handlers, keyed by 'METHOD /escaped/path'
+func newFakeGitea(t *testing.T, handlers map[string]http.HandlerFunc) *GiteaClient {
+	serverURL := newFakeForge(t, "Authorization", "token "+testGiteaToken, handlers)
+	return NewGiteaClient(serverURL, testGiteaToken, testGiteaOwner, testGiteaRepo)
+}
+
+func testGiteaRepoPath(path string) string {
+	return fmt.Sprintf("/repos/%s/%s/%s", testGiteaOwner, testGiteaRepo, path)
+}
+
+func TestGiteaFindMergeRequestPaginates(t *testing.T) {
+	const pullRequestCount = giteaPageSize + 10
+
+	client := newFakeGitea(t, map[string]http.HandlerFunc{
+		"GET " + testGiteaRepoPath("pulls"): func(writer http.ResponseWriter, request *http.Request) {
+			if limit := request.URL.Query().Get("limit"); limit != fmt.Sprintf("%d", giteaPageSize) {
+				t.Errorf("expected a limit of %d, got '%s'", giteaPageSize, limit)
+			}
+			// The branch's pull request is on the second page, behind a closed one for the same branch
+			respondPage(writer, request, pullRequestCount, giteaPageSize, func(idx int) map[string]interface{} {
+				pullRequest := map[string]interface{}{
+					"number": pullRequestCount - idx,
+					"state":  "open",
+					"title":  fmt.Sprintf("Post %d", idx),
+					"head":   map[string]string{"ref": fmt.Sprintf("other-%d", idx), "sha": "abc"},
+				}
+				switch idx {
+				case giteaPageSize + 2:
+					pullRequest["head"] = map[string]string{"ref": "my-post", "sha": "old"}
+					pullRequest["state"] = "closed"
+				case giteaPageSize + 3:
+					pullRequest["head"] = map[string]string{"ref": "my-post", "sha": "def"}
+					pullRequest["title"] = "WIP: My post"
+				}
+				return pullRequest
+			})
+		},
+	})
+
+	mergeRequest, err := client.FindMergeRequest("my-post")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if mergeRequest == nil || mergeRequest.HeadSHA != "def" {
+		t.Fatalf("expected the open pull request for 'my-post', got: %+v", mergeRequest)
+	}
+	if !mergeRequest.Draft || mergeRequest.State != MergeRequestOpen {
+		t.Errorf("expected an open draft, got: %+v", mergeRequest)
+	}
+}
+
+func TestGiteaGetRequiredChecks(t *testing.T) {
+	protectionPath := "GET " + testGiteaRepoPath("branch_protections/main")
+
+	client := newFakeGitea(t, map[string]http.HandlerFunc{protectionPath: respondStatus(http.StatusNotFound, "not found")})
+	requiredChecks, err := client.GetRequiredChecks("main")
+	if err != nil || requiredChecks != nil {
+		t.Errorf("expected an unprotected branch to require nothing, got: %v, %v", requiredChecks, err)
+	}
+
+	client = newFakeGitea(t, map[string]http.HandlerFunc{
+		protectionPath: func(writer http.ResponseWriter, request *http.Request) {
+			respondJSON(writer, http.StatusOK, map[string]interface{}{"enable_status_check": true, "status_check_contexts": []string{"build", "lint/*"}})
+		},
+	})
+	requiredChecks, err = client.GetRequiredChecks("main")
+	if err != nil || len(requiredChecks) != 2 || requiredChecks[1] != "lint/*" {
+		t.Errorf("expected the protection's status checks, got: %v, %v", requiredChecks, err)
+	}
+
+	client = newFakeGitea(t, map[string]http.HandlerFunc{protectionPath: respondStatus(http.StatusForbidden, "token does not have permission")})
+	if _, err := client.GetRequiredChecks("main"); !isForgeError(err, ForgeErrorAuth) {
+		t.Errorf("expected an auth error, got: %v", err)
+	}
+}
+
+func TestGiteaGetReviewComments(t *testing.T) {
+	const conversationCommentCount = giteaPageSize + 1
+
+	client := newFakeGitea(t, map[string]http.HandlerFunc{
+		"GET " + testGiteaRepoPath("pulls/5/reviews"): func(writer http.ResponseWriter, request *http.Request) {
+			respondJSON(writer, http.StatusOK, []map[string]interface{}{
+				{"id": 1, "state": "APPROVED", "body": "", "comments_count": 0},
+				{"id": 2, "state": "REQUEST_CHANGES", "body": "A few things", "comments_count": 1, "user": map[string]string{"login": "editor"}},
+			})
+		},
+		"GET " + testGiteaRepoPath("pulls/5/reviews/2/comments"): func(writer http.ResponseWriter, request *http.Request) {
+			respondJSON(writer, http.StatusOK, []map[string]interface{}{
+				{"body": "Typo", "path": "my-post/post.md", "position": 12, "user": map[string]string{"login": "editor"}},
+			})
+		},
+		"GET " + testGiteaRepoPath("issues/5/comments"): func(writer http.ResponseWriter, request *http.Request) {
+			respondPage(writer, request, conversationCommentCount, giteaPageSize, func(idx int) map[string]interface{} {
+				return map[string]interface{}{"body": fmt.Sprintf("comment %d", idx)}
+			})
+		},
+	})
+
+	comments, err := client.GetReviewComments(5)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	// The review with a body, its line comment, and every conversation comment
+	if expected := conversationCommentCount + 2; len(comments) != expected {
+		t.Fatalf("expected %d comments, got %d", expected, len(comments))
+	}
+	if lineComment := comments[1]; lineComment.Path != "my-post/post.md" || lineComment.Line != 12 {
+		t.Errorf("expected a comment on line 12 of the post, got: %+v", lineComment)
+	}
+}
+
+func TestGiteaErrors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		handler http.HandlerFunc
+		errKind ForgeErrorKindEnum
+	}{
+		{name: "not found", handler: respondStatus(http.StatusNotFound, "The target couldn't be found."), errKind: ForgeErrorNotFound},
+		{name: "bad token", handler: respondStatus(http.StatusUnauthorized, "token is required"), errKind: ForgeErrorAuth},
+		{name: "server error", handler: respondStatus(http.StatusInternalServerError, "internal error"), errKind: ForgeErrorAPI},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := newFakeGitea(t, map[string]http.HandlerFunc{
+				"GET " + testGiteaRepoPath("pulls/5"): testCase.handler,
+			})
+			_, err := client.GetMergeRequest(5)
+			if !isForgeError(err, testCase.errKind) {
+				t.Errorf("expected a forge error of kind %d, got: %v", testCase.errKind, err)
+			}
+		})
+	}
+
+	t.Run("network", func(t *testing.T) {
+		client := NewGiteaClient(newClosedServerURL(), testGiteaToken, testGiteaOwner, testGiteaRepo)
+		_, err := client.GetMergeRequest(5)
+		if !isForgeError(err, ForgeErrorNetwork) {
+			t.Errorf("expected a network error, got: %v", err)
+		}
+	})
+}
+
+func TestGiteaDeleteBranch(t *testing.T) {
+	deletePath := "DELETE " + testGiteaRepoPath("branches/revise%2Fmy-post-1")
+
+	client := newFakeGitea(t, map[string]http.HandlerFunc{deletePath: respondStatus(http.StatusNotFound, "branch doesn't exist")})
+	if err := client.DeleteBranch("revise/my-post-1"); err != nil {
+		t.Errorf("expected an already-deleted branch to be fine, got: %v", err)
+	}
+
+	client = newFakeGitea(t, map[string]http.HandlerFunc{deletePath: respondStatus(http.StatusInternalServerError, "internal error")})
+	if err := client.DeleteBranch("revise/my-post-1"); !isForgeError(err, ForgeErrorAPI) {
+		t.Errorf("expected an API error, got: %v", err)
+	}
+}
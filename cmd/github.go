@@ -0,0 +1,476 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/kurtosis-tech/stacktrace"
+)
+
+const (
+	GitHubTokenEnvVar  = "GITHUB_TOKEN"
+	GitHubAPIURLEnvVar = "GITHUB_API_URL"
+
+	DefaultGitHubHost   = "github.com"
+	DefaultGitHubAPIURL = "https://api.github.com"
+
+	// The most GitHub returns per page
+	gitHubPageSize = 100
+)
+
+type gitHubPullRequest struct {
+	Number   int    `json:"number"`
+	NodeID   string `json:"node_id"`
+	HTMLURL  string `json:"html_url"`
+	State    string `json:"state"`
+	Draft    bool   `json:"draft"`
+	Merged   bool   `json:"merged"`
+	MergedAt string `json:"merged_at"`
+	Head     struct {
+		Ref string `json:"ref"`
+		SHA string `json:"sha"`
+	} `json:"head"`
+	Base struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+}
+
+type gitHubCombinedStatus struct {
+	Statuses []struct {
+		Context   string `json:"context"`
+		State     string `json:"state"`
+		TargetURL string `json:"target_url"`
+	} `json:"statuses"`
+}
+
+type gitHubCheckRuns struct {
+	CheckRuns []struct {
+		Name       string `json:"name"`
+		Status     string `json:"status"`
+		Conclusion string `json:"conclusion"`
+		HTMLURL    string `json:"html_url"`
+	} `json:"check_runs"`
+}
+
+type gitHubBranch struct {
+	Protection struct {
+		RequiredStatusChecks struct {
+			Contexts []string `json:"contexts"`
+		} `json:"required_status_checks"`
+	} `json:"protection"`
+}
+
+type gitHubReview struct {
+	State       string    `json:"state"`
+	Body        string    `json:"body"`
+	SubmittedAt time.Time `json:"submitted_at"`
+	User        struct {
+		Login string `json:"login"`
+	} `json:"user"`
+}
+
+// gitHubComment is either a comment on a line of a PR's diff or a comment on the PR's conversation
+type gitHubComment struct {
+	Body      string    `json:"body"`
+	Path      string    `json:"path"`
+	Line      *int      `json:"line"`
+	CreatedAt time.Time `json:"created_at"`
+	User      struct {
+		Login string `json:"login"`
+	} `json:"user"`
+}
+
+type gitHubGraphQLResponse struct {
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// GitHubClient talks to the GitHub REST API for a single repository, plus the GraphQL API for the few
+// operations (like enabling auto-merge) that the REST API doesn't support
+type GitHubClient struct {
+	api     *forgeAPIClient
+	graphQL *forgeAPIClient
+	owner   string
+	repo    string
+}
+
+func NewGitHubClient(apiURL string, token string, owner string, repo string) *GitHubClient {
+	headers := map[string]string{
+		"Accept":               "application/vnd.github+json",
+		"X-GitHub-Api-Version": "2022-11-28",
+	}
+	if token != "" {
+		headers["Authorization"] = "Bearer " + token
+	}
+
+	// GitHub Enterprise serves GraphQL at '/api/graphql' rather than under the REST API's '/api/v3'
+	graphQLURL := strings.TrimSuffix(apiURL, "/") + "/graphql"
+	if strings.HasSuffix(strings.TrimSuffix(apiURL, "/"), "/api/v3") {
+		graphQLURL = strings.TrimSuffix(strings.TrimSuffix(apiURL, "/"), "/v3") + "/graphql"
+	}
+
+	return &GitHubClient{
+		api:     newForgeAPIClient("GitHub", apiURL, headers),
+		graphQL: newForgeAPIClient("GitHub", graphQLURL, headers),
+		owner:   owner,
+		repo:    repo,
+	}
+}
+
+func newGitHubClientForRepo(host string, owner string, repo string) (*GitHubClient, error) {
+	apiURL := getConfigValue(GitHubAPIURLEnvVar)
+	if apiURL == "" {
+		apiURL = DefaultGitHubAPIURL
+		if host != DefaultGitHubHost {
+			// GitHub Enterprise serves its API under the instance's own host
+			apiURL = fmt.Sprintf("https://%s/api/v3", host)
+		}
+	}
+
+	// Fall back to the token the gh CLI is logged in with
+	token, err := getForgeToken("GitHub", GitHubTokenEnvVar, "gh", "auth", "token", "--hostname", host)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to get a GitHub token")
+	}
+
+	return NewGitHubClient(apiURL, token, owner, repo), nil
+}
+
+func (client *GitHubClient) Name() string {
+	return "GitHub"
+}
+
+func (client *GitHubClient) FindMergeRequest(branch string) (*MergeRequest, error) {
+	query := url.Values{}
+	query.Set("head", fmt.Sprintf("%s:%s", client.owner, branch))
+	query.Set("state", "all")
+
+	var pullRequests []gitHubPullRequest
+	if err := client.api.doRequest(http.MethodGet, client.repoPath("pulls")+"?"+query.Encode(), nil, &pullRequests); err != nil {
+		return nil, stacktrace.Propagate(err, "failed to list PRs for branch '%s'", branch)
+	}
+
+	for _, pullRequest := range pullRequests {
+		// The list endpoint doesn't populate 'merged', only 'merged_at'
+		pullRequest.Merged = pullRequest.MergedAt != ""
+		mergeRequest := pullRequest.toMergeRequest()
+		if mergeRequest.State != MergeRequestClosed {
+			return mergeRequest, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (client *GitHubClient) GetMergeRequest(number int) (*MergeRequest, error) {
+	var pullRequest gitHubPullRequest
+	if err := client.api.doRequest(http.MethodGet, client.repoPath(fmt.Sprintf("pulls/%d", number)), nil, &pullRequest); err != nil {
+		return nil, stacktrace.Propagate(err, "failed to get PR #%d", number)
+	}
+	return pullRequest.toMergeRequest(), nil
+}
+
+func (client *GitHubClient) CreateMergeRequest(branch string, baseBranch string, options MergeRequestOptions) (*MergeRequest, error) {
+	request := map[string]interface{}{
+		"title": options.Title,
+		"head":  branch,
+		"base":  baseBranch,
+		"body":  options.Body,
+		"draft": options.Draft,
+	}
+
+	var pullRequest gitHubPullRequest
+	if err := client.api.doRequest(http.MethodPost, client.repoPath("pulls"), request, &pullRequest); err != nil {
+		return nil, stacktrace.Propagate(err, "failed to create PR for branch '%s'", branch)
+	}
+
+	// PRs are issues as far as labels are concerned
+	if len(options.Labels) > 0 {
+		labelsRequest := map[string]interface{}{
+			"labels": options.Labels,
+		}
+		if err := client.api.doRequest(http.MethodPost, client.repoPath(fmt.Sprintf("issues/%d/labels", pullRequest.Number)), labelsRequest, nil); err != nil {
+			return nil, stacktrace.Propagate(err, "failed to add labels to PR #%d", pullRequest.Number)
+		}
+	}
+
+	if len(options.Reviewers) > 0 {
+		if err := client.RequestReviewers(pullRequest.Number, options.Reviewers); err != nil {
+			return nil, err
+		}
+	}
+
+	return pullRequest.toMergeRequest(), nil
+}
+
+func (client *GitHubClient) MarkReadyForReview(number int) error {
+	nodeID, err := client.getPullRequestNodeID(number)
+	if err != nil {
+		return err
+	}
+
+	query := `mutation($id: ID!) { markPullRequestReadyForReview(input: {pullRequestId: $id}) { clientMutationId } }`
+	if err := client.doGraphQL(query, map[string]interface{}{"id": nodeID}); err != nil {
+		return stacktrace.Propagate(err, "failed to mark PR #%d as ready for review", number)
+	}
+	return nil
+}
+
+func (client *GitHubClient) GetChecks(mergeRequest *MergeRequest) ([]StatusCheck, error) {
+	var checks []StatusCheck
+
+	// Checks are reported both as legacy commit statuses and as check runs
+	var combinedStatus gitHubCombinedStatus
+	if err := client.api.doRequest(http.MethodGet, client.repoPath(fmt.Sprintf("commits/%s/status", mergeRequest.HeadSHA)), nil, &combinedStatus); err != nil {
+		return nil, stacktrace.Propagate(err, "failed to get commit statuses for '%s'", mergeRequest.HeadSHA)
+	}
+	for _, commitStatus := range combinedStatus.Statuses {
+		checks = append(checks, StatusCheck{
+			Context:  commitStatus.Context,
+			State:    checkStateFromForgeState(commitStatus.State),
+			RawState: strings.ToUpper(commitStatus.State),
+			URL:      commitStatus.TargetURL,
+		})
+	}
+
+	var checkRuns gitHubCheckRuns
+	if err := client.api.doRequest(http.MethodGet, client.repoPath(fmt.Sprintf("commits/%s/check-runs", mergeRequest.HeadSHA)), nil, &checkRuns); err != nil {
+		return nil, stacktrace.Propagate(err, "failed to get check runs for '%s'", mergeRequest.HeadSHA)
+	}
+	for _, checkRun := range checkRuns.CheckRuns {
+		// Check runs only have a conclusion once they've completed
+		state := CheckPending
+		rawState := checkRun.Status
+		if checkRun.Status == "completed" {
+			state = checkStateFromForgeState(checkRun.Conclusion)
+			rawState = checkRun.Conclusion
+		}
+		checks = append(checks, StatusCheck{
+			Context:  checkRun.Name,
+			State:    state,
+			RawState: strings.ToUpper(rawState),
+			URL:      checkRun.HTMLURL,
+		})
+	}
+
+	// The base branch's protection rules determine which checks must pass
+	requiredChecks, err := client.GetRequiredChecks(mergeRequest.BaseBranch)
+	if err != nil {
+		return nil, err
+	}
+
+	return markRequiredChecks(checks, requiredChecks), nil
+}
+
+func (client *GitHubClient) GetRequiredChecks(branch string) ([]string, error) {
+	var gitHubBranch gitHubBranch
+	if err := client.api.doRequest(http.MethodGet, client.repoPath("branches/"+branch), nil, &gitHubBranch); err != nil {
+		return nil, stacktrace.Propagate(err, "failed to get protection rules for branch '%s'", branch)
+	}
+	return gitHubBranch.Protection.RequiredStatusChecks.Contexts, nil
+}
+
+func (client *GitHubClient) GetReviews(number int) ([]Review, error) {
+	var gitHubReviews []gitHubReview
+	if err := client.api.doRequest(http.MethodGet, client.repoPath(fmt.Sprintf("pulls/%d/reviews", number)), nil, &gitHubReviews); err != nil {
+		return nil, stacktrace.Propagate(err, "failed to get reviews for PR #%d", number)
+	}
+
+	var reviews []Review
+	for _, review := range gitHubReviews {
+		reviews = append(reviews, Review{
+			Author: review.User.Login,
+			State:  review.State,
+		})
+	}
+	return reviews, nil
+}
+
+func (client *GitHubClient) RequestReviewers(number int, reviewers []string) error {
+	request := map[string]interface{}{
+		"reviewers": reviewers,
+	}
+	if err := client.api.doRequest(http.MethodPost, client.repoPath(fmt.Sprintf("pulls/%d/requested_reviewers", number)), request, nil); err != nil {
+		return stacktrace.Propagate(err, "failed to request reviewers for PR #%d", number)
+	}
+	return nil
+}
+
+// GetReviewComments returns the comments on lines of the PR's diff, the comments on its conversation, and
+// the bodies of its reviews
+func (client *GitHubClient) GetReviewComments(number int) ([]ReviewComment, error) {
+	var comments []ReviewComment
+
+	lineComments, err := client.listComments(fmt.Sprintf("pulls/%d/comments", number))
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to get review comments for PR #%d", number)
+	}
+	for _, lineComment := range lineComments {
+		// Comments on lines that have since changed no longer have a line
+		line := 0
+		if lineComment.Line != nil {
+			line = *lineComment.Line
+		}
+		comments = append(comments, ReviewComment{
+			Author:    lineComment.User.Login,
+			Body:      lineComment.Body,
+			Path:      lineComment.Path,
+			Line:      line,
+			CreatedAt: lineComment.CreatedAt,
+		})
+	}
+
+	conversationComments, err := client.listComments(fmt.Sprintf("issues/%d/comments", number))
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to get comments for PR #%d", number)
+	}
+	for _, conversationComment := range conversationComments {
+		comments = append(comments, ReviewComment{
+			Author:    conversationComment.User.Login,
+			Body:      conversationComment.Body,
+			CreatedAt: conversationComment.CreatedAt,
+		})
+	}
+
+	var gitHubReviews []gitHubReview
+	if err := client.api.doRequest(http.MethodGet, client.repoPath(fmt.Sprintf("pulls/%d/reviews", number)), nil, &gitHubReviews); err != nil {
+		return nil, stacktrace.Propagate(err, "failed to get reviews for PR #%d", number)
+	}
+	for _, review := range gitHubReviews {
+		if strings.TrimSpace(review.Body) == "" {
+			continue
+		}
+		comments = append(comments, ReviewComment{
+			Author:    review.User.Login,
+			Body:      review.Body,
+			CreatedAt: review.SubmittedAt,
+		})
+	}
+
+	return comments, nil
+}
+
+// listComments gets every page of a comments endpoint
+func (client *GitHubClient) listComments(path string) ([]gitHubComment, error) {
+	var comments []gitHubComment
+	for page := 1; ; page++ {
+		query := url.Values{}
+		query.Set("per_page", fmt.Sprintf("%d", gitHubPageSize))
+		query.Set("page", fmt.Sprintf("%d", page))
+
+		var pageComments []gitHubComment
+		if err := client.api.doRequest(http.MethodGet, client.repoPath(path)+"?"+query.Encode(), nil, &pageComments); err != nil {
+			return nil, err
+		}
+		comments = append(comments, pageComments...)
+
+		if len(pageComments) < gitHubPageSize {
+			return comments, nil
+		}
+	}
+}
+
+func (client *GitHubClient) Merge(number int, mergeMethod string) error {
+	request := map[string]interface{}{
+		"merge_method": mergeMethod,
+	}
+
+	if err := client.api.doRequest(http.MethodPut, client.repoPath(fmt.Sprintf("pulls/%d/merge", number)), request, nil); err != nil {
+		return stacktrace.Propagate(err, "failed to merge PR #%d", number)
+	}
+	return nil
+}
+
+func (client *GitHubClient) EnableAutoMerge(number int, mergeMethod string) error {
+	nodeID, err := client.getPullRequestNodeID(number)
+	if err != nil {
+		return err
+	}
+
+	query := `mutation($id: ID!, $method: PullRequestMergeMethod!) { enablePullRequestAutoMerge(input: {pullRequestId: $id, mergeMethod: $method}) { clientMutationId } }`
+	variables := map[string]interface{}{
+		"id":     nodeID,
+		"method": strings.ToUpper(mergeMethod),
+	}
+	if err := client.doGraphQL(query, variables); err != nil {
+		return stacktrace.Propagate(err, "failed to enable auto-merge for PR #%d (is auto-merge allowed in the repo's settings?)", number)
+	}
+	return nil
+}
+
+func (client *GitHubClient) DeleteBranch(branch string) error {
+	err := client.api.doRequest(http.MethodDelete, client.repoPath("git/refs/heads/"+branch), nil, nil)
+
+	// GitHub answers with a 422 rather than a 404 if the branch was already deleted
+	if err != nil && !isForgeError(err, ForgeErrorNotFound) && !isGitHubMissingRefError(err) {
+		return stacktrace.Propagate(err, "failed to delete remote branch '%s'", branch)
+	}
+	return nil
+}
+
+// isGitHubMissingRefError returns true if the error is GitHub saying that a ref doesn't exist
+func isGitHubMissingRefError(err error) bool {
+	forgeErr, ok := stacktrace.RootCause(err).(*ForgeError)
+	return ok && forgeErr.StatusCode == http.StatusUnprocessableEntity && strings.Contains(forgeErr.Message, "Reference does not exist")
+}
+
+// getPullRequestNodeID returns the PR's GraphQL ID, which GraphQL mutations use instead of its number
+func (client *GitHubClient) getPullRequestNodeID(number int) (string, error) {
+	var pullRequest gitHubPullRequest
+	if err := client.api.doRequest(http.MethodGet, client.repoPath(fmt.Sprintf("pulls/%d", number)), nil, &pullRequest); err != nil {
+		return "", stacktrace.Propagate(err, "failed to get PR #%d", number)
+	}
+	return pullRequest.NodeID, nil
+}
+
+// doGraphQL runs a GraphQL mutation, turning any errors GraphQL reports (which come back with a 200 status)
+// into a *ForgeError
+func (client *GitHubClient) doGraphQL(query string, variables map[string]interface{}) error {
+	request := map[string]interface{}{
+		"query":     query,
+		"variables": variables,
+	}
+
+	var response gitHubGraphQLResponse
+	if err := client.graphQL.doRequest(http.MethodPost, "", request, &response); err != nil {
+		return stacktrace.Propagate(err, "GraphQL request failed")
+	}
+	if len(response.Errors) > 0 {
+		var messages []string
+		for _, graphQLErr := range response.Errors {
+			messages = append(messages, graphQLErr.Message)
+		}
+		return &ForgeError{
+			Forge:   "GitHub",
+			Kind:    ForgeErrorAPI,
+			Message: strings.Join(messages, "; "),
+		}
+	}
+	return nil
+}
+
+func (client *GitHubClient) repoPath(path string) string {
+	return fmt.Sprintf("/repos/%s/%s/%s", client.owner, client.repo, path)
+}
+
+func (pullRequest gitHubPullRequest) toMergeRequest() *MergeRequest {
+	state := MergeRequestOpen
+	if pullRequest.Merged {
+		state = MergeRequestMerged
+	} else if pullRequest.State != "open" {
+		state = MergeRequestClosed
+	}
+
+	return &MergeRequest{
+		Number:     pullRequest.Number,
+		URL:        pullRequest.HTMLURL,
+		State:      state,
+		HeadSHA:    pullRequest.Head.SHA,
+		BaseBranch: pullRequest.Base.Ref,
+		Draft:      pullRequest.Draft,
+	}
+}
@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+const (
+	testGitHubOwner = "owner"
+	testGitHubRepo  = "repo"
+	testGitHubToken = "test-token"
+)
+
+// newFakeGitHub starts a fake GitHub API that serves the given handlers, keyed by 'METHOD /path'
+func newFakeGitHub(t *testing.T, handlers map[string]http.HandlerFunc) *GitHubClient {
+	serverURL := newFakeForge(t, "Authorization", "Bearer "+testGitHubToken, handlers)
+	return NewGitHubClient(serverURL, testGitHubToken, testGitHubOwner, testGitHubRepo)
+}
+
+func testGitHubRepoPath(path string) string {
+	return fmt.Sprintf("/repos/%s/%s/%s", testGitHubOwner, testGitHubRepo, path)
+}
+
+func TestGitHubDeleteBranch(t *testing.T) {
+	deletePath := "DELETE " + testGitHubRepoPath("git/refs/heads/my-post")
+	testCases := []struct {
+		name      string
+		handler   http.HandlerFunc
+		expectErr bool
+		errKind   ForgeErrorKindEnum
+	}{
+		{name: "deleted", handler: respondNoContent},
+		{name: "not found", handler: respondStatus(http.StatusNotFound, "Not Found")},
+		{name: "already deleted", handler: respondStatus(http.StatusUnprocessableEntity, "Reference does not exist")},
+		{name: "other validation error", handler: respondStatus(http.StatusUnprocessableEntity, "Validation Failed"), expectErr: true, errKind: ForgeErrorAPI},
+		{name: "server error", handler: respondStatus(http.StatusInternalServerError, "Server Error"), expectErr: true, errKind: ForgeErrorAPI},
+		{name: "forbidden", handler: respondStatus(http.StatusForbidden, "Resource not accessible by integration"), expectErr: true, errKind: ForgeErrorAuth},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := newFakeGitHub(t, map[string]http.HandlerFunc{deletePath: testCase.handler})
+			err := client.DeleteBranch("my-post")
+			if !testCase.expectErr {
+				if err != nil {
+					t.Fatalf("expected no error, got: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected an error, got none")
+			}
+			if !isForgeError(err, testCase.errKind) {
+				t.Errorf("expected a forge error of kind %d, got: %v", testCase.errKind, err)
+			}
+		})
+	}
+}
+
+func TestGitHubGetMergeRequestErrors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		handler http.HandlerFunc
+		errKind ForgeErrorKindEnum
+	}{
+		{name: "not found", handler: respondStatus(http.StatusNotFound, "Not Found"), errKind: ForgeErrorNotFound},
+		{name: "bad credentials", handler: respondStatus(http.StatusUnauthorized, "Bad credentials"), errKind: ForgeErrorAuth},
+		{name: "server error", handler: respondStatus(http.StatusBadGateway, "Bad Gateway"), errKind: ForgeErrorAPI},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := newFakeGitHub(t, map[string]http.HandlerFunc{
+				"GET " + testGitHubRepoPath("pulls/7"): testCase.handler,
+			})
+			_, err := client.GetMergeRequest(7)
+			if !isForgeError(err, testCase.errKind) {
+				t.Errorf("expected a forge error of kind %d, got: %v", testCase.errKind, err)
+			}
+		})
+	}
+}
+
+func TestGitHubNetworkError(t *testing.T) {
+	client := NewGitHubClient(newClosedServerURL(), testGitHubToken, testGitHubOwner, testGitHubRepo)
+
+	_, err := client.GetMergeRequest(7)
+	if !isForgeError(err, ForgeErrorNetwork) {
+		t.Errorf("expected a network error, got: %v", err)
+	}
+}
+
+func TestGitHubFindMergeRequest(t *testing.T) {
+	client := newFakeGitHub(t, map[string]http.HandlerFunc{
+		"GET " + testGitHubRepoPath("pulls"): func(writer http.ResponseWriter, request *http.Request) {
+			if head := request.URL.Query().Get("head"); head != testGitHubOwner+":my-post" {
+				t.Errorf("expected head 'owner:my-post', got '%s'", head)
+			}
+			// The list endpoint reports merged PRs as closed, with a merge time
+			respondJSON(writer, http.StatusOK, []map[string]interface{}{
+				{"number": 3, "state": "closed", "merged_at": nil},
+				{"number": 2, "state": "closed", "merged_at": "2024-01-01T00:00:00Z", "html_url": "https://github.com/owner/repo/pull/2"},
+			})
+		},
+	})
+
+	mergeRequest, err := client.FindMergeRequest("my-post")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if mergeRequest == nil || mergeRequest.Number != 2 || mergeRequest.State != MergeRequestMerged {
+		t.Errorf("expected merged PR #2, got: %+v", mergeRequest)
+	}
+}
+
+func TestGitHubGetReviewCommentsPaginates(t *testing.T) {
+	const lineCommentCount = gitHubPageSize + 20
+	var requestedPages []string
+
+	client := newFakeGitHub(t, map[string]http.HandlerFunc{
+		"GET " + testGitHubRepoPath("pulls/7/comments"): func(writer http.ResponseWriter, request *http.Request) {
+			requestedPages = append(requestedPages, request.URL.Query().Get("page"))
+			respondPage(writer, request, lineCommentCount, gitHubPageSize, func(idx int) map[string]interface{} {
+				return map[string]interface{}{"body": fmt.Sprintf("comment %d", idx), "path": "my-post/post.md", "line": idx + 1}
+			})
+		},
+		"GET " + testGitHubRepoPath("issues/7/comments"): func(writer http.ResponseWriter, request *http.Request) {
+			respondJSON(writer, http.StatusOK, []map[string]interface{}{
+				{"body": "Looks good", "user": map[string]string{"login": "editor"}},
+			})
+		},
+		"GET " + testGitHubRepoPath("pulls/7/reviews"): func(writer http.ResponseWriter, request *http.Request) {
+			respondJSON(writer, http.StatusOK, []map[string]interface{}{
+				{"state": "APPROVED", "body": ""},
+				{"state": "COMMENTED", "body": "One nit"},
+			})
+		},
+	})
+
+	comments, err := client.GetReviewComments(7)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(requestedPages) != 2 {
+		t.Errorf("expected 2 pages of line comments to be requested, got: %v", requestedPages)
+	}
+	// Every line comment, the conversation comment, and the review with a body
+	if expected := lineCommentCount + 2; len(comments) != expected {
+		t.Fatalf("expected %d comments, got %d", expected, len(comments))
+	}
+	if last := comments[lineCommentCount-1]; last.Line != lineCommentCount || last.Path != "my-post/post.md" {
+		t.Errorf("expected the last line comment on line %d, got: %+v", lineCommentCount, last)
+	}
+}
@@ -0,0 +1,456 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/kurtosis-tech/stacktrace"
+)
+
+const (
+	GitLabTokenEnvVar  = "GITLAB_TOKEN"
+	GitLabAPIURLEnvVar = "GITLAB_API_URL"
+
+	// GitLab marks merge requests as drafts by their title
+	gitLabDraftPrefix = "Draft: "
+
+	// The most GitLab returns per page
+	gitLabPageSize = 100
+
+	// Rebases happen in the background, so merging waits for them to finish
+	defaultGitLabRebasePollInterval = 2 * time.Second
+	gitLabRebaseTimeout             = 5 * time.Minute
+)
+
+// Matches every title prefix that GitLab treats as marking a draft
+var gitLabDraftPrefixRegex = regexp.MustCompile(`(?i)^\s*(\[draft\]|\(draft\)|draft:)\s*`)
+
+type gitLabMergeRequest struct {
+	IID          int    `json:"iid"`
+	WebURL       string `json:"web_url"`
+	Title        string `json:"title"`
+	State        string `json:"state"`
+	Draft        bool   `json:"draft"`
+	SHA          string `json:"sha"`
+	TargetBranch string `json:"target_branch"`
+	Reviewers    []struct {
+		ID int `json:"id"`
+	} `json:"reviewers"`
+	HeadPipeline *struct {
+		ID int `json:"id"`
+	} `json:"head_pipeline"`
+
+	// Only returned when asked for with 'include_rebase_in_progress'
+	RebaseInProgress bool   `json:"rebase_in_progress"`
+	MergeError       string `json:"merge_error"`
+}
+
+type gitLabJob struct {
+	Name         string `json:"name"`
+	Status       string `json:"status"`
+	WebURL       string `json:"web_url"`
+	AllowFailure bool   `json:"allow_failure"`
+}
+
+type gitLabUser struct {
+	ID int `json:"id"`
+}
+
+type gitLabDiscussion struct {
+	Notes []struct {
+		Body      string    `json:"body"`
+		System    bool      `json:"system"`
+		CreatedAt time.Time `json:"created_at"`
+		Author    struct {
+			Username string `json:"username"`
+		} `json:"author"`
+		Position *struct {
+			NewPath string `json:"new_path"`
+			NewLine int    `json:"new_line"`
+		} `json:"position"`
+	} `json:"notes"`
+}
+
+type gitLabApprovals struct {
+	ApprovedBy []struct {
+		User struct {
+			Username string `json:"username"`
+		} `json:"user"`
+	} `json:"approved_by"`
+}
+
+// GitLabClient talks to the GitLab REST API for a single project
+type GitLabClient struct {
+	api *forgeAPIClient
+
+	// URL-escaped 'namespace/project' path, which GitLab accepts anywhere a project ID is expected
+	projectID string
+
+	rebasePollInterval time.Duration
+}
+
+func NewGitLabClient(apiURL string, token string, namespace string, project string) *GitLabClient {
+	headers := map[string]string{}
+	if token != "" {
+		headers["PRIVATE-TOKEN"] = token
+	}
+
+	return &GitLabClient{
+		api:                newForgeAPIClient("GitLab", apiURL, headers),
+		projectID:          url.PathEscape(namespace + "/" + project),
+		rebasePollInterval: defaultGitLabRebasePollInterval,
+	}
+}
+
+func newGitLabClientForRepo(host string, namespace string, project string) (*GitLabClient, error) {
+	apiURL := getConfigValue(GitLabAPIURLEnvVar)
+	if apiURL == "" {
+		apiURL = fmt.Sprintf("https://%s/api/v4", host)
+	}
+
+	token, err := getForgeToken("GitLab", GitLabTokenEnvVar)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to get a GitLab token")
+	}
+
+	return NewGitLabClient(apiURL, token, namespace, project), nil
+}
+
+func (client *GitLabClient) Name() string {
+	return "GitLab"
+}
+
+func (client *GitLabClient) FindMergeRequest(branch string) (*MergeRequest, error) {
+	query := url.Values{}
+	query.Set("source_branch", branch)
+
+	var gitLabMergeRequests []gitLabMergeRequest
+	if err := client.api.doRequest(http.MethodGet, client.projectPath("merge_requests")+"?"+query.Encode(), nil, &gitLabMergeRequests); err != nil {
+		return nil, stacktrace.Propagate(err, "failed to list merge requests for branch '%s'", branch)
+	}
+
+	for _, gitLabMergeRequest := range gitLabMergeRequests {
+		mergeRequest := gitLabMergeRequest.toMergeRequest()
+		if mergeRequest.State != MergeRequestClosed {
+			return mergeRequest, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (client *GitLabClient) GetMergeRequest(number int) (*MergeRequest, error) {
+	gitLabMergeRequest, err := client.getGitLabMergeRequest(number)
+	if err != nil {
+		return nil, err
+	}
+	return gitLabMergeRequest.toMergeRequest(), nil
+}
+
+func (client *GitLabClient) CreateMergeRequest(branch string, baseBranch string, options MergeRequestOptions) (*MergeRequest, error) {
+	title := options.Title
+	if options.Draft {
+		title = gitLabDraftPrefix + title
+	}
+
+	request := map[string]interface{}{
+		"source_branch": branch,
+		"target_branch": baseBranch,
+		"title":         title,
+		"description":   options.Body,
+	}
+	if len(options.Labels) > 0 {
+		request["labels"] = strings.Join(options.Labels, ",")
+	}
+
+	// Reviewers are requested by user ID rather than username
+	if len(options.Reviewers) > 0 {
+		reviewerIDs, err := client.getUserIDs(options.Reviewers)
+		if err != nil {
+			return nil, err
+		}
+		request["reviewer_ids"] = reviewerIDs
+	}
+
+	var gitLabMergeRequest gitLabMergeRequest
+	if err := client.api.doRequest(http.MethodPost, client.projectPath("merge_requests"), request, &gitLabMergeRequest); err != nil {
+		return nil, stacktrace.Propagate(err, "failed to create merge request for branch '%s'", branch)
+	}
+	return gitLabMergeRequest.toMergeRequest(), nil
+}
+
+func (client *GitLabClient) MarkReadyForReview(number int) error {
+	gitLabMergeRequest, err := client.getGitLabMergeRequest(number)
+	if err != nil {
+		return err
+	}
+
+	request := map[string]interface{}{
+		"title": gitLabDraftPrefixRegex.ReplaceAllString(gitLabMergeRequest.Title, ""),
+	}
+	if err := client.api.doRequest(http.MethodPut, client.projectPath(fmt.Sprintf("merge_requests/%d", number)), request, nil); err != nil {
+		return stacktrace.Propagate(err, "failed to mark merge request !%d as ready", number)
+	}
+	return nil
+}
+
+func (client *GitLabClient) GetChecks(mergeRequest *MergeRequest) ([]StatusCheck, error) {
+	// The head pipeline is only returned when getting a single merge request
+	gitLabMergeRequest, err := client.getGitLabMergeRequest(mergeRequest.Number)
+	if err != nil {
+		return nil, err
+	}
+	if gitLabMergeRequest.HeadPipeline == nil {
+		return nil, nil
+	}
+
+	jobs, err := client.listPipelineJobs(gitLabMergeRequest.HeadPipeline.ID)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to get jobs for pipeline %d", gitLabMergeRequest.HeadPipeline.ID)
+	}
+
+	// GitLab has no branch-level required checks; instead, jobs that are allowed to fail are optional
+	var checks []StatusCheck
+	for _, job := range jobs {
+		checks = append(checks, StatusCheck{
+			Context:  job.Name,
+			State:    checkStateFromForgeState(job.Status),
+			RawState: strings.ToUpper(job.Status),
+			Required: !job.AllowFailure,
+			URL:      job.WebURL,
+		})
+	}
+	return checks, nil
+}
+
+// listPipelineJobs gets every page of the pipeline's jobs
+func (client *GitLabClient) listPipelineJobs(pipelineID int) ([]gitLabJob, error) {
+	var jobs []gitLabJob
+	for page := 1; ; page++ {
+		query := url.Values{}
+		query.Set("per_page", fmt.Sprintf("%d", gitLabPageSize))
+		query.Set("page", fmt.Sprintf("%d", page))
+
+		var pageJobs []gitLabJob
+		jobsPath := client.projectPath(fmt.Sprintf("pipelines/%d/jobs", pipelineID)) + "?" + query.Encode()
+		if err := client.api.doRequest(http.MethodGet, jobsPath, nil, &pageJobs); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, pageJobs...)
+
+		if len(pageJobs) < gitLabPageSize {
+			return jobs, nil
+		}
+	}
+}
+
+// GetRequiredChecks returns nothing, since GitLab has no branch-level required checks; whether a job is
+// required is instead determined by whether it's allowed to fail
+func (client *GitLabClient) GetRequiredChecks(branch string) ([]string, error) {
+	return nil, nil
+}
+
+// GetReviews returns the merge request's approvals, since GitLab doesn't have GitHub-style reviews
+func (client *GitLabClient) GetReviews(number int) ([]Review, error) {
+	var approvals gitLabApprovals
+	if err := client.api.doRequest(http.MethodGet, client.projectPath(fmt.Sprintf("merge_requests/%d/approvals", number)), nil, &approvals); err != nil {
+		return nil, stacktrace.Propagate(err, "failed to get approvals for merge request !%d", number)
+	}
+
+	var reviews []Review
+	for _, approval := range approvals.ApprovedBy {
+		reviews = append(reviews, Review{
+			Author: approval.User.Username,
+			State:  "APPROVED",
+		})
+	}
+	return reviews, nil
+}
+
+// RequestReviewers adds the users to the merge request's reviewers, keeping the existing ones
+func (client *GitLabClient) RequestReviewers(number int, reviewers []string) error {
+	gitLabMergeRequest, err := client.getGitLabMergeRequest(number)
+	if err != nil {
+		return err
+	}
+
+	newReviewerIDs, err := client.getUserIDs(reviewers)
+	if err != nil {
+		return err
+	}
+	var reviewerIDs []int
+	for _, reviewer := range gitLabMergeRequest.Reviewers {
+		reviewerIDs = append(reviewerIDs, reviewer.ID)
+	}
+	reviewerIDs = append(reviewerIDs, newReviewerIDs...)
+
+	request := map[string]interface{}{
+		"reviewer_ids": reviewerIDs,
+	}
+	if err := client.api.doRequest(http.MethodPut, client.projectPath(fmt.Sprintf("merge_requests/%d", number)), request, nil); err != nil {
+		return stacktrace.Propagate(err, "failed to request reviewers for merge request !%d", number)
+	}
+	return nil
+}
+
+// GetReviewComments returns the notes in the merge request's discussions, leaving out the ones GitLab
+// generates itself (e.g. "added 1 commit")
+func (client *GitLabClient) GetReviewComments(number int) ([]ReviewComment, error) {
+	var comments []ReviewComment
+	for page := 1; ; page++ {
+		query := url.Values{}
+		query.Set("per_page", fmt.Sprintf("%d", gitLabPageSize))
+		query.Set("page", fmt.Sprintf("%d", page))
+
+		var discussions []gitLabDiscussion
+		discussionsPath := client.projectPath(fmt.Sprintf("merge_requests/%d/discussions", number)) + "?" + query.Encode()
+		if err := client.api.doRequest(http.MethodGet, discussionsPath, nil, &discussions); err != nil {
+			return nil, stacktrace.Propagate(err, "failed to get discussions for merge request !%d", number)
+		}
+
+		for _, discussion := range discussions {
+			for _, note := range discussion.Notes {
+				if note.System {
+					continue
+				}
+				comment := ReviewComment{
+					Author:    note.Author.Username,
+					Body:      note.Body,
+					CreatedAt: note.CreatedAt,
+				}
+				if note.Position != nil {
+					comment.Path = note.Position.NewPath
+					comment.Line = note.Position.NewLine
+				}
+				comments = append(comments, comment)
+			}
+		}
+
+		if len(discussions) < gitLabPageSize {
+			return comments, nil
+		}
+	}
+}
+
+func (client *GitLabClient) Merge(number int, mergeMethod string) error {
+	return client.merge(number, mergeMethod, false)
+}
+
+func (client *GitLabClient) EnableAutoMerge(number int, mergeMethod string) error {
+	return client.merge(number, mergeMethod, true)
+}
+
+// merge merges the merge request now, or once its pipeline succeeds
+func (client *GitLabClient) merge(number int, mergeMethod string, whenPipelineSucceeds bool) error {
+	// GitLab's merge method is a project setting, but rebasing and squashing can be requested per merge
+	if mergeMethod == MergeMethodRebase {
+		if err := client.api.doRequest(http.MethodPut, client.projectPath(fmt.Sprintf("merge_requests/%d/rebase", number)), nil, nil); err != nil {
+			return stacktrace.Propagate(err, "failed to rebase merge request !%d", number)
+		}
+		if err := client.waitForRebase(number); err != nil {
+			return err
+		}
+	}
+
+	request := map[string]interface{}{
+		"squash":                       mergeMethod == MergeMethodSquash,
+		"merge_when_pipeline_succeeds": whenPipelineSucceeds,
+	}
+	if err := client.api.doRequest(http.MethodPut, client.projectPath(fmt.Sprintf("merge_requests/%d/merge", number)), request, nil); err != nil {
+		return stacktrace.Propagate(err, "failed to merge merge request !%d", number)
+	}
+	return nil
+}
+
+// waitForRebase polls the merge request until the rebase GitLab is doing in the background has finished
+func (client *GitLabClient) waitForRebase(number int) error {
+	deadline := time.Now().Add(gitLabRebaseTimeout)
+	for {
+		var gitLabMergeRequest gitLabMergeRequest
+		mergeRequestPath := client.projectPath(fmt.Sprintf("merge_requests/%d", number)) + "?include_rebase_in_progress=true"
+		if err := client.api.doRequest(http.MethodGet, mergeRequestPath, nil, &gitLabMergeRequest); err != nil {
+			return stacktrace.Propagate(err, "failed to check on the rebase of merge request !%d", number)
+		}
+		if !gitLabMergeRequest.RebaseInProgress {
+			if gitLabMergeRequest.MergeError != "" {
+				return stacktrace.NewError("failed to rebase merge request !%d: %s", number, gitLabMergeRequest.MergeError)
+			}
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return stacktrace.NewError("gave up waiting for merge request !%d to be rebased after %s", number, gitLabRebaseTimeout)
+		}
+		time.Sleep(client.rebasePollInterval)
+	}
+}
+
+func (client *GitLabClient) DeleteBranch(branch string) error {
+	err := client.api.doRequest(http.MethodDelete, client.projectPath("repository/branches/"+url.PathEscape(branch)), nil, nil)
+	if err != nil && !isForgeError(err, ForgeErrorNotFound) {
+		return stacktrace.Propagate(err, "failed to delete remote branch '%s'", branch)
+	}
+	return nil
+}
+
+func (client *GitLabClient) getGitLabMergeRequest(number int) (*gitLabMergeRequest, error) {
+	var gitLabMergeRequest gitLabMergeRequest
+	if err := client.api.doRequest(http.MethodGet, client.projectPath(fmt.Sprintf("merge_requests/%d", number)), nil, &gitLabMergeRequest); err != nil {
+		return nil, stacktrace.Propagate(err, "failed to get merge request !%d", number)
+	}
+	return &gitLabMergeRequest, nil
+}
+
+func (client *GitLabClient) getUserIDs(usernames []string) ([]int, error) {
+	var userIDs []int
+	for _, username := range usernames {
+		userID, err := client.getUserID(username)
+		if err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, nil
+}
+
+func (client *GitLabClient) getUserID(username string) (int, error) {
+	query := url.Values{}
+	query.Set("username", username)
+
+	var users []gitLabUser
+	if err := client.api.doRequest(http.MethodGet, "/users?"+query.Encode(), nil, &users); err != nil {
+		return 0, stacktrace.Propagate(err, "failed to look up GitLab user '%s'", username)
+	}
+	if len(users) == 0 {
+		return 0, stacktrace.NewError("no GitLab user named '%s' exists", username)
+	}
+	return users[0].ID, nil
+}
+
+func (client *GitLabClient) projectPath(path string) string {
+	return fmt.Sprintf("/projects/%s/%s", client.projectID, path)
+}
+
+func (gitLabMergeRequest gitLabMergeRequest) toMergeRequest() *MergeRequest {
+	state := MergeRequestOpen
+	// 'locked' is a brief state while GitLab is merging, so it counts as still open
+	switch gitLabMergeRequest.State {
+	case "merged":
+		state = MergeRequestMerged
+	case "closed":
+		state = MergeRequestClosed
+	}
+
+	return &MergeRequest{
+		Number:     gitLabMergeRequest.IID,
+		URL:        gitLabMergeRequest.WebURL,
+		State:      state,
+		HeadSHA:    gitLabMergeRequest.SHA,
+		BaseBranch: gitLabMergeRequest.TargetBranch,
+		Draft:      gitLabMergeRequest.Draft,
+	}
+}
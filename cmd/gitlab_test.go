@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+const (
+	testGitLabNamespace = "group"
+	testGitLabProject   = "writing"
+	testGitLabToken     = "test-token"
+)
+
+// newFakeGitLab starts a fake GitLab API that serves the given handlers, keyed by 'METHOD /escaped/path'
+func newFakeGitLab(t *testing.T, handlers map[string]http.HandlerFunc) *GitLabClient {
+	serverURL := newFakeForge(t, "PRIVATE-TOKEN", testGitLabToken, handlers)
+	client := NewGitLabClient(serverURL, testGitLabToken, testGitLabNamespace, testGitLabProject)
+	client.rebasePollInterval = time.Millisecond
+	return client
+}
+
+// testGitLabProjectPath is the escaped path of one of the project's endpoints, as the fake server sees it
+func testGitLabProjectPath(path string) string {
+	return fmt.Sprintf("/projects/%s%%2F%s/%s", testGitLabNamespace, testGitLabProject, path)
+}
+
+func TestGitLabGetChecksPaginatesJobs(t *testing.T) {
+	const jobCount = gitLabPageSize + 5
+	var requestedPages []string
+
+	client := newFakeGitLab(t, map[string]http.HandlerFunc{
+		"GET " + testGitLabProjectPath("merge_requests/4"): func(writer http.ResponseWriter, request *http.Request) {
+			respondJSON(writer, http.StatusOK, map[string]interface{}{"iid": 4, "state": "opened", "head_pipeline": map[string]int{"id": 99}})
+		},
+		"GET " + testGitLabProjectPath("pipelines/99/jobs"): func(writer http.ResponseWriter, request *http.Request) {
+			requestedPages = append(requestedPages, request.URL.Query().Get("page"))
+			respondPage(writer, request, jobCount, gitLabPageSize, func(idx int) map[string]interface{} {
+				// The last job is allowed to fail, so it isn't required
+				return map[string]interface{}{"name": fmt.Sprintf("job-%d", idx), "status": "success", "allow_failure": idx == jobCount-1}
+			})
+		},
+	})
+
+	checks, err := client.GetChecks(&MergeRequest{Number: 4})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if len(requestedPages) != 2 {
+		t.Errorf("expected 2 pages of jobs to be requested, got: %v", requestedPages)
+	}
+	if len(checks) != jobCount {
+		t.Fatalf("expected %d checks, got %d", jobCount, len(checks))
+	}
+	if last := checks[jobCount-1]; last.Context != fmt.Sprintf("job-%d", jobCount-1) || last.Required {
+		t.Errorf("expected the last job to be optional, got: %+v", last)
+	}
+	if !checks[0].Required || checks[0].State != CheckPassed {
+		t.Errorf("expected the first job to be a required, successful check, got: %+v", checks[0])
+	}
+}
+
+func TestGitLabRebaseMergeWaitsForRebase(t *testing.T) {
+	rebasePollsLeft := 3
+	merged := false
+
+	client := newFakeGitLab(t, map[string]http.HandlerFunc{
+		"PUT " + testGitLabProjectPath("merge_requests/4/rebase"): func(writer http.ResponseWriter, request *http.Request) {
+			respondJSON(writer, http.StatusAccepted, map[string]bool{"rebase_in_progress": true})
+		},
+		"GET " + testGitLabProjectPath("merge_requests/4"): func(writer http.ResponseWriter, request *http.Request) {
+			if request.URL.Query().Get("include_rebase_in_progress") != "true" {
+				t.Error("expected the rebase's progress to be asked for")
+			}
+			rebasePollsLeft--
+			respondJSON(writer, http.StatusOK, map[string]interface{}{"iid": 4, "state": "opened", "rebase_in_progress": rebasePollsLeft > 0})
+		},
+		"PUT " + testGitLabProjectPath("merge_requests/4/merge"): func(writer http.ResponseWriter, request *http.Request) {
+			if rebasePollsLeft > 0 {
+				t.Error("merged before the rebase finished")
+			}
+			merged = true
+			respondJSON(writer, http.StatusOK, map[string]interface{}{"iid": 4, "state": "merged"})
+		},
+	})
+
+	if err := client.Merge(4, MergeMethodRebase); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !merged {
+		t.Error("expected the merge request to be merged")
+	}
+}
+
+func TestGitLabRebaseFailureStopsMerge(t *testing.T) {
+	client := newFakeGitLab(t, map[string]http.HandlerFunc{
+		"PUT " + testGitLabProjectPath("merge_requests/4/rebase"): func(writer http.ResponseWriter, request *http.Request) {
+			respondJSON(writer, http.StatusAccepted, map[string]bool{"rebase_in_progress": true})
+		},
+		"GET " + testGitLabProjectPath("merge_requests/4"): func(writer http.ResponseWriter, request *http.Request) {
+			respondJSON(writer, http.StatusOK, map[string]interface{}{"iid": 4, "state": "opened", "rebase_in_progress": false, "merge_error": "Rebase failed: conflicts"})
+		},
+	})
+
+	// There's no merge handler, so an attempt to merge would fail the test
+	if err := client.Merge(4, MergeMethodRebase); err == nil {
+		t.Fatal("expected the failed rebase to be reported")
+	}
+}
+
+func TestGitLabMergeRequestStates(t *testing.T) {
+	testCases := map[string]MergeRequestStateEnum{
+		"opened": MergeRequestOpen,
+		"locked": MergeRequestOpen,
+		"merged": MergeRequestMerged,
+		"closed": MergeRequestClosed,
+	}
+	for gitLabState, expectedState := range testCases {
+		mergeRequest := gitLabMergeRequest{State: gitLabState}.toMergeRequest()
+		if mergeRequest.State != expectedState {
+			t.Errorf("expected GitLab state '%s' to be %d, got %d", gitLabState, expectedState, mergeRequest.State)
+		}
+	}
+}
+
+func TestGitLabErrors(t *testing.T) {
+	testCases := []struct {
+		name    string
+		handler http.HandlerFunc
+		errKind ForgeErrorKindEnum
+	}{
+		{name: "not found", handler: respondStatus(http.StatusNotFound, "404 Not found"), errKind: ForgeErrorNotFound},
+		{name: "bad token", handler: respondStatus(http.StatusUnauthorized, "401 Unauthorized"), errKind: ForgeErrorAuth},
+		{name: "server error", handler: respondStatus(http.StatusInternalServerError, "500 Internal Server Error"), errKind: ForgeErrorAPI},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := newFakeGitLab(t, map[string]http.HandlerFunc{
+				"GET " + testGitLabProjectPath("merge_requests/4"): testCase.handler,
+			})
+			_, err := client.GetMergeRequest(4)
+			if !isForgeError(err, testCase.errKind) {
+				t.Errorf("expected a forge error of kind %d, got: %v", testCase.errKind, err)
+			}
+		})
+	}
+
+	t.Run("network", func(t *testing.T) {
+		client := NewGitLabClient(newClosedServerURL(), testGitLabToken, testGitLabNamespace, testGitLabProject)
+		_, err := client.GetMergeRequest(4)
+		if !isForgeError(err, ForgeErrorNetwork) {
+			t.Errorf("expected a network error, got: %v", err)
+		}
+	})
+}
+
+func TestGitLabDeleteBranch(t *testing.T) {
+	deletePath := "DELETE " + testGitLabProjectPath("repository/branches/revise%2Fmy-post-1")
+
+	client := newFakeGitLab(t, map[string]http.HandlerFunc{deletePath: respondStatus(http.StatusNotFound, "404 Branch Not Found")})
+	if err := client.DeleteBranch("revise/my-post-1"); err != nil {
+		t.Errorf("expected an already-deleted branch to be fine, got: %v", err)
+	}
+
+	client = newFakeGitLab(t, map[string]http.HandlerFunc{deletePath: respondStatus(http.StatusForbidden, "403 Forbidden")})
+	if err := client.DeleteBranch("revise/my-post-1"); !isForgeError(err, ForgeErrorAuth) {
+		t.Errorf("expected an auth error, got: %v", err)
+	}
+}
+
+func TestGitLabGetReviewCommentsPaginates(t *testing.T) {
+	const discussionCount = gitLabPageSize + 1
+
+	client := newFakeGitLab(t, map[string]http.HandlerFunc{
+		"GET " + testGitLabProjectPath("merge_requests/4/discussions"): func(writer http.ResponseWriter, request *http.Request) {
+			respondPage(writer, request, discussionCount, gitLabPageSize, func(idx int) map[string]interface{} {
+				return map[string]interface{}{
+					"notes": []map[string]interface{}{
+						{"body": fmt.Sprintf("note %d", idx), "author": map[string]string{"username": "editor"}, "position": map[string]interface{}{"new_path": "my-post/post.md", "new_line": idx + 1}},
+						{"body": "added 1 commit", "system": true},
+					},
+				}
+			})
+		},
+	})
+
+	comments, err := client.GetReviewComments(4)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	// GitLab's own notes are left out
+	if len(comments) != discussionCount {
+		t.Fatalf("expected %d comments, got %d", discussionCount, len(comments))
+	}
+	if last := comments[discussionCount-1]; last.Line != discussionCount || last.Author != "editor" {
+		t.Errorf("expected the last comment on line %d by 'editor', got: %+v", discussionCount, last)
+	}
+}
@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kurtosis-tech/stacktrace"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// Words to write each day across the whole repo; unset means no daily target
+	DailyWordTargetEnvVar = "DAILY_WORD_TARGET"
+
+	TargetWordsFrontMatterKey = "target_words"
+	DeadlineFrontMatterKey    = "deadline"
+
+	goalBarWidth = 30
+)
+
+// PostGoal is an in-progress post's progress towards its word count target
+type PostGoal struct {
+	Entry        PostEntry
+	VersionCount int
+	Words        int
+	TargetWords  int
+	StartedAt    time.Time
+
+	// Zero if the post has no deadline
+	Deadline time.Time
+}
+
+var goalCmd = &cobra.Command{
+	Use:   "goal",
+	Short: "Show progress towards word count targets",
+	Long: `Show a progress bar for each in-progress post (found on the branches not yet merged into main, as
+'opwriting find' does) towards the '` + TargetWordsFrontMatterKey + `' in its front matter. Posts with a '` + DeadlineFrontMatterKey + `' too
+get a warning when they're behind the pace needed to hit the target by then, counting from when the post
+was started. If ` + DailyWordTargetEnvVar + ` is set in ` + EnvFilename + `, also shows how many of the day's words have
+been written (and committed) across the repo.`,
+	Args: cobra.NoArgs,
+	RunE: runGoalCommand,
+}
+
+func runGoalCommand(cmd *cobra.Command, args []string) error {
+	writingRepoPath := os.Getenv(WritingDirEnvVar)
+	if writingRepoPath == "" {
+		return stacktrace.NewError("writing directory not configured: %s environment variable not set", WritingDirEnvVar)
+	}
+	dailyTarget, err := getDailyWordTarget()
+	if err != nil {
+		return err
+	}
+	location, err := getScheduleLocation()
+	if err != nil {
+		return err
+	}
+	now := time.Now().In(location)
+
+	// The history gives when each post was started and how much was written today
+	commits, err := getPostCommits(writingRepoPath)
+	if err != nil {
+		return err
+	}
+	stats := computeWritingStats(commits, map[string]time.Time{}, now, 1)
+	startedAt := map[string]time.Time{}
+	for _, post := range stats.Posts {
+		startedAt[post.Post] = post.StartedAt
+	}
+
+	goals, err := getPostGoals(writingRepoPath, startedAt)
+	if err != nil {
+		return err
+	}
+
+	if dailyTarget > 0 {
+		today := now.Format(statsDateFormat)
+		wordsToday := 0
+		for _, day := range stats.Days {
+			if day.Date == today {
+				wordsToday = day.WordsAdded
+			}
+		}
+		emoji := "⏳"
+		if wordsToday >= dailyTarget {
+			emoji = "✅"
+		}
+		fmt.Printf("%s Today: %s %d/%d words\n\n", emoji, formatProgressBar(wordsToday, dailyTarget), wordsToday, dailyTarget)
+	}
+
+	if len(goals) == 0 {
+		fmt.Println("No posts in progress")
+		return nil
+	}
+	for _, goal := range goals {
+		printPostGoal(goal, now)
+	}
+	return nil
+}
+
+// getPostGoals reads the word counts and targets of the posts on branches that haven't been merged into main. Only the
+// posts a branch changed are in progress; the copies of published posts it inherited from main aren't.
+func getPostGoals(writingRepoPath string, startedAt map[string]time.Time) ([]PostGoal, error) {
+	postEntries, err := collectPostEntries(writingRepoPath)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to collect posts across branches")
+	}
+	versionCounts := map[string]int{}
+	for _, entry := range postEntries {
+		versionCounts[entry.Dir]++
+	}
+
+	var goals []PostGoal
+	for _, entry := range postEntries {
+		// Branches' entries are already limited to the posts they changed
+		if entry.Branch == MainBranchName || entry.Dir == TemplateDirname {
+			continue
+		}
+		content, err := readPostFromBranch(writingRepoPath, entry.Branch, entry.Dir)
+		if err != nil {
+			return nil, err
+		}
+		frontMatter, body, err := parsePost(content)
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "failed to parse post '%s' on branch '%s'", entry.Dir, entry.Branch)
+		}
+
+		goal := PostGoal{
+			Entry:        entry,
+			VersionCount: versionCounts[entry.Dir],
+			Words:        countWords(body),
+			TargetWords:  frontMatter.TargetWords,
+			StartedAt:    startedAt[entry.Dir],
+		}
+		if frontMatter.Deadline != "" {
+			if goal.Deadline, err = parsePublishAt(frontMatter.Deadline); err != nil {
+				return nil, stacktrace.Propagate(err, "invalid %s in post '%s' on branch '%s'", DeadlineFrontMatterKey, entry.Dir, entry.Branch)
+			}
+		}
+		goals = append(goals, goal)
+	}
+	return goals, nil
+}
+
+func printPostGoal(goal PostGoal, now time.Time) {
+	name := formatPostEntry(goal.Entry, goal.VersionCount)
+	if goal.TargetWords <= 0 {
+		fmt.Printf("➖ %s: %d words (no %s set)\n", name, goal.Words, TargetWordsFrontMatterKey)
+		return
+	}
+
+	percent := 100 * goal.Words / goal.TargetWords
+	emoji := "⏳"
+	if goal.Words >= goal.TargetWords {
+		emoji = "✅"
+	}
+	fmt.Printf("%s %s: %s %d%% %d/%d words\n", emoji, name, formatProgressBar(goal.Words, goal.TargetWords), percent, goal.Words, goal.TargetWords)
+
+	if goal.Deadline.IsZero() || goal.Words >= goal.TargetWords {
+		return
+	}
+	remaining := goal.TargetWords - goal.Words
+	if !now.Before(goal.Deadline) {
+		fmt.Printf("     ❌ Missed its deadline of %s by %d words\n", goal.Deadline.Format(statsDateFormat), remaining)
+		return
+	}
+
+	daysLeft := goal.Deadline.Sub(now).Hours() / 24
+	wordsPerDay := int(math.Ceil(float64(remaining) / max(daysLeft, 1)))
+	expected := expectedWordsByNow(goal, now)
+	if goal.Words < expected {
+		fmt.Printf("     ⚠️  Behind pace: should be at %d words by now; needs %d words/day to finish by %s\n", expected, wordsPerDay, goal.Deadline.Format(statsDateFormat))
+	} else {
+		fmt.Printf("     On pace to finish by %s (%d words/day)\n", goal.Deadline.Format(statsDateFormat), wordsPerDay)
+	}
+}
+
+// expectedWordsByNow is how far along the post would be if it were written at an even pace from when it was started
+// to its deadline
+func expectedWordsByNow(goal PostGoal, now time.Time) int {
+	if goal.StartedAt.IsZero() || !goal.StartedAt.Before(goal.Deadline) {
+		return 0
+	}
+	elapsed := now.Sub(goal.StartedAt).Seconds() / goal.Deadline.Sub(goal.StartedAt).Seconds()
+	return int(float64(goal.TargetWords) * min(max(elapsed, 0), 1))
+}
+
+func formatProgressBar(value int, target int) string {
+	filled := min(value*goalBarWidth/max(target, 1), goalBarWidth)
+	return "[" + strings.Repeat("█", filled) + strings.Repeat("░", goalBarWidth-filled) + "]"
+}
+
+func getDailyWordTarget() (int, error) {
+	value := getConfigValue(DailyWordTargetEnvVar)
+	if value == "" {
+		return 0, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 {
+		return 0, stacktrace.NewError("%s must be a positive number of words, but was '%s'", DailyWordTargetEnvVar, value)
+	}
+	return parsed, nil
+}
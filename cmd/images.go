@@ -0,0 +1,393 @@
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/kurtosis-tech/stacktrace"
+	"github.com/spf13/cobra"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+const (
+	// Where a post keeps its images, relative to the post's directory
+	ImagesDirname = "images"
+)
+
+var imagesCheckFix bool
+
+var imagesCmd = &cobra.Command{
+	Use:     "images",
+	Aliases: []string{"image"},
+	Short:   "Work with posts' images",
+}
+
+var imagesCheckCmd = &cobra.Command{
+	Use:   "check [post_dir...]",
+	Short: "Check posts' image references and find unused images",
+	Long: `Check every image referenced by each post's ` + PostFilename + `, reporting references to files that
+don't exist, that only exist if case is ignored (which works on macOS but breaks on Linux), or that
+live in another post's directory. Links to files in the post's ` + ImagesDirname + `/ directory count as
+references too. Also lists images in the post's ` + ImagesDirname + `/ directory that nothing references, which
+--fix deletes. Picks posts the same way 'opwriting lint' does, and exits
+non-zero if any reference is broken.`,
+	RunE: runImagesCheckCommand,
+}
+
+func init() {
+	imagesCheckCmd.Flags().BoolVar(&imagesCheckFix, "fix", false, "Delete images that aren't referenced")
+
+	imagesCmd.AddCommand(imagesCheckCmd)
+}
+
+func runImagesCheckCommand(cmd *cobra.Command, args []string) error {
+	writingRepoPath := os.Getenv(WritingDirEnvVar)
+	if writingRepoPath == "" {
+		return stacktrace.NewError("writing directory not configured: %s environment variable not set", WritingDirEnvVar)
+	}
+
+	postDirs, err := resolvePostDirs(writingRepoPath, args)
+	if err != nil {
+		return err
+	}
+	if len(postDirs) == 0 {
+		fmt.Println("No posts to check")
+		return nil
+	}
+
+	problemCount := 0
+	orphanCount := 0
+	for _, postDir := range postDirs {
+		report, err := checkPostImages(writingRepoPath, postDir)
+		if err != nil {
+			return stacktrace.Propagate(err, "failed to check the images of post '%s'", postDir)
+		}
+		if len(report.Problems) == 0 && len(report.Orphans) == 0 {
+			fmt.Printf("✅ %s\n", postDir)
+			continue
+		}
+
+		fmt.Printf("🖼️  %s\n", postDir)
+		for _, problem := range report.Problems {
+			fmt.Printf("  ❌ %s:%d: %s\n", PostFilename, problem.Reference.Line, problem.Message)
+		}
+		problemCount += len(report.Problems)
+
+		for _, orphan := range report.Orphans {
+			if !imagesCheckFix {
+				fmt.Printf("  ⚠️  %s isn't referenced\n", orphan)
+				orphanCount++
+				continue
+			}
+
+			orphanFilepath := filepath.Join(writingRepoPath, postDir, filepath.FromSlash(orphan))
+			if err := os.Remove(orphanFilepath); err != nil {
+				return stacktrace.Propagate(err, "failed to delete unreferenced image: %s", orphanFilepath)
+			}
+			fmt.Printf("  🗑️  Deleted %s, which wasn't referenced\n", orphan)
+		}
+	}
+
+	if orphanCount > 0 {
+		fmt.Printf("\n💡 Run with --fix to delete the %d unreferenced image(s)\n", orphanCount)
+	}
+	if problemCount > 0 {
+		return stacktrace.NewError("found %d broken image reference(s)", problemCount)
+	}
+	return nil
+}
+
+// ImageReference is an image that a post's Markdown (or inline HTML) points at
+type ImageReference struct {
+	// As written in the post
+	Destination string
+
+	// Line of post.md the reference is on
+	Line int
+}
+
+// Matches the src of <img> tags and the href of <a> tags, whether quoted or not
+var htmlImageSrcRegex = regexp.MustCompile(`(?i)<(img\b[^>]*?\bsrc|a\b[^>]*?\bhref)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+
+// findImageReferences returns every image the post references, in the order they appear. As well as the images
+// the post shows, this includes links to files in the post's images directory, e.g. to the full-size version
+// of a thumbnail.
+func findImageReferences(postDir string) ([]ImageReference, error) {
+	postFilepath := filepath.Join(postDir, PostFilename)
+	rawContent, err := os.ReadFile(postFilepath)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to read post: %s", postFilepath)
+	}
+	content := strings.ReplaceAll(string(rawContent), "\r\n", "\n")
+	_, body, _ := splitFrontMatter(content)
+	bodyLineOffset := strings.Count(content[:len(content)-len(body)], "\n")
+
+	source := []byte(body)
+	document := lintMarkdownParser.Parse(text.NewReader(source))
+
+	var references []ImageReference
+	ast.Walk(document, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		switch typedNode := node.(type) {
+		case *ast.Image:
+			references = append(references, ImageReference{
+				Destination: string(typedNode.Destination),
+				Line:        bodyLineOffset + lineOfOffset(source, findNodeOffset(node)),
+			})
+		case *ast.Link:
+			if isImagesDirLink(postDir, string(typedNode.Destination)) {
+				references = append(references, ImageReference{
+					Destination: string(typedNode.Destination),
+					Line:        bodyLineOffset + lineOfOffset(source, findNodeOffset(node)),
+				})
+			}
+		case *ast.HTMLBlock, *ast.RawHTML:
+			for _, reference := range findHTMLImageReferences(postDir, node, source) {
+				reference.Line += bodyLineOffset
+				references = append(references, reference)
+			}
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return references, nil
+}
+
+// isImagesDirLink returns true if the link points at a file in the post's images directory, rather than at a
+// web page or another post
+func isImagesDirLink(postDir string, destination string) bool {
+	if !isLocalImage(destination) {
+		return false
+	}
+	relPath, err := filepath.Rel(filepath.Join(postDir, ImagesDirname), resolveLocalImagePath(postDir, destination))
+	return err == nil && relPath != "." && !strings.HasPrefix(relPath, "..")
+}
+
+// findHTMLImageReferences finds the <img> tags, and the <a> tags that link into the post's images directory, in a
+// block or inline of raw HTML
+func findHTMLImageReferences(postDir string, node ast.Node, source []byte) []ImageReference {
+	var segments *text.Segments
+	switch typedNode := node.(type) {
+	case *ast.HTMLBlock:
+		segments = typedNode.Lines()
+	case *ast.RawHTML:
+		segments = typedNode.Segments
+	default:
+		return nil
+	}
+
+	var references []ImageReference
+	for idx := 0; idx < segments.Len(); idx++ {
+		segment := segments.At(idx)
+		for _, match := range htmlImageSrcRegex.FindAllSubmatch(segment.Value(source), -1) {
+			// Only one of the double-quoted, single-quoted and unquoted groups matched
+			destination := string(match[2]) + string(match[3]) + string(match[4])
+			isLink := strings.HasPrefix(strings.ToLower(string(match[1])), "a")
+			if destination == "" || (isLink && !isImagesDirLink(postDir, destination)) {
+				continue
+			}
+			references = append(references, ImageReference{
+				Destination: destination,
+				Line:        lineOfOffset(source, segment.Start),
+			})
+		}
+	}
+	return references
+}
+
+// isLocalImage returns true if the image reference points at a file in the repo rather than on the web
+func isLocalImage(destination string) bool {
+	parsed, err := url.Parse(destination)
+	if err != nil {
+		// Unparseable destinations are treated as paths, so that they get reported if they don't exist
+		return true
+	}
+	return parsed.Scheme == "" && parsed.Host == ""
+}
+
+// resolveLocalImagePath returns the path of the file a local image reference points at, relative to the post
+func resolveLocalImagePath(postDir string, destination string) string {
+	// Query strings and fragments aren't part of the file name
+	if idx := strings.IndexAny(destination, "?#"); idx != -1 {
+		destination = destination[:idx]
+	}
+	if unescaped, err := url.PathUnescape(destination); err == nil {
+		destination = unescaped
+	}
+	return filepath.Join(postDir, filepath.FromSlash(destination))
+}
+
+// ImageProblem is a reference to an image that won't show up once the post is published
+type ImageProblem struct {
+	Reference ImageReference
+	Message   string
+}
+
+// PostImagesReport is everything wrong with how a post uses images
+type PostImagesReport struct {
+	// References to missing files, files whose name only matches case-insensitively, and files
+	// outside the post's directory
+	Problems []ImageProblem
+
+	// Files in the post's images directory that nothing references, relative to the post
+	Orphans []string
+}
+
+// checkPostImages checks every image reference in the post, and looks for images in the post's images
+// directory that aren't referenced
+func checkPostImages(writingRepoPath string, postDir string) (*PostImagesReport, error) {
+	absWritingRepoPath, err := filepath.Abs(writingRepoPath)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to get absolute path for writing directory")
+	}
+	absPostDir := filepath.Join(absWritingRepoPath, postDir)
+
+	references, err := findImageReferences(absPostDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Images are only orphans if post.md doesn't mention them at all, since a reference written in a way that
+	// isn't recognized mustn't get its image deleted
+	postFilepath := filepath.Join(absPostDir, PostFilename)
+	postContent, err := os.ReadFile(postFilepath)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to read post: %s", postFilepath)
+	}
+
+	report := &PostImagesReport{}
+	referencedPaths := map[string]bool{}
+	for _, reference := range references {
+		if !isLocalImage(reference.Destination) {
+			continue
+		}
+
+		imagePath := resolveLocalImagePath(absPostDir, reference.Destination)
+		actualPath, exists, caseMismatch := resolveExactPath(absWritingRepoPath, imagePath)
+		if !exists {
+			report.Problems = append(report.Problems, ImageProblem{
+				Reference: reference,
+				Message:   fmt.Sprintf("'%s' doesn't exist", reference.Destination),
+			})
+			continue
+		}
+		referencedPaths[actualPath] = true
+
+		if caseMismatch {
+			actualRelPath, _ := filepath.Rel(absPostDir, actualPath)
+			report.Problems = append(report.Problems, ImageProblem{
+				Reference: reference,
+				Message:   fmt.Sprintf("'%s' only matches '%s' if case is ignored, which breaks on Linux", reference.Destination, filepath.ToSlash(actualRelPath)),
+			})
+		}
+
+		if relPath, _ := filepath.Rel(absPostDir, actualPath); strings.HasPrefix(relPath, "..") {
+			location := "outside the post's directory"
+			if ownerDir := findOwningPostDir(absWritingRepoPath, actualPath); ownerDir != "" {
+				location = fmt.Sprintf("in another post's directory ('%s')", ownerDir)
+			}
+			report.Problems = append(report.Problems, ImageProblem{
+				Reference: reference,
+				Message:   fmt.Sprintf("'%s' is %s; copy it into this post so the posts can change independently", reference.Destination, location),
+			})
+		}
+	}
+
+	imagesDirpath := filepath.Join(absPostDir, ImagesDirname)
+	err = filepath.WalkDir(imagesDirpath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == imagesDirpath {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			return nil
+		}
+		if referencedPaths[path] {
+			return nil
+		}
+		relPath, _ := filepath.Rel(absPostDir, path)
+		relPath = filepath.ToSlash(relPath)
+		if !isMentioned(string(postContent), relPath) {
+			report.Orphans = append(report.Orphans, relPath)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to list images in: %s", imagesDirpath)
+	}
+	return report, nil
+}
+
+// isMentioned returns true if the post's text contains the image's path relative to the post, as it is or
+// URL-escaped
+func isMentioned(postContent string, relImagePath string) bool {
+	escapedPath := (&url.URL{Path: relImagePath}).EscapedPath()
+	return strings.Contains(postContent, relImagePath) || strings.Contains(postContent, escapedPath)
+}
+
+// resolveExactPath finds the file at the given absolute path by comparing each path component below the base
+// directory against the directory's actual entries, since case-insensitive filesystems (e.g. macOS's)
+// would otherwise hide names that only match when case is ignored. Returns the file's actual path, whether
+// it exists, and whether any component's case differs.
+func resolveExactPath(baseDirpath string, path string) (string, bool, bool) {
+	relPath, err := filepath.Rel(baseDirpath, path)
+	if err != nil || strings.HasPrefix(relPath, "..") {
+		// Outside the repo, where the case can't be checked
+		_, err := os.Stat(path)
+		return path, err == nil, false
+	}
+
+	actualPath := baseDirpath
+	caseMismatch := false
+	for _, component := range strings.Split(relPath, string(filepath.Separator)) {
+		if component == "." || component == "" {
+			continue
+		}
+
+		entries, err := os.ReadDir(actualPath)
+		if err != nil {
+			return path, false, false
+		}
+		matchedName := ""
+		for _, entry := range entries {
+			if entry.Name() == component {
+				matchedName = entry.Name()
+				break
+			}
+			if matchedName == "" && strings.EqualFold(entry.Name(), component) {
+				matchedName = entry.Name()
+			}
+		}
+		if matchedName == "" {
+			return path, false, false
+		}
+		if matchedName != component {
+			caseMismatch = true
+		}
+		actualPath = filepath.Join(actualPath, matchedName)
+	}
+	return actualPath, true, caseMismatch
+}
+
+// findOwningPostDir returns the post directory (relative to the repo) that the file is in, or the empty string
+// if it isn't in one
+func findOwningPostDir(absWritingRepoPath string, path string) string {
+	for dir := filepath.Dir(path); dir != absWritingRepoPath && strings.HasPrefix(dir, absWritingRepoPath); dir = filepath.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(dir, PostFilename)); err == nil {
+			relDir, _ := filepath.Rel(absWritingRepoPath, dir)
+			return filepath.ToSlash(relDir)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,244 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/kurtosis-tech/stacktrace"
+	"github.com/spf13/cobra"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// Where 'opwriting image add' puts the image's Markdown, if the post has one
+	ImageMarker = "<!-- image -->"
+
+	maxSlugLength = 60
+)
+
+// The extension that images added in each format get
+var imageFormatExtensions = map[string]string{
+	"png":  ".png",
+	"jpeg": ".jpg",
+	"gif":  ".gif",
+	"webp": webpExtension,
+}
+
+var imagesAddClipboard bool
+var imagesAddName string
+var imagesAddNoOptimize bool
+
+var imagesAddCmd = &cobra.Command{
+	Use:   "add {file|--clipboard} [alt_text...]",
+	Short: "Add an image to the current post",
+	Long: `Copy an image file, or the image on the clipboard with --clipboard, into the ` + ImagesDirname + `/ directory of the
+post you're in. It's named after the alt text (or --name, or the original file name) and optimized the same
+way as 'opwriting images optimize'. If the post already has the same image, that one is reused instead.
+
+The image's Markdown is put in place of the first '` + ImageMarker + `' in the post's ` + PostFilename + `, or printed
+if there isn't one.`,
+	RunE: runImagesAddCommand,
+}
+
+func init() {
+	imagesAddCmd.Flags().BoolVar(&imagesAddClipboard, "clipboard", false, "Add the image on the clipboard rather than a file")
+	imagesAddCmd.Flags().StringVar(&imagesAddName, "name", "", "Name the image's file after this rather than the alt text")
+	imagesAddCmd.Flags().BoolVar(&imagesAddNoOptimize, "no-optimize", false, "Copy the image as-is")
+
+	imagesCmd.AddCommand(imagesAddCmd)
+}
+
+func runImagesAddCommand(cmd *cobra.Command, args []string) error {
+	if err := validateWritingDirectory(); err != nil {
+		return stacktrace.Propagate(err, "directory validation failed")
+	}
+	postDirpath, err := findCurrentPostDirpath()
+	if err != nil {
+		return err
+	}
+
+	var source []byte
+	sourceName := ""
+	if imagesAddClipboard {
+		if source, err = readClipboardImage(); err != nil {
+			return stacktrace.Propagate(err, "failed to get the image on the clipboard")
+		}
+	} else {
+		if len(args) == 0 {
+			return stacktrace.NewError("give the image file to add, or --clipboard to add the image on the clipboard")
+		}
+		sourceFilepath := args[0]
+		args = args[1:]
+		if source, err = os.ReadFile(sourceFilepath); err != nil {
+			return stacktrace.Propagate(err, "failed to read image: %s", sourceFilepath)
+		}
+		sourceName = strings.TrimSuffix(filepath.Base(sourceFilepath), filepath.Ext(sourceFilepath))
+	}
+	altText := strings.Join(args, " ")
+
+	_, format, err := image.DecodeConfig(bytes.NewReader(source))
+	if err != nil {
+		return stacktrace.Propagate(err, "the image isn't a PNG, JPEG, GIF or WebP")
+	}
+	extension := imageFormatExtensions[format]
+
+	data := source
+	var changes []string
+	if isOptimizableImage(extension) && !imagesAddNoOptimize {
+		settings, err := getImageOptimizeSettings(cmd)
+		if err != nil {
+			return err
+		}
+		optimized, err := optimizeImageData(source, settings)
+		if err != nil {
+			return stacktrace.Propagate(err, "failed to optimize the image")
+		}
+		data = optimized.Data
+		changes = optimized.Changes
+		if optimized.NewExtension != "" {
+			extension = optimized.NewExtension
+		}
+	}
+
+	imagesDirpath := filepath.Join(postDirpath, ImagesDirname)
+	imageRelPath, err := findDuplicateImage(postDirpath, source, data)
+	if err != nil {
+		return err
+	}
+	if imageRelPath != "" {
+		fmt.Printf("♻️  The post already has this image as %s\n", imageRelPath)
+	} else {
+		if err := os.MkdirAll(imagesDirpath, 0755); err != nil {
+			return stacktrace.Propagate(err, "failed to create images directory: %s", imagesDirpath)
+		}
+
+		slug := ""
+		for _, name := range []string{imagesAddName, altText, sourceName, "image"} {
+			if slug = slugify(name); slug != "" {
+				break
+			}
+		}
+		filename := slug + extension
+		for suffix := 2; ; suffix++ {
+			if _, err := os.Stat(filepath.Join(imagesDirpath, filename)); os.IsNotExist(err) {
+				break
+			}
+			filename = fmt.Sprintf("%s-%d%s", slug, suffix, extension)
+		}
+
+		imageFilepath := filepath.Join(imagesDirpath, filename)
+		if err := os.WriteFile(imageFilepath, data, 0644); err != nil {
+			return stacktrace.Propagate(err, "failed to write image: %s", imageFilepath)
+		}
+		imageRelPath = ImagesDirname + "/" + filename
+
+		details := formatFileSize(len(data))
+		if len(changes) > 0 {
+			details = fmt.Sprintf("%s → %s; %s", formatFileSize(len(source)), details, strings.Join(changes, ", "))
+		}
+		fmt.Printf("🖼️  Added %s (%s)\n", imageRelPath, details)
+	}
+
+	destination := imageRelPath
+	if strings.ContainsAny(destination, " ()") {
+		destination = "<" + destination + ">"
+	}
+	altText = strings.NewReplacer("[", `\[`, "]", `\]`).Replace(altText)
+	markdown := fmt.Sprintf("![%s](%s)", altText, destination)
+
+	postFilepath := filepath.Join(postDirpath, PostFilename)
+	content, err := os.ReadFile(postFilepath)
+	if err != nil {
+		return stacktrace.Propagate(err, "failed to read post: %s", postFilepath)
+	}
+	if !strings.Contains(string(content), ImageMarker) {
+		fmt.Println(markdown)
+		return nil
+	}
+
+	updated := strings.Replace(string(content), ImageMarker, markdown, 1)
+	if err := os.WriteFile(postFilepath, []byte(updated), 0644); err != nil {
+		return stacktrace.Propagate(err, "failed to write post: %s", postFilepath)
+	}
+	fmt.Printf("✏️  Inserted %s at the %s marker in %s\n", markdown, ImageMarker, PostFilename)
+	return nil
+}
+
+// findCurrentPostDirpath returns the directory of the post the current directory is in, looking upwards for its
+// post file but not past the writing repo
+func findCurrentPostDirpath() (string, error) {
+	currentDir, err := os.Getwd()
+	if err != nil {
+		return "", stacktrace.Propagate(err, "failed to get current working directory")
+	}
+	absWritingDir, err := filepath.Abs(os.Getenv(WritingDirEnvVar))
+	if err != nil {
+		return "", stacktrace.Propagate(err, "failed to get absolute path for writing directory")
+	}
+
+	for dir := currentDir; ; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(dir, PostFilename)); err == nil {
+			return dir, nil
+		}
+		if dir == absWritingDir || dir == filepath.Dir(dir) {
+			break
+		}
+	}
+	return "", stacktrace.NewError("not in a post; run this from a post's directory (one with a %s)", PostFilename)
+}
+
+// findDuplicateImage returns the path (relative to the post) of the image in the post's images directory with
+// the same contents as any of the given versions of an image, or the empty string if there isn't one
+func findDuplicateImage(postDirpath string, versions ...[]byte) (string, error) {
+	hashes := map[[sha256.Size]byte]bool{}
+	for _, version := range versions {
+		hashes[sha256.Sum256(version)] = true
+	}
+
+	duplicateRelPath := ""
+	imagesDirpath := filepath.Join(postDirpath, ImagesDirname)
+	err := filepath.WalkDir(imagesDirpath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == imagesDirpath {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if entry.IsDir() || duplicateRelPath != "" {
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if hashes[sha256.Sum256(content)] {
+			relPath, _ := filepath.Rel(postDirpath, path)
+			duplicateRelPath = filepath.ToSlash(relPath)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", stacktrace.Propagate(err, "failed to look for duplicates in: %s", imagesDirpath)
+	}
+	return duplicateRelPath, nil
+}
+
+var nonSlugCharsRegex = regexp.MustCompile(`[^a-z0-9]+`)
+
+// slugify turns text into a lowercase, hyphen-separated name that's safe for files and URLs
+func slugify(text string) string {
+	slug := strings.Trim(nonSlugCharsRegex.ReplaceAllString(strings.ToLower(text), "-"), "-")
+	if len(slug) > maxSlugLength {
+		slug = strings.TrimRight(slug[:maxSlugLength], "-")
+	}
+	return slug
+}
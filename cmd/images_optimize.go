@@ -0,0 +1,625 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kurtosis-tech/stacktrace"
+	"github.com/spf13/cobra"
+	xdraw "golang.org/x/image/draw"
+)
+
+const (
+	// Images wider than this get scaled down; Substack shows images at most 1456 pixels wide
+	ImageMaxWidthEnvVar  = "IMAGE_MAX_WIDTH"
+	defaultImageMaxWidth = 1456
+
+	// Quality (1-100) that JPEGs are recompressed at
+	ImageJPEGQualityEnvVar  = "IMAGE_JPEG_QUALITY"
+	defaultImageJPEGQuality = 82
+
+	// Whether to convert images to WebP when that makes them smaller ('true' or 'false')
+	ImageConvertToWebPEnvVar = "IMAGE_CONVERT_TO_WEBP"
+
+	webpExtension = ".webp"
+)
+
+var imagesOptimizeMaxWidth int
+var imagesOptimizeQuality int
+var imagesOptimizeWebP bool
+var imagesOptimizeDryRun bool
+
+var imagesOptimizeCmd = &cobra.Command{
+	Use:   "optimize [post_dir...]",
+	Short: "Shrink posts' images",
+	Long: `Optimize the PNGs and JPEGs in each post's ` + ImagesDirname + `/ directory: scale down images wider than the
+maximum width, recompress them, and strip their metadata (EXIF, including GPS location, and text chunks),
+keeping whichever version is smallest. With --webp, images are also converted to lossless WebP when that's
+smaller still, and references to them in ` + PostFilename + ` are updated to the new file names.
+
+Defaults come from ` + ImageMaxWidthEnvVar + `, ` + ImageJPEGQualityEnvVar + ` and ` + ImageConvertToWebPEnvVar + ` in the config, and the
+flags override them. Picks posts the same way 'opwriting lint' does.`,
+	RunE: runImagesOptimizeCommand,
+}
+
+func init() {
+	imagesOptimizeCmd.Flags().IntVar(&imagesOptimizeMaxWidth, "max-width", defaultImageMaxWidth, "Scale down images wider than this many pixels")
+	imagesOptimizeCmd.Flags().IntVar(&imagesOptimizeQuality, "quality", defaultImageJPEGQuality, "Quality (1-100) to recompress JPEGs at")
+	imagesOptimizeCmd.Flags().BoolVar(&imagesOptimizeWebP, "webp", false, "Convert images to WebP when that makes them smaller")
+	imagesOptimizeCmd.Flags().BoolVar(&imagesOptimizeDryRun, "dry-run", false, "Show what would change without changing anything")
+
+	imagesCmd.AddCommand(imagesOptimizeCmd)
+}
+
+func runImagesOptimizeCommand(cmd *cobra.Command, args []string) error {
+	writingRepoPath := os.Getenv(WritingDirEnvVar)
+	if writingRepoPath == "" {
+		return stacktrace.NewError("writing directory not configured: %s environment variable not set", WritingDirEnvVar)
+	}
+
+	settings, err := getImageOptimizeSettings(cmd)
+	if err != nil {
+		return err
+	}
+
+	postDirs, err := resolvePostDirs(writingRepoPath, args)
+	if err != nil {
+		return err
+	}
+	if len(postDirs) == 0 {
+		fmt.Println("No posts to optimize")
+		return nil
+	}
+
+	totalSaved := 0
+	for _, postDir := range postDirs {
+		saved, err := optimizePostImages(filepath.Join(writingRepoPath, postDir), postDir, settings, imagesOptimizeDryRun)
+		if err != nil {
+			return stacktrace.Propagate(err, "failed to optimize the images of post '%s'", postDir)
+		}
+		totalSaved += saved
+	}
+
+	if imagesOptimizeDryRun {
+		fmt.Printf("\nWould save %s (dry run; nothing was changed)\n", formatFileSize(totalSaved))
+	} else {
+		fmt.Printf("\nSaved %s\n", formatFileSize(totalSaved))
+	}
+	return nil
+}
+
+// ImageOptimizeSettings controls how images get optimized
+type ImageOptimizeSettings struct {
+	MaxWidth      int
+	JPEGQuality   int
+	ConvertToWebP bool
+}
+
+// getImageOptimizeSettings reads the settings from the config, letting any flags the user passed override them
+func getImageOptimizeSettings(cmd *cobra.Command) (ImageOptimizeSettings, error) {
+	settings := ImageOptimizeSettings{
+		MaxWidth:      defaultImageMaxWidth,
+		JPEGQuality:   defaultImageJPEGQuality,
+		ConvertToWebP: strings.EqualFold(getConfigValue(ImageConvertToWebPEnvVar), "true"),
+	}
+
+	parsePositive := func(key string, defaultValue int) (int, error) {
+		value := getConfigValue(key)
+		if value == "" {
+			return defaultValue, nil
+		}
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			return 0, stacktrace.NewError("%s must be a positive number, but was '%s'", key, value)
+		}
+		return parsed, nil
+	}
+	var err error
+	if settings.MaxWidth, err = parsePositive(ImageMaxWidthEnvVar, defaultImageMaxWidth); err != nil {
+		return ImageOptimizeSettings{}, err
+	}
+	if settings.JPEGQuality, err = parsePositive(ImageJPEGQualityEnvVar, defaultImageJPEGQuality); err != nil {
+		return ImageOptimizeSettings{}, err
+	}
+
+	if cmd.Flags().Changed("max-width") {
+		settings.MaxWidth = imagesOptimizeMaxWidth
+	}
+	if cmd.Flags().Changed("quality") {
+		settings.JPEGQuality = imagesOptimizeQuality
+	}
+	if cmd.Flags().Changed("webp") {
+		settings.ConvertToWebP = imagesOptimizeWebP
+	}
+
+	if settings.MaxWidth < 1 {
+		return ImageOptimizeSettings{}, stacktrace.NewError("the maximum width must be at least 1 pixel")
+	}
+	if settings.JPEGQuality < 1 || settings.JPEGQuality > 100 {
+		return ImageOptimizeSettings{}, stacktrace.NewError("the JPEG quality must be between 1 and 100, but was %d", settings.JPEGQuality)
+	}
+	return settings, nil
+}
+
+// optimizePostImages optimizes every PNG and JPEG in the post's images directory, pointing the post at any that
+// were converted to WebP, and returns the number of bytes saved
+func optimizePostImages(postDirpath string, postDir string, settings ImageOptimizeSettings, dryRun bool) (int, error) {
+	imagesDirpath := filepath.Join(postDirpath, ImagesDirname)
+	var imageFilepaths []string
+	err := filepath.WalkDir(imagesDirpath, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == imagesDirpath {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !entry.IsDir() && isOptimizableImage(path) {
+			imageFilepaths = append(imageFilepaths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, stacktrace.Propagate(err, "failed to list images in: %s", imagesDirpath)
+	}
+	if len(imageFilepaths) == 0 {
+		fmt.Printf("✅ %s (no images to optimize)\n", postDir)
+		return 0, nil
+	}
+	sort.Strings(imageFilepaths)
+
+	fmt.Printf("🖼️  %s\n", postDir)
+	saved := 0
+	renamedFilepaths := map[string]string{}
+	for _, imageFilepath := range imageFilepaths {
+		relPath, _ := filepath.Rel(postDirpath, imageFilepath)
+		relPath = filepath.ToSlash(relPath)
+
+		result, err := optimizeImage(imageFilepath, settings, dryRun)
+		if err != nil {
+			return 0, stacktrace.Propagate(err, "failed to optimize image: %s", imageFilepath)
+		}
+		if len(result.Changes) == 0 {
+			fmt.Printf("  ✅ %s is already optimized\n", relPath)
+			continue
+		}
+
+		fmt.Printf(
+			"  🗜️  %s: %s → %s (%s)\n",
+			relPath,
+			formatFileSize(result.OriginalSize),
+			formatFileSize(result.OptimizedSize),
+			strings.Join(result.Changes, ", "),
+		)
+		saved += result.OriginalSize - result.OptimizedSize
+		if result.Filepath != imageFilepath {
+			renamedFilepaths[imageFilepath] = result.Filepath
+		}
+	}
+
+	if len(renamedFilepaths) > 0 {
+		postFilepath := filepath.Join(postDirpath, PostFilename)
+		numRewritten, err := rewriteImageReferences(postDirpath, renamedFilepaths, dryRun)
+		if err != nil {
+			return 0, stacktrace.Propagate(err, "failed to update the image references in: %s", postFilepath)
+		}
+		if numRewritten > 0 {
+			verb := "Updated"
+			if dryRun {
+				verb = "Would update"
+			}
+			fmt.Printf("  ✏️  %s %d image reference(s) in %s\n", verb, numRewritten, PostFilename)
+		}
+
+		// The originals are only removed once nothing points at them
+		if !dryRun {
+			for originalFilepath := range renamedFilepaths {
+				if err := os.Remove(originalFilepath); err != nil {
+					return 0, stacktrace.Propagate(err, "failed to delete the original of a converted image: %s", originalFilepath)
+				}
+			}
+		}
+	}
+	return saved, nil
+}
+
+// ImageOptimizeResult is what optimizing a single image did
+type ImageOptimizeResult struct {
+	// Where the optimized image is, which differs from the original when it was converted to another format
+	Filepath string
+
+	OriginalSize  int
+	OptimizedSize int
+
+	// What was done to the image, e.g. 'stripped metadata'; empty if it was already optimized
+	Changes []string
+}
+
+// optimizeImage optimizes the image file in place, or next to it if it's converted to another format, unless
+// it's a dry run
+func optimizeImage(imageFilepath string, settings ImageOptimizeSettings, dryRun bool) (*ImageOptimizeResult, error) {
+	original, err := os.ReadFile(imageFilepath)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to read image")
+	}
+	info, err := os.Stat(imageFilepath)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to get info of image")
+	}
+
+	// Converting mustn't clobber an existing file
+	webpFilepath := strings.TrimSuffix(imageFilepath, filepath.Ext(imageFilepath)) + webpExtension
+	if _, err := os.Stat(webpFilepath); err == nil {
+		settings.ConvertToWebP = false
+	}
+
+	optimized, err := optimizeImageData(original, settings)
+	if err != nil {
+		return nil, err
+	}
+	result := &ImageOptimizeResult{
+		Filepath:      imageFilepath,
+		OriginalSize:  len(original),
+		OptimizedSize: len(original),
+	}
+	if len(optimized.Changes) == 0 {
+		return result, nil
+	}
+	if optimized.NewExtension != "" {
+		result.Filepath = webpFilepath
+	}
+	result.OptimizedSize = len(optimized.Data)
+	result.Changes = optimized.Changes
+
+	if !dryRun {
+		if err := os.WriteFile(result.Filepath, optimized.Data, info.Mode().Perm()); err != nil {
+			return nil, stacktrace.Propagate(err, "failed to write optimized image: %s", result.Filepath)
+		}
+	}
+	return result, nil
+}
+
+// OptimizedImage is the optimized contents of an image
+type OptimizedImage struct {
+	Data []byte
+
+	// The file extension for the image's new format (e.g. '.webp'), or empty if the format didn't change
+	NewExtension string
+
+	// What was done to the image, e.g. 'stripped metadata'; empty if the original is already as small as it gets
+	Changes []string
+}
+
+// isOptimizableImage returns true if the file name is one of the formats that can be optimized
+func isOptimizableImage(filename string) bool {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".png", ".jpg", ".jpeg":
+		return true
+	}
+	return false
+}
+
+// optimizeImageData shrinks a PNG or JPEG, keeping the smallest of the versions it tries. The result only
+// depends on the image and settings, so optimizing the same image twice gives the same bytes.
+func optimizeImageData(original []byte, settings ImageOptimizeSettings) (*OptimizedImage, error) {
+	img, format, err := image.Decode(bytes.NewReader(original))
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to decode image")
+	}
+
+	var changes []string
+	var stripped []byte
+	orientation := 1
+	switch format {
+	case "jpeg":
+		orientation = getJPEGOrientation(original)
+		stripped = stripJPEGMetadata(original)
+	case "png":
+		stripped = stripPNGMetadata(original)
+	default:
+		return nil, stacktrace.NewError("'%s' files can't be optimized", format)
+	}
+	hasMetadata := len(stripped) < len(original)
+
+	// Metadata is about to be stripped, so its rotation has to be applied to the pixels
+	needsReencoding := false
+	if orientation != 1 {
+		img = applyOrientation(img, orientation)
+		needsReencoding = true
+		changes = append(changes, "applied EXIF rotation")
+	}
+	if width := img.Bounds().Dx(); width > settings.MaxWidth {
+		img = resizeToWidth(img, settings.MaxWidth)
+		needsReencoding = true
+		changes = append(changes, fmt.Sprintf("resized from %dpx to %dpx wide", width, settings.MaxWidth))
+	}
+
+	var reencoded bytes.Buffer
+	switch format {
+	case "jpeg":
+		err = jpeg.Encode(&reencoded, img, &jpeg.Options{Quality: settings.JPEGQuality})
+	case "png":
+		err = (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(&reencoded, img)
+	}
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to re-encode image")
+	}
+
+	// Stripping the metadata alone avoids recompressing, but only works if the pixels didn't change
+	optimized := &OptimizedImage{Data: reencoded.Bytes()}
+	recompressed := true
+	if !needsReencoding && len(stripped) <= len(optimized.Data) {
+		optimized.Data = stripped
+		recompressed = false
+	}
+
+	if settings.ConvertToWebP {
+		webpData, err := encodeLosslessWebP(img)
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "failed to convert image to WebP")
+		}
+		if len(webpData) < len(optimized.Data) {
+			optimized.Data = webpData
+			optimized.NewExtension = webpExtension
+		}
+	}
+
+	// Recompressing alone is only worth it if it saves space
+	if !needsReencoding && !hasMetadata && len(optimized.Data) >= len(original) {
+		return &OptimizedImage{Data: original}, nil
+	}
+
+	switch {
+	case optimized.NewExtension != "":
+		changes = append(changes, "converted to WebP")
+	case recompressed:
+		changes = append(changes, "recompressed")
+	}
+	if hasMetadata {
+		changes = append(changes, "stripped metadata")
+	}
+	optimized.Changes = changes
+	return optimized, nil
+}
+
+// resizeToWidth scales the image down to the given width, keeping its aspect ratio
+func resizeToWidth(img image.Image, width int) image.Image {
+	bounds := img.Bounds()
+	height := max(1, bounds.Dy()*width/bounds.Dx())
+	resized := image.NewNRGBA(image.Rect(0, 0, width, height))
+	xdraw.CatmullRom.Scale(resized, resized.Bounds(), img, bounds, xdraw.Src, nil)
+	return resized
+}
+
+// applyOrientation rotates and flips the image the way its EXIF orientation (1-8) says it should be shown
+func applyOrientation(img image.Image, orientation int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	source := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(source, source.Bounds(), img, bounds.Min, draw.Src)
+
+	// Orientations 5-8 swap the width and height
+	orientedWidth, orientedHeight := width, height
+	if orientation >= 5 {
+		orientedWidth, orientedHeight = height, width
+	}
+	oriented := image.NewNRGBA(image.Rect(0, 0, orientedWidth, orientedHeight))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var orientedX, orientedY int
+			switch orientation {
+			case 2:
+				orientedX, orientedY = width-1-x, y
+			case 3:
+				orientedX, orientedY = width-1-x, height-1-y
+			case 4:
+				orientedX, orientedY = x, height-1-y
+			case 5:
+				orientedX, orientedY = y, x
+			case 6:
+				orientedX, orientedY = height-1-y, x
+			case 7:
+				orientedX, orientedY = height-1-y, width-1-x
+			case 8:
+				orientedX, orientedY = y, width-1-x
+			default:
+				orientedX, orientedY = x, y
+			}
+			copy(oriented.Pix[oriented.PixOffset(orientedX, orientedY):], source.Pix[source.PixOffset(x, y):source.PixOffset(x, y)+4])
+		}
+	}
+	return oriented
+}
+
+const (
+	jpegMarkerStartOfScan = 0xda
+	jpegMarkerAPP1        = 0xe1
+	jpegMarkerAPP13       = 0xed
+	jpegMarkerComment     = 0xfe
+
+	exifOrientationTag = 0x0112
+)
+
+// forEachJPEGSegment calls the function with the marker and bounds of each segment before the image data,
+// stopping early if the file is malformed
+func forEachJPEGSegment(data []byte, fn func(marker byte, start int, end int)) {
+	for offset := 2; offset+4 <= len(data) && data[offset] == 0xff; {
+		marker := data[offset+1]
+		if marker == jpegMarkerStartOfScan {
+			return
+		}
+		end := offset + 2 + int(binary.BigEndian.Uint16(data[offset+2:]))
+		if end > len(data) {
+			return
+		}
+		fn(marker, offset, end)
+		offset = end
+	}
+}
+
+// stripJPEGMetadata removes the EXIF/XMP, IPTC and comment segments without touching the image data, keeping
+// the ones that affect how it looks (e.g. color profiles)
+func stripJPEGMetadata(data []byte) []byte {
+	var stripped []byte
+	lastEnd := 0
+	forEachJPEGSegment(data, func(marker byte, start int, end int) {
+		if marker == jpegMarkerAPP1 || marker == jpegMarkerAPP13 || marker == jpegMarkerComment {
+			stripped = append(stripped, data[lastEnd:start]...)
+			lastEnd = end
+		}
+	})
+	if lastEnd == 0 {
+		return data
+	}
+	return append(stripped, data[lastEnd:]...)
+}
+
+// getJPEGOrientation returns the orientation from the JPEG's EXIF data, or 1 (upright) if it doesn't have one
+func getJPEGOrientation(data []byte) int {
+	orientation := 1
+	forEachJPEGSegment(data, func(marker byte, start int, end int) {
+		segment := data[start+4 : end]
+		if marker != jpegMarkerAPP1 || !bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return
+		}
+		tiff := segment[6:]
+		if len(tiff) < 8 {
+			return
+		}
+		var byteOrder binary.ByteOrder = binary.BigEndian
+		if string(tiff[:2]) == "II" {
+			byteOrder = binary.LittleEndian
+		}
+
+		ifdOffset := int(byteOrder.Uint32(tiff[4:]))
+		if ifdOffset+2 > len(tiff) {
+			return
+		}
+		numEntries := int(byteOrder.Uint16(tiff[ifdOffset:]))
+		for idx := 0; idx < numEntries; idx++ {
+			entryOffset := ifdOffset + 2 + 12*idx
+			if entryOffset+12 > len(tiff) {
+				return
+			}
+			if byteOrder.Uint16(tiff[entryOffset:]) == exifOrientationTag {
+				if value := int(byteOrder.Uint16(tiff[entryOffset+8:])); value >= 1 && value <= 8 {
+					orientation = value
+				}
+				return
+			}
+		}
+	})
+	return orientation
+}
+
+// Ancillary PNG chunks that only hold metadata, which can be dropped without changing how the image looks
+var pngMetadataChunkTypes = map[string]bool{
+	"eXIf": true,
+	"tEXt": true,
+	"iTXt": true,
+	"zTXt": true,
+	"tIME": true,
+}
+
+// stripPNGMetadata removes the PNG's EXIF and text chunks without recompressing it
+func stripPNGMetadata(data []byte) []byte {
+	const signatureLength = 8
+	if len(data) < signatureLength {
+		return data
+	}
+
+	stripped := append([]byte{}, data[:signatureLength]...)
+	offset := signatureLength
+	for offset+8 <= len(data) {
+		// Length, type, data and CRC
+		end := offset + 12 + int(binary.BigEndian.Uint32(data[offset:]))
+		if end > len(data) || end < offset {
+			return data
+		}
+		if !pngMetadataChunkTypes[string(data[offset+4:offset+8])] {
+			stripped = append(stripped, data[offset:end]...)
+		}
+		offset = end
+	}
+	return append(stripped, data[offset:]...)
+}
+
+// rewriteImageReferences points the post's references to renamed images at their new names, keeping how each
+// reference was written (e.g. './images/...' or a query string), and returns the number of references changed
+func rewriteImageReferences(postDirpath string, renamedFilepaths map[string]string, dryRun bool) (int, error) {
+	references, err := findImageReferences(postDirpath)
+	if err != nil {
+		return 0, err
+	}
+
+	replacements := map[string]string{}
+	for _, reference := range references {
+		if !isLocalImage(reference.Destination) {
+			continue
+		}
+		newFilepath, found := renamedFilepaths[resolveLocalImagePath(postDirpath, reference.Destination)]
+		if !found {
+			continue
+		}
+
+		path, suffix := reference.Destination, ""
+		if idx := strings.IndexAny(path, "?#"); idx != -1 {
+			path, suffix = path[:idx], path[idx:]
+		}
+		replacements[reference.Destination] = strings.TrimSuffix(path, filepath.Ext(path)) + filepath.Ext(newFilepath) + suffix
+	}
+	if len(replacements) == 0 {
+		return 0, nil
+	}
+
+	postFilepath := filepath.Join(postDirpath, PostFilename)
+	content, err := os.ReadFile(postFilepath)
+	if err != nil {
+		return 0, stacktrace.Propagate(err, "failed to read post: %s", postFilepath)
+	}
+
+	// Only whole destinations get replaced, so 'a.png' doesn't match inside 'images/a.png'
+	numRewritten := 0
+	updated := string(content)
+	for oldDestination, newDestination := range replacements {
+		destinationRegex := regexp.MustCompile(`(^|[\s("'<=:])` + regexp.QuoteMeta(oldDestination) + `($|[\s)"'>])`)
+		updated = destinationRegex.ReplaceAllStringFunc(updated, func(match string) string {
+			numRewritten++
+			return strings.Replace(match, oldDestination, newDestination, 1)
+		})
+	}
+
+	if !dryRun {
+		if err := os.WriteFile(postFilepath, []byte(updated), 0644); err != nil {
+			return 0, stacktrace.Propagate(err, "failed to write post: %s", postFilepath)
+		}
+	}
+	return numRewritten, nil
+}
+
+// formatFileSize formats a number of bytes for people, e.g. '1.2 MB'
+func formatFileSize(numBytes int) string {
+	const unit = 1024
+	if numBytes < unit && numBytes > -unit {
+		return fmt.Sprintf("%d B", numBytes)
+	}
+	value := float64(numBytes)
+	for _, suffix := range []string{"KB", "MB", "GB"} {
+		value /= unit
+		if value < unit && value > -unit {
+			return fmt.Sprintf("%.1f %s", value, suffix)
+		}
+	}
+	return fmt.Sprintf("%.1f TB", value/unit)
+}
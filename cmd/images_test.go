@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeTestPost creates a post directory with the given post.md and empty image files
+func writeTestPost(t *testing.T, writingRepoPath string, postDir string, content string, imageRelFilepaths ...string) string {
+	postDirpath := filepath.Join(writingRepoPath, postDir)
+	for _, relFilepath := range imageRelFilepaths {
+		imageFilepath := filepath.Join(postDirpath, filepath.FromSlash(relFilepath))
+		if err := os.MkdirAll(filepath.Dir(imageFilepath), 0755); err != nil {
+			t.Fatalf("failed to create image directory: %v", err)
+		}
+		if err := os.WriteFile(imageFilepath, []byte("image"), 0644); err != nil {
+			t.Fatalf("failed to write image: %v", err)
+		}
+	}
+	if err := os.MkdirAll(postDirpath, 0755); err != nil {
+		t.Fatalf("failed to create post directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(postDirpath, PostFilename), []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write post: %v", err)
+	}
+	return postDirpath
+}
+
+func TestCheckPostImages(t *testing.T) {
+	writingRepoPath := t.TempDir()
+	writeTestPost(t, writingRepoPath, "other-post", "# Other post\n")
+	content := `# My post
+
+[![A thumbnail](images/thumb.png)](images/full.png)
+
+<img src=images/raw.png alt="Unquoted">
+
+<a href="./images/linked.png"><img src='images/linked-thumb.png' alt="Linked"></a>
+
+See [the other post](../other-post/) and [a site](https://example.com/images/remote.png).
+
+![Missing](images/missing.png)
+
+The diagram's source is in images/diagram.svg, and there's a copy at images/my%20photo.png.
+`
+	writeTestPost(t, writingRepoPath, "my-post", content,
+		"images/thumb.png",
+		"images/full.png",
+		"images/raw.png",
+		"images/linked.png",
+		"images/linked-thumb.png",
+		"images/diagram.svg",
+		"images/my photo.png",
+		"images/unused.png",
+	)
+
+	report, err := checkPostImages(writingRepoPath, "my-post")
+	if err != nil {
+		t.Fatalf("expected the post's images to be checked, got: %v", err)
+	}
+
+	expectedOrphans := []string{"images/unused.png"}
+	if !reflect.DeepEqual(report.Orphans, expectedOrphans) {
+		t.Errorf("expected only %v to be unreferenced, got: %v", expectedOrphans, report.Orphans)
+	}
+	if len(report.Problems) != 1 || report.Problems[0].Reference.Destination != "images/missing.png" {
+		t.Errorf("expected only the missing image to be a problem, got: %+v", report.Problems)
+	}
+}
@@ -0,0 +1,581 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/kurtosis-tech/stacktrace"
+	"github.com/spf13/cobra"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	extast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+const (
+	// Per-repo rule configuration, as comma-separated 'rule=severity' pairs (e.g. 'bare-url=off,trailing-whitespace=error')
+	LintRulesEnvVar = "LINT_RULES"
+
+	LintSeverityError   = "error"
+	LintSeverityWarning = "warning"
+	LintSeverityOff     = "off"
+
+	lintFormatHuman = "human"
+	lintFormatJSON  = "json"
+)
+
+// LintRule is a check that lint runs on every post
+type LintRule struct {
+	ID              string
+	Description     string
+	DefaultSeverity string
+
+	// Whether the rule counts towards the post's accessibility score
+	Accessibility bool
+}
+
+var lintRules = []LintRule{
+	{ID: "missing-title", Description: "The post has no title in its front matter or a leading '#' heading", DefaultSeverity: LintSeverityError},
+	{ID: "multiple-h1", Description: "There's more than one top-level heading", DefaultSeverity: LintSeverityWarning, Accessibility: true},
+	{ID: "heading-level-skip", Description: "A heading is more than one level deeper than the one before it", DefaultSeverity: LintSeverityWarning, Accessibility: true},
+	{ID: "empty-link", Description: "A link has no text or no destination", DefaultSeverity: LintSeverityError, Accessibility: true},
+	{ID: "vague-link-text", Description: "A link's text (e.g. 'click here') doesn't say where it goes", DefaultSeverity: LintSeverityWarning, Accessibility: true},
+	{ID: "missing-alt", Description: "An image has no alt text", DefaultSeverity: LintSeverityWarning, Accessibility: true},
+	{ID: "filename-alt", Description: "An image's alt text is its file name, or a word like 'image'", DefaultSeverity: LintSeverityWarning, Accessibility: true},
+	{ID: "table-missing-header", Description: "A table has no header row", DefaultSeverity: LintSeverityWarning, Accessibility: true},
+	{ID: "bare-url", Description: "A URL appears in the text without being made into a link", DefaultSeverity: LintSeverityWarning},
+	{ID: "trailing-whitespace", Description: "A line ends in whitespace", DefaultSeverity: LintSeverityWarning},
+	{ID: "list-marker-style", Description: "A bulleted list uses a different marker than the post's first one", DefaultSeverity: LintSeverityWarning},
+	{ID: "todo-marker", Description: "A line still has a TODO or TK marker", DefaultSeverity: LintSeverityError},
+	{ID: "unpublished-wiki-link", Description: "A [[post]] link points to a post that isn't published on " + MainBranchName, DefaultSeverity: LintSeverityError},
+	{ID: "template-placeholder", Description: "A line is unchanged from " + TemplateDirname + "/" + PostFilename, DefaultSeverity: LintSeverityError},
+}
+
+// LintIssue is a single problem that lint found in a post
+type LintIssue struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Post     string `json:"post"`
+	Line     int    `json:"line"`
+	Message  string `json:"message"`
+}
+
+var (
+	lintFormat    string
+	lintListRules bool
+
+	todoMarkerRegex = regexp.MustCompile(`\b(TODO|TK)\b`)
+	bareURLRegex    = regexp.MustCompile(`https?://[^\s<>()\[\]]+`)
+
+	htmlImageTagRegex   = regexp.MustCompile(`(?is)<img\b[^>]*>`)
+	htmlAltRegex        = regexp.MustCompile(`(?i)\balt\s*=`)
+	htmlTableRegex      = regexp.MustCompile(`(?is)<table\b.*?</table>`)
+	htmlTableHeadRegex  = regexp.MustCompile(`(?i)<th\b`)
+	imageFilenameRegex  = regexp.MustCompile(`(?i)^[\w.-]+\.(png|jpe?g|gif|webp|svg|heic|avif)$`)
+	vagueLinkTextRegex  = regexp.MustCompile(`(?i)^(click|click here|here|this|this link|link|this page|read more|learn more|more|see here)$`)
+	genericAltTextRegex = regexp.MustCompile(`(?i)^(image|img|photo|picture|pic|screenshot|graphic|figure|alt|alt text)$`)
+)
+
+// Bare URLs are left as text, rather than linkified, so that lint can find them
+var lintMarkdownParser = goldmark.New(
+	goldmark.WithExtensions(extension.Table, extension.Strikethrough, extension.TaskList),
+).Parser()
+
+var lintCmd = &cobra.Command{
+	Use:   "lint [post_dir...]",
+	Short: "Check posts for Markdown problems",
+	Long: `Parse each post's Markdown and report problems like a missing title, skipped heading levels,
+empty links, bare URLs, leftover TODO/TK markers, and text left unchanged from the template.
+With no posts given, lints the post in the current directory, else the posts changed on the
+current branch (or every post, on main). Each rule's severity can be changed, or the rule turned
+off, with ` + LintRulesEnvVar + ` in ` + EnvFilename + ` (e.g. 'bare-url=off,trailing-whitespace=error');
+run 'opwriting lint --list-rules' to see them all. Exits non-zero if any errors are found.`,
+	RunE: runLint,
+}
+
+func init() {
+	lintCmd.Flags().StringVar(&lintFormat, "format", lintFormatHuman, "Output format: 'human' or 'json'")
+	lintCmd.Flags().BoolVar(&lintListRules, "list-rules", false, "List every rule with its configured severity, and exit")
+}
+
+func runLint(cmd *cobra.Command, args []string) error {
+	if lintFormat != lintFormatHuman && lintFormat != lintFormatJSON {
+		return stacktrace.NewError("unrecognized format '%s'; use '%s' or '%s'", lintFormat, lintFormatHuman, lintFormatJSON)
+	}
+
+	severities, err := getLintSeverities()
+	if err != nil {
+		return err
+	}
+	if lintListRules {
+		for _, rule := range lintRules {
+			fmt.Printf("%-22s %-8s %s\n", rule.ID, severities[rule.ID], rule.Description)
+		}
+		return nil
+	}
+
+	writingRepoPath := os.Getenv(WritingDirEnvVar)
+	if writingRepoPath == "" {
+		return stacktrace.NewError("writing directory not configured: %s environment variable not set", WritingDirEnvVar)
+	}
+
+	postDirs, err := resolvePostDirs(writingRepoPath, args)
+	if err != nil {
+		return err
+	}
+
+	var issues []LintIssue
+	for _, postDir := range postDirs {
+		postIssues, err := lintPost(writingRepoPath, postDir, severities)
+		if err != nil {
+			return stacktrace.Propagate(err, "failed to lint post '%s'", postDir)
+		}
+		issues = append(issues, postIssues...)
+	}
+
+	if lintFormat == lintFormatJSON {
+		// An empty list rather than null, for the benefit of whatever's reading it
+		if issues == nil {
+			issues = []LintIssue{}
+		}
+		encoded, err := json.MarshalIndent(issues, "", "  ")
+		if err != nil {
+			return stacktrace.Propagate(err, "failed to encode lint issues")
+		}
+		fmt.Println(string(encoded))
+	} else {
+		printLintIssues(postDirs, issues)
+	}
+
+	errorCount := countLintErrors(issues)
+	if errorCount > 0 {
+		return stacktrace.NewError("found %d lint error(s)", errorCount)
+	}
+	return nil
+}
+
+// resolvePostDirs resolves the posts a command should work on, relative to the writing repo: the given ones,
+// else the one the user is in, else the ones changed on the current branch, else (on main) all of them
+func resolvePostDirs(writingRepoPath string, args []string) ([]string, error) {
+	absWritingRepoPath, err := filepath.Abs(writingRepoPath)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to get absolute path for writing directory")
+	}
+	currentDir, err := os.Getwd()
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to get current working directory")
+	}
+
+	toRepoRelative := func(path string) (string, error) {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(currentDir, path)
+		}
+		relPath, err := filepath.Rel(absWritingRepoPath, path)
+		if err != nil || strings.HasPrefix(relPath, "..") {
+			return "", stacktrace.NewError("'%s' isn't in the writing directory (%s)", path, absWritingRepoPath)
+		}
+		return relPath, nil
+	}
+
+	if len(args) > 0 {
+		var postDirs []string
+		for _, arg := range args {
+			postDir, err := toRepoRelative(strings.TrimSuffix(arg, "/"))
+			if err != nil {
+				return nil, err
+			}
+			postDirs = append(postDirs, postDir)
+		}
+		return postDirs, nil
+	}
+
+	if _, err := os.Stat(filepath.Join(currentDir, PostFilename)); err == nil {
+		postDir, err := toRepoRelative(currentDir)
+		if err != nil {
+			return nil, err
+		}
+		return []string{postDir}, nil
+	}
+
+	// Everything below works on the repo's branches, so run git from the repo
+	if err := os.Chdir(absWritingRepoPath); err != nil {
+		return nil, stacktrace.Propagate(err, "failed to change to writing directory: %s", absWritingRepoPath)
+	}
+	currentBranch, err := getCurrentBranch()
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to get current branch")
+	}
+
+	var postDirs []string
+	if currentBranch == MainBranchName {
+		posts, err := getPostDirsFromBranch(".", MainBranchName)
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "failed to get posts on %s", MainBranchName)
+		}
+		for _, post := range posts {
+			postDirs = append(postDirs, post.Dir)
+		}
+	} else {
+		affectedPosts, err := getAffectedPosts(currentBranch)
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "failed to find the posts changed on branch '%s'", currentBranch)
+		}
+		for _, post := range affectedPosts {
+			if post.Change != PostDeleted {
+				postDirs = append(postDirs, post.Dir)
+			}
+		}
+	}
+
+	// The template is full of placeholders by design
+	var filteredPostDirs []string
+	for _, postDir := range postDirs {
+		if postDir != TemplateDirname {
+			filteredPostDirs = append(filteredPostDirs, postDir)
+		}
+	}
+	sort.Strings(filteredPostDirs)
+	return filteredPostDirs, nil
+}
+
+// getLintSeverities returns the severity of every rule, with the defaults overridden by the config
+func getLintSeverities() (map[string]string, error) {
+	severities := map[string]string{}
+	for _, rule := range lintRules {
+		severities[rule.ID] = rule.DefaultSeverity
+	}
+
+	for _, setting := range splitConfigList(getConfigValue(LintRulesEnvVar)) {
+		ruleID, severity, found := strings.Cut(setting, "=")
+		ruleID = strings.TrimSpace(ruleID)
+		severity = strings.ToLower(strings.TrimSpace(severity))
+		if !found {
+			return nil, stacktrace.NewError("invalid %s entry '%s'; expected 'rule=severity'", LintRulesEnvVar, setting)
+		}
+		if _, known := severities[ruleID]; !known {
+			return nil, stacktrace.NewError("unrecognized lint rule '%s' in %s; run 'opwriting lint --list-rules' to see them all", ruleID, LintRulesEnvVar)
+		}
+		switch severity {
+		case LintSeverityError, LintSeverityWarning, LintSeverityOff:
+		default:
+			return nil, stacktrace.NewError("invalid severity '%s' for lint rule '%s'; use '%s', '%s', or '%s'", severity, ruleID, LintSeverityError, LintSeverityWarning, LintSeverityOff)
+		}
+		severities[ruleID] = severity
+	}
+	return severities, nil
+}
+
+// lintPost runs every rule that isn't turned off against the post, returning its issues in line order
+func lintPost(writingRepoPath string, postDir string, severities map[string]string) ([]LintIssue, error) {
+	postFilepath := filepath.Join(writingRepoPath, postDir, PostFilename)
+	rawContent, err := os.ReadFile(postFilepath)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to read post: %s", postFilepath)
+	}
+	content := strings.ReplaceAll(string(rawContent), "\r\n", "\n")
+
+	frontMatter, body, err := parsePost(content)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to parse post: %s", postFilepath)
+	}
+	// Line numbers are reported relative to the whole file, so the body's are offset by the front matter's
+	bodyLineOffset := strings.Count(content[:len(content)-len(body)], "\n")
+
+	var issues []LintIssue
+	report := func(ruleID string, line int, message string) {
+		severity := severities[ruleID]
+		if severity == LintSeverityOff {
+			return
+		}
+		issues = append(issues, LintIssue{
+			Rule:     ruleID,
+			Severity: severity,
+			Post:     postDir,
+			Line:     line,
+			Message:  message,
+		})
+	}
+
+	lines := strings.Split(content, "\n")
+	lintLines(lines, report)
+
+	templateLines, err := readTemplateLines(writingRepoPath)
+	if err != nil {
+		return nil, err
+	}
+	for idx, line := range lines {
+		if templateLines[strings.TrimSpace(line)] {
+			report("template-placeholder", idx+1, fmt.Sprintf("'%s' is unchanged from the template", strings.TrimSpace(line)))
+		}
+	}
+
+	source := []byte(body)
+	document := lintMarkdownParser.Parse(text.NewReader(source))
+	lineOf := func(node ast.Node) int {
+		return bodyLineOffset + lineOfOffset(source, findNodeOffset(node))
+	}
+	if strings.TrimSpace(frontMatter.Title) == "" && !hasLeadingH1(document) {
+		report("missing-title", 1, "the post has no title; add one to the front matter or start the post with a '#' heading")
+	}
+	lintMarkdownTree(document, source, lineOf, report)
+
+	for _, link := range findWikiLinks(body) {
+		if err := checkPostPublished(writingRepoPath, link.Target); err != nil {
+			report("unpublished-wiki-link", bodyLineOffset+lineOfOffset(source, link.Start), fmt.Sprintf("'%s' links to '%s', which isn't published on %s", body[link.Start:link.End], link.Target, MainBranchName))
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].Line < issues[j].Line
+	})
+	return issues, nil
+}
+
+// hasLeadingH1 returns whether the post's body starts with a top-level heading, which is the post's title when
+// the front matter doesn't give one
+func hasLeadingH1(document ast.Node) bool {
+	heading, ok := document.FirstChild().(*ast.Heading)
+	return ok && heading.Level == 1
+}
+
+// lintLines runs the rules that look at the raw text of each line
+func lintLines(lines []string, report func(ruleID string, line int, message string)) {
+	for idx, line := range lines {
+		if strings.TrimRight(line, " \t") != line {
+			report("trailing-whitespace", idx+1, "line ends in whitespace")
+		}
+		if marker := todoMarkerRegex.FindString(line); marker != "" {
+			report("todo-marker", idx+1, fmt.Sprintf("leftover '%s' marker", marker))
+		}
+	}
+}
+
+// lintMarkdownTree runs the rules that look at the post's structure
+func lintMarkdownTree(document ast.Node, source []byte, lineOf func(ast.Node) int, report func(ruleID string, line int, message string)) {
+	h1Count := 0
+	previousHeadingLevel := 0
+	var firstBulletMarker byte
+
+	ast.Walk(document, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		switch typedNode := node.(type) {
+		case *ast.Heading:
+			if typedNode.Level == 1 {
+				h1Count++
+				if h1Count > 1 {
+					report("multiple-h1", lineOf(node), "there's already a top-level heading; use '##' for sections")
+				}
+			}
+			if previousHeadingLevel > 0 && typedNode.Level > previousHeadingLevel+1 {
+				report("heading-level-skip", lineOf(node), fmt.Sprintf("heading level %d follows level %d", typedNode.Level, previousHeadingLevel))
+			}
+			previousHeadingLevel = typedNode.Level
+		case *ast.List:
+			if typedNode.IsOrdered() {
+				break
+			}
+			if firstBulletMarker == 0 {
+				firstBulletMarker = typedNode.Marker
+			} else if typedNode.Marker != firstBulletMarker {
+				report("list-marker-style", lineOf(node), fmt.Sprintf("list uses '%c' but the post's first list uses '%c'", typedNode.Marker, firstBulletMarker))
+			}
+		case *ast.Link:
+			linkText := strings.TrimSpace(string(nodeText(node, source)))
+			if linkText == "" {
+				report("empty-link", lineOf(node), fmt.Sprintf("link to '%s' has no text", string(typedNode.Destination)))
+			} else if strings.TrimSpace(string(typedNode.Destination)) == "" {
+				report("empty-link", lineOf(node), fmt.Sprintf("link '%s' has no destination", linkText))
+			} else if vagueLinkTextRegex.MatchString(strings.Trim(linkText, ".!:;,")) {
+				report("vague-link-text", lineOf(node), fmt.Sprintf("link text '%s' doesn't say where it goes, which is all screen reader users hear", linkText))
+			}
+			// The text of a link is allowed to be a URL
+			return ast.WalkSkipChildren, nil
+		case *ast.Image:
+			lintImageAltText(strings.TrimSpace(string(nodeText(node, source))), string(typedNode.Destination), lineOf(node), report)
+			return ast.WalkSkipChildren, nil
+		case *extast.TableHeader:
+			if strings.TrimSpace(string(nodeText(node, source))) == "" {
+				report("table-missing-header", lineOf(node), "the table's header row is empty; give each column a heading")
+			}
+		case *ast.HTMLBlock, *ast.RawHTML:
+			lintHTML(node, source, lineOf(node), report)
+			return ast.WalkSkipChildren, nil
+		case *ast.CodeSpan, *ast.FencedCodeBlock, *ast.CodeBlock, *ast.AutoLink:
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			segmentText := typedNode.Segment.Value(source)
+			if match := bareURLRegex.Find(segmentText); match != nil {
+				report("bare-url", lineOf(node), fmt.Sprintf("'%s' isn't a link; wrap it in <> or use [text](url)", string(match)))
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+// lintImageAltText checks that an image's alt text describes it
+func lintImageAltText(altText string, destination string, line int, report func(ruleID string, line int, message string)) {
+	if altText == "" {
+		report("missing-alt", line, fmt.Sprintf("image '%s' has no alt text; describe it for readers who can't see it", destination))
+		return
+	}
+
+	filename := path.Base(strings.SplitN(destination, "?", 2)[0])
+	isFilename := strings.EqualFold(altText, filename) ||
+		strings.EqualFold(altText, strings.TrimSuffix(filename, path.Ext(filename))) ||
+		imageFilenameRegex.MatchString(altText)
+	if isFilename || genericAltTextRegex.MatchString(altText) {
+		report("filename-alt", line, fmt.Sprintf("alt text '%s' doesn't describe the image", altText))
+	}
+}
+
+// lintHTML runs the accessibility rules against raw HTML, which is otherwise skipped
+func lintHTML(node ast.Node, source []byte, line int, report func(ruleID string, line int, message string)) {
+	var segments *text.Segments
+	switch typedNode := node.(type) {
+	case *ast.HTMLBlock:
+		segments = typedNode.Lines()
+	case *ast.RawHTML:
+		segments = typedNode.Segments
+	default:
+		return
+	}
+	var html []byte
+	for idx := 0; idx < segments.Len(); idx++ {
+		segment := segments.At(idx)
+		html = append(html, segment.Value(source)...)
+	}
+
+	// An empty alt attribute is how HTML marks an image as decorative, so only a missing one is reported
+	for _, tag := range htmlImageTagRegex.FindAll(html, -1) {
+		if !htmlAltRegex.Match(tag) {
+			report("missing-alt", line, `<img> tag has no alt attribute; describe the image, or use alt="" if it's decorative`)
+		}
+	}
+	for _, table := range htmlTableRegex.FindAll(html, -1) {
+		if !htmlTableHeadRegex.Match(table) {
+			report("table-missing-header", line, "the <table> has no <th> header cells")
+		}
+	}
+}
+
+// findNodeOffset returns the byte offset in the source where the node starts, going by its own position or
+// its first child's (since not every node records its position), else its parent's
+func findNodeOffset(node ast.Node) int {
+	if node.Type() == ast.TypeBlock && node.Lines().Len() > 0 {
+		return node.Lines().At(0).Start
+	}
+	if textNode, ok := node.(*ast.Text); ok {
+		return textNode.Segment.Start
+	}
+	for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+		if offset := findNodeOffset(child); offset >= 0 {
+			return offset
+		}
+	}
+	if node.Pos() >= 0 && node.Kind() != ast.KindDocument {
+		return node.Pos()
+	}
+	if node.Parent() != nil {
+		return findNodeOffset(node.Parent())
+	}
+	return -1
+}
+
+// lineOfOffset converts a byte offset in the source to a (1-based) line number
+func lineOfOffset(source []byte, offset int) int {
+	if offset < 0 {
+		return 1
+	}
+	if offset > len(source) {
+		offset = len(source)
+	}
+	return strings.Count(string(source[:offset]), "\n") + 1
+}
+
+// nodeText returns the plain text inside an inline node
+func nodeText(node ast.Node, source []byte) []byte {
+	var result []byte
+	for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+		if textNode, ok := child.(*ast.Text); ok {
+			result = append(result, textNode.Segment.Value(source)...)
+			continue
+		}
+		result = append(result, nodeText(child, source)...)
+	}
+	return result
+}
+
+// readTemplateLines returns the lines of the template post that are placeholder text (i.e. that contain
+// words), or nothing if the repo has no template
+func readTemplateLines(writingRepoPath string) (map[string]bool, error) {
+	templateFilepath := filepath.Join(writingRepoPath, TemplateDirname, PostFilename)
+	content, err := os.ReadFile(templateFilepath)
+	if os.IsNotExist(err) {
+		return map[string]bool{}, nil
+	}
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to read template: %s", templateFilepath)
+	}
+
+	wordRegex := regexp.MustCompile(`[A-Za-z]{2,}`)
+	templateLines := map[string]bool{}
+	for _, line := range strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		if wordRegex.MatchString(line) {
+			templateLines[line] = true
+		}
+	}
+	return templateLines, nil
+}
+
+func printLintIssues(postDirs []string, issues []LintIssue) {
+	if len(postDirs) == 0 {
+		fmt.Println("No posts to lint")
+		return
+	}
+
+	issuesByPost := map[string][]LintIssue{}
+	for _, issue := range issues {
+		issuesByPost[issue.Post] = append(issuesByPost[issue.Post], issue)
+	}
+
+	for _, postDir := range postDirs {
+		postIssues := issuesByPost[postDir]
+		if len(postIssues) == 0 {
+			fmt.Printf("✅ %s\n", filepath.Join(postDir, PostFilename))
+			continue
+		}
+
+		fmt.Printf("%s\n", filepath.Join(postDir, PostFilename))
+		for _, issue := range postIssues {
+			emoji := "⚠️ "
+			if issue.Severity == LintSeverityError {
+				emoji = "❌"
+			}
+			fmt.Printf("  %s %4d  %-20s %s\n", emoji, issue.Line, issue.Rule, issue.Message)
+		}
+	}
+
+	errorCount := countLintErrors(issues)
+	fmt.Printf("\n%d error(s), %d warning(s)\n", errorCount, len(issues)-errorCount)
+}
+
+func countLintErrors(issues []LintIssue) int {
+	errorCount := 0
+	for _, issue := range issues {
+		if issue.Severity == LintSeverityError {
+			errorCount++
+		}
+	}
+	return errorCount
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+
+	"github.com/kurtosis-tech/stacktrace"
+)
+
+const (
+	// Optional command to send notifications with instead of the OS's default, which gets called with the
+	// title and message as its two arguments (e.g. a script that posts to a chat webhook)
+	NotifyCommandEnvVar = "NOTIFY_COMMAND"
+)
+
+// Notifier tells the user about something that happened while they weren't watching the terminal
+type Notifier interface {
+	Notify(title string, message string) error
+}
+
+// commandNotifier sends notifications by running a command with the title and message as arguments
+type commandNotifier struct {
+	commandName string
+	buildArgs   func(title string, message string) []string
+}
+
+func (notifier *commandNotifier) Notify(title string, message string) error {
+	cmd := exec.Command(notifier.commandName, notifier.buildArgs(title, message)...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return stacktrace.NewError("failed to send notification using '%s': %s", notifier.commandName, string(output))
+	}
+	return nil
+}
+
+// printNotifier is used when there's no way to send desktop notifications, and just prints the notification
+type printNotifier struct{}
+
+func (notifier *printNotifier) Notify(title string, message string) error {
+	fmt.Printf("🔔 %s: %s\n", title, message)
+	return nil
+}
+
+// newNotifier creates the notifier that background publishes report to, and is swapped out in tests
+var newNotifier = newDesktopNotifier
+
+// newDesktopNotifier returns a notifier for the configured notification command, or the current OS's
+// notification mechanism if none is configured
+func newDesktopNotifier() Notifier {
+	if notifyCommand := getConfigValue(NotifyCommandEnvVar); notifyCommand != "" {
+		return &commandNotifier{
+			commandName: notifyCommand,
+			buildArgs: func(title string, message string) []string {
+				return []string{title, message}
+			},
+		}
+	}
+
+	switch runtime.GOOS {
+	case "darwin":
+		return &commandNotifier{
+			commandName: "osascript",
+			buildArgs: func(title string, message string) []string {
+				return []string{"-e", fmt.Sprintf("display notification %q with title %q", message, title)}
+			},
+		}
+	case "linux":
+		if _, err := exec.LookPath("notify-send"); err == nil {
+			return &commandNotifier{
+				commandName: "notify-send",
+				buildArgs: func(title string, message string) []string {
+					return []string{title, message}
+				},
+			}
+		}
+	}
+
+	return &printNotifier{}
+}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/kurtosis-tech/stacktrace"
+)
+
+const (
+	// Optional command to open rendered posts with instead of the OS's default, which gets called with the
+	// file's path as its last argument (e.g. 'firefox' or 'open -a "Google Chrome"')
+	BrowserCommandEnvVar = "BROWSER_COMMAND"
+)
+
+// Opener shows the user a file, e.g. in their browser
+type Opener interface {
+	// Open shows the file, returning a description of how it was shown
+	Open(path string) (string, error)
+}
+
+// commandOpener opens files by running a command with the file's path as the last argument
+type commandOpener struct {
+	commandName string
+	args        []string
+}
+
+func (opener *commandOpener) Open(path string) (string, error) {
+	cmd := exec.Command(opener.commandName, append(opener.args, path)...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return "", stacktrace.NewError("failed to open '%s' using '%s': %s", path, opener.commandName, string(output))
+	}
+	return fmt.Sprintf("Opened %s", path), nil
+}
+
+// printOpener is used when there's no way to show files graphically, and just prints the path so the user
+// can open it themselves
+type printOpener struct{}
+
+func (opener *printOpener) Open(path string) (string, error) {
+	return fmt.Sprintf("No browser available; open %s to see it", path), nil
+}
+
+// newOpener returns an opener for the configured browser command, or the current OS's default way of opening
+// files if none is configured
+func newOpener() Opener {
+	if fields := splitCommandLine(getConfigValue(BrowserCommandEnvVar)); len(fields) > 0 {
+		return &commandOpener{
+			commandName: fields[0],
+			args:        fields[1:],
+		}
+	}
+
+	switch runtime.GOOS {
+	case "darwin":
+		return &commandOpener{commandName: "open"}
+	case "windows":
+		// 'start' is built into cmd; its first quoted argument is the window title
+		return &commandOpener{commandName: "cmd", args: []string{"/c", "start", ""}}
+	default:
+		// Without a display, there's nothing for xdg-open to open the file in
+		hasDisplay := os.Getenv("DISPLAY") != "" || os.Getenv("WAYLAND_DISPLAY") != ""
+		if _, err := exec.LookPath("xdg-open"); err == nil && hasDisplay {
+			return &commandOpener{commandName: "xdg-open"}
+		}
+	}
+
+	return &printOpener{}
+}
+
+// splitCommandLine splits a command on whitespace, keeping double-quoted arguments together
+func splitCommandLine(command string) []string {
+	var fields []string
+	var current strings.Builder
+	inQuotes := false
+	hasField := false
+	for _, char := range command {
+		switch {
+		case char == '"':
+			inQuotes = !inQuotes
+			hasField = true
+		case (char == ' ' || char == '\t') && !inQuotes:
+			if hasField {
+				fields = append(fields, current.String())
+				current.Reset()
+				hasField = false
+			}
+		default:
+			current.WriteRune(char)
+			hasField = true
+		}
+	}
+	if hasField {
+		fields = append(fields, current.String())
+	}
+	return fields
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+
+	"github.com/kurtosis-tech/stacktrace"
+)
+
+const (
+	PRTitleTemplateEnvVar = "PR_TITLE_TEMPLATE"
+	PRBodyTemplateEnvVar  = "PR_BODY_TEMPLATE"
+
+	// Used when the branch touches no posts, or the first post has no title
+	defaultPRTitleTemplate = `{{if .Title}}{{.Title}}{{else}}{{.Branch}}{{end}}`
+
+	defaultPRBodyTemplate = `{{range .Posts}}- **{{.Title}}** ({{.Change}}, {{.WordCount}} words){{if .Summary}}: {{.Summary}}{{end}}
+{{end}}`
+)
+
+// PRTemplatePost is a post touched by the branch, as seen by the PR title and body templates
+type PRTemplatePost struct {
+	Dir       string
+	Title     string
+	Summary   string
+	WordCount int
+	Change    string
+}
+
+// PRTemplateData is what the PR title and body templates are rendered with. The top-level title, summary,
+// and word count are those of the branch's first post, since most branches touch only one.
+type PRTemplateData struct {
+	Branch    string
+	Title     string
+	Summary   string
+	WordCount int
+	Posts     []PRTemplatePost
+}
+
+// renderPRTitleAndBody renders the configured (or default) PR title and body templates using the front
+// matter of the posts the branch touches
+func renderPRTitleAndBody(branch string, affectedPosts []AffectedPost) (string, string, error) {
+	data, err := getPRTemplateData(branch, affectedPosts)
+	if err != nil {
+		return "", "", stacktrace.Propagate(err, "failed to gather the posts' details for the PR")
+	}
+
+	titleTemplate := getConfigValue(PRTitleTemplateEnvVar)
+	if titleTemplate == "" {
+		titleTemplate = defaultPRTitleTemplate
+	}
+	title, err := renderPRTemplate(PRTitleTemplateEnvVar, titleTemplate, data)
+	if err != nil {
+		return "", "", err
+	}
+
+	bodyTemplate := getConfigValue(PRBodyTemplateEnvVar)
+	if bodyTemplate == "" {
+		bodyTemplate = defaultPRBodyTemplate
+	}
+	body, err := renderPRTemplate(PRBodyTemplateEnvVar, bodyTemplate, data)
+	if err != nil {
+		return "", "", err
+	}
+
+	// Titles must be a single line
+	title = strings.Join(strings.Fields(title), " ")
+	if title == "" {
+		title = branch
+	}
+	return title, strings.TrimSpace(body), nil
+}
+
+func getPRTemplateData(branch string, affectedPosts []AffectedPost) (*PRTemplateData, error) {
+	data := &PRTemplateData{
+		Branch: branch,
+	}
+
+	for _, post := range affectedPosts {
+		// Deleted posts only exist on main
+		sourceBranch := branch
+		if post.Change == PostDeleted {
+			sourceBranch = MainBranchName
+		}
+
+		content, err := readPostFromBranch(".", sourceBranch, post.Dir)
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "failed to read post '%s'", post.Dir)
+		}
+		frontMatter, body, err := parsePost(content)
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "failed to parse post '%s'", post.Dir)
+		}
+
+		title := frontMatter.Title
+		if title == "" {
+			title = post.Dir
+		}
+		data.Posts = append(data.Posts, PRTemplatePost{
+			Dir:       post.Dir,
+			Title:     title,
+			Summary:   frontMatter.Summary,
+			WordCount: countWords(body),
+			Change:    post.Change.String(),
+		})
+	}
+
+	if len(data.Posts) > 0 {
+		data.Title = data.Posts[0].Title
+		data.Summary = data.Posts[0].Summary
+		data.WordCount = data.Posts[0].WordCount
+	}
+	return data, nil
+}
+
+func renderPRTemplate(name string, templateText string, data *PRTemplateData) (string, error) {
+	parsedTemplate, err := template.New(name).Parse(templateText)
+	if err != nil {
+		return "", stacktrace.Propagate(err, "failed to parse %s", name)
+	}
+
+	var rendered bytes.Buffer
+	if err := parsedTemplate.Execute(&rendered, data); err != nil {
+		return "", stacktrace.Propagate(err, "failed to render %s", name)
+	}
+	return rendered.String(), nil
+}
@@ -2,61 +2,158 @@ package cmd
 
 import (
 	"bufio"
-	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
-	"github.com/joho/godotenv"
 	"github.com/kurtosis-tech/stacktrace"
 	"github.com/spf13/cobra"
 )
 
+type PRBranch struct {
+	StatusCheckRollup []StatusCheck
+	Reviews           []Review
+	State             MergeRequestStateEnum
+}
+
+type PRStatusEnum int
+
 const (
-	DefaultSubstackURL = "<your Substack URL here>/post/new"
-	SubstackURLEnvVar  = "SUBSTACK_URL"
+	StatusPending PRStatusEnum = iota
+	StatusSuccess
+	StatusFailure
 )
 
-type PRStatusResponse struct {
-	CurrentBranch PRBranch `json:"currentBranch"`
-}
+type PostChangeEnum int
 
-type PRBranch struct {
-	StatusCheckRollup []StatusCheck `json:"statusCheckRollup"`
-	Reviews           []struct {
-		State string `json:"state"`
-	} `json:"reviews"`
-	State string `json:"state"`
+const (
+	PostAdded PostChangeEnum = iota
+	PostModified
+	PostDeleted
+)
+
+func (change PostChangeEnum) String() string {
+	switch change {
+	case PostAdded:
+		return "new"
+	case PostModified:
+		return "updated"
+	case PostDeleted:
+		return "deleted"
+	default:
+		return "unknown"
+	}
 }
 
-type StatusCheck struct {
-	Context string `json:"context"`
-	State   string `json:"state"`
+type AffectedPost struct {
+	Dir    string         `json:"dir"`
+	Change PostChangeEnum `json:"change"`
 }
 
-type PRStatusEnum int
+// PublishOptions controls how the PR is created and merged
+type PublishOptions struct {
+	MergeMethod string   `json:"merge_method"`
+	AutoMerge   bool     `json:"auto_merge"`
+	Draft       bool     `json:"draft"`
+	Labels      []string `json:"labels"`
+	Reviewers   []string `json:"reviewers"`
+
+	// How many approving reviews the PR needs before it's merged
+	RequiredApprovals int `json:"required_approvals"`
+
+	// Create the PR even if the local checks fail
+	Force bool `json:"force"`
+}
 
 const (
-	StatusPending PRStatusEnum = iota
-	StatusSuccess
-	StatusFailure
+	prStatusPollInterval = 10 * time.Second
+
+	// Config keys for publish's defaults, which the flags override
+	MergeMethodEnvVar = "MERGE_METHOD"
+	AutoMergeEnvVar   = "AUTO_MERGE"
+	PRLabelsEnvVar    = "PR_LABELS"
+	PRReviewersEnvVar = "PR_REVIEWERS"
+
+	RequiredApprovalsEnvVar = "REQUIRED_APPROVALS"
+)
+
+var (
+	publishTimeout     time.Duration
+	publishDetach      bool
+	publishDryRun      bool
+	publishResume      bool
+	publishWatchBranch string
+	publishMergeMethod string
+	publishAutoMerge   bool
+	publishDraft       bool
+	publishLabels      []string
+	publishReviewers   []string
+	publishApprovals   int
+	publishForce       bool
+
+	// Makes waits check the PR once and report that they were stopped if it isn't ready yet, for callers that
+	// mustn't block (e.g. the scheduler, which checks again on its next run)
+	publishPollOnce bool
 )
 
 var publishCmd = &cobra.Command{
-	Use:   "publish",
+	Use:   "publish [--resume [branch]]",
 	Short: "Create or manage a PR for the current branch",
 	Long: `Create a pull request for the current branch and monitor its status.
+Before the PR is created, the local checks from 'opwriting check' are run against the
+branch's posts, and publishing stops if any fail unless --force is given.
 Errors if on main branch or a branch already merged into main.
-Waits for checks to pass and can be interrupted at any time.`,
+Waits for the required checks to pass and can be interrupted at any time; stops with
+a failure report if a required check fails. With --detach, monitoring happens in the
+background and a desktop notification is sent when the post merges or fails; use
+'publish status' to see all in-flight publishes.
+Every post touched by the branch is handled after merging: new posts are published,
+modified posts (e.g. from 'revise' branches) are updated, and deleted posts are removed.
+The PR's title and body are rendered from the posts' front matter, and the merge method, auto-merge,
+labels, reviewers, and the number of approvals to wait for can be set in the config or with flags. With --draft, the PR is opened as a draft
+for review and left there; run publish again without --draft to mark it ready and merge it.
+If the posts are scheduled for later (see 'opwriting schedule'), the PR is opened and left for
+'opwriting scheduler run' to merge and publish once they're due.
+Use --dry-run to see every step that publishing would take without taking any of them.
+Progress is recorded as each step completes, so a publish that fails or is interrupted partway
+(even after merging) can be finished with --resume, which skips the steps already done.`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: publishPR,
 }
 
+func init() {
+	publishCmd.Flags().DurationVar(&publishTimeout, "timeout", 0, "How long to wait for checks to finish before giving up (e.g. '30m'); 0 waits forever")
+	publishCmd.Flags().BoolVar(&publishDetach, "detach", false, "Monitor the PR in a background process instead of blocking the terminal")
+	publishCmd.Flags().BoolVar(&publishDryRun, "dry-run", false, "Print every step publishing would take without doing anything")
+	publishCmd.Flags().BoolVar(&publishResume, "resume", false, "Finish an unfinished publish (of the given branch, the current branch, or the only unfinished one)")
+	publishCmd.Flags().StringVar(&publishMergeMethod, "merge-method", "", "How to merge the PR: 'merge', 'squash', or 'rebase' (default from "+MergeMethodEnvVar+", else 'merge')")
+	publishCmd.Flags().BoolVar(&publishAutoMerge, "auto-merge", false, "Have the forge merge the PR once its checks pass, instead of merging it ourselves (default from "+AutoMergeEnvVar+")")
+	publishCmd.Flags().BoolVar(&publishDraft, "draft", false, "Open the PR as a draft for review, and stop there")
+	publishCmd.Flags().StringSliceVar(&publishLabels, "label", nil, "Label to add to the PR; can be repeated (default from "+PRLabelsEnvVar+")")
+	publishCmd.Flags().StringSliceVar(&publishReviewers, "reviewer", nil, "User to request a review from; can be repeated (default from "+PRReviewersEnvVar+")")
+	publishCmd.Flags().BoolVar(&publishForce, "force", false, "Create the PR even if the local checks (see 'opwriting check') fail")
+	publishCmd.Flags().IntVar(&publishApprovals, "required-approvals", 0, "Number of approving reviews to wait for before merging (default from "+RequiredApprovalsEnvVar+")")
+
+	// Used internally by --detach to start the background watcher
+	publishCmd.Flags().StringVar(&publishWatchBranch, watchBranchFlag, "", "")
+	publishCmd.Flags().MarkHidden(watchBranchFlag)
+
+	publishCmd.AddCommand(publishStatusCmd)
+}
+
 func publishPR(cmd *cobra.Command, args []string) error {
+	// Detached publishes re-run this command in the background to do the monitoring
+	if publishWatchBranch != "" {
+		return runPublishWatcher(publishWatchBranch)
+	}
+
 	// Validate we're in the writing directory
 	if err := validateWritingDirectory(); err != nil {
 		return stacktrace.Propagate(err, "directory validation failed")
@@ -68,41 +165,196 @@ func publishPR(cmd *cobra.Command, args []string) error {
 		return stacktrace.Propagate(err, "failed to get current branch")
 	}
 
+	if publishResume {
+		branchToResume := ""
+		if len(args) > 0 {
+			branchToResume = args[0]
+		}
+		return resumePublish(branchToResume, currentBranch)
+	}
+	if len(args) > 0 {
+		return stacktrace.NewError("a branch can only be given with --resume; to publish a branch, check it out first")
+	}
+
 	// Check if on main branch
 	if currentBranch == "main" {
 		return stacktrace.NewError("cannot publish from main branch")
 	}
 
+	options, err := getPublishOptions(cmd)
+	if err != nil {
+		return stacktrace.Propagate(err, "invalid publish options")
+	}
+
+	forge, err := newForgeForCurrentRepo()
+	if err != nil {
+		return stacktrace.Propagate(err, "failed to create forge client")
+	}
+
 	// Check if branch is already merged into main
-	merged, err := isBranchMerged(currentBranch)
+	merged, err := isBranchMerged(forge, currentBranch)
 	if err != nil {
 		return stacktrace.Propagate(err, "failed to check if branch is merged")
 	}
 	if merged {
+		// A publish that failed after merging can still be finished
+		if state, err := loadPublishState(currentBranch); err == nil && isPublishResumable(state) {
+			return stacktrace.NewError("branch '%s' is already merged into main, but publishing it didn't finish; run 'opwriting publish --resume' to pick up where it left off", currentBranch)
+		}
 		return stacktrace.NewError("branch '%s' is already merged into main", currentBranch)
 	}
 
-	// Check if PR already exists
-	prURL, err := getPRForBranch(currentBranch)
+	// Work out everything that publishing will involve before doing any of it
+	publishCtx, err := newPublishContext(forge, currentBranch, options)
+	if err != nil {
+		return stacktrace.Propagate(err, "failed to plan publish")
+	}
+	plan := buildPublishPlan(publishCtx, publishDetach)
+
+	if publishDryRun {
+		fmt.Printf("Publishing '%s' would take the following steps (dry run; nothing has been changed):\n", currentBranch)
+		plan.Print()
+		return nil
+	}
+
+	// Starting over replaces any earlier attempt's progress, unless it's still being worked on
+	if existingState, err := loadPublishState(currentBranch); err == nil && isPublishInProgress(existingState) {
+		return stacktrace.NewError("branch '%s' is already being published (PID %d); see 'opwriting publish status'", currentBranch, existingState.PID)
+	}
+
+	plan.state = newPublishState(publishCtx)
+	plan.state.PID = os.Getpid()
+	return executeTrackedPlan(plan)
+}
+
+// getPublishOptions combines the publish flags with the defaults from the config, with flags taking precedence
+func getPublishOptions(cmd *cobra.Command) (PublishOptions, error) {
+	options, err := getConfigPublishOptions()
 	if err != nil {
-		return stacktrace.Propagate(err, "failed to check for existing PR")
+		return PublishOptions{}, err
+	}
+
+	options.Draft = publishDraft
+	options.Force = publishForce
+	if cmd.Flags().Changed("merge-method") {
+		options.MergeMethod = publishMergeMethod
+	}
+	if cmd.Flags().Changed("auto-merge") {
+		options.AutoMerge = publishAutoMerge
+	}
+	if cmd.Flags().Changed("label") {
+		options.Labels = publishLabels
 	}
+	if cmd.Flags().Changed("reviewer") {
+		options.Reviewers = publishReviewers
+	}
+	if cmd.Flags().Changed("required-approvals") {
+		options.RequiredApprovals = publishApprovals
+	}
+	return validatePublishOptions(options)
+}
 
-	if prURL == "" {
-		// Create new PR
-		fmt.Printf("Creating PR for branch '%s'...\n", currentBranch)
-		prURL, err = createPR(currentBranch)
+// getConfigPublishOptions reads the publish defaults from the config, for publishes started without flags
+// (e.g. by the scheduler)
+func getConfigPublishOptions() (PublishOptions, error) {
+	options := PublishOptions{
+		MergeMethod: getConfigValue(MergeMethodEnvVar),
+		AutoMerge:   strings.EqualFold(getConfigValue(AutoMergeEnvVar), "true"),
+		Labels:      splitConfigList(getConfigValue(PRLabelsEnvVar)),
+		Reviewers:   splitConfigList(getConfigValue(PRReviewersEnvVar)),
+	}
+
+	if requiredApprovals := getConfigValue(RequiredApprovalsEnvVar); requiredApprovals != "" {
+		parsed, err := strconv.Atoi(requiredApprovals)
 		if err != nil {
-			return stacktrace.Propagate(err, "failed to create PR")
+			return PublishOptions{}, stacktrace.Propagate(err, "%s must be a number, but was '%s'", RequiredApprovalsEnvVar, requiredApprovals)
+		}
+		options.RequiredApprovals = parsed
+	}
+	return validatePublishOptions(options)
+}
+
+// validatePublishOptions checks the options and fills in the defaults for any that weren't set
+func validatePublishOptions(options PublishOptions) (PublishOptions, error) {
+	if options.RequiredApprovals < 0 {
+		return PublishOptions{}, stacktrace.NewError("the number of required approvals can't be negative")
+	}
+
+	if options.MergeMethod == "" {
+		options.MergeMethod = MergeMethodMerge
+	}
+	if err := validateMergeMethod(options.MergeMethod); err != nil {
+		return PublishOptions{}, err
+	}
+	return options, nil
+}
+
+// splitConfigList splits a comma-separated config value into its trimmed, non-empty items
+func splitConfigList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
 		}
-		fmt.Printf("PR created: %s\n", prURL)
-	} else {
-		fmt.Printf("Found existing PR: %s\n", prURL)
+	}
+	return items
+}
+
+// resumePublish finishes an earlier publish that failed or was interrupted, skipping the steps it completed
+func resumePublish(branch string, currentBranch string) error {
+	state, err := findPublishToResume(branch, currentBranch)
+	if err != nil {
+		return stacktrace.Propagate(err, "failed to find a publish to resume")
+	}
+	if isPublishInProgress(state) {
+		return stacktrace.NewError("branch '%s' is still being published (PID %d); see 'opwriting publish status'", state.Branch, state.PID)
+	}
+	if state.Status == PublishStatusPublished {
+		return stacktrace.NewError("publishing branch '%s' already finished", state.Branch)
+	}
+	if state.Status == PublishStatusScheduled {
+		return stacktrace.NewError("branch '%s' is scheduled to be published at %s by 'opwriting scheduler run'; to publish it now, clear its schedule with 'opwriting schedule --clear' and publish again", state.Branch, formatScheduledTime(state.PublishAt))
+	}
+	// A draft's plan stops at leaving the PR as a draft, so resuming it would mark it published without merging it
+	if state.Status == PublishStatusDraft {
+		return stacktrace.NewError("branch '%s' was left as a draft PR, so there's nothing to resume; run 'opwriting publish' again without --draft to mark it ready and merge it", state.Branch)
+	}
+
+	forge, err := newForgeForCurrentRepo()
+	if err != nil {
+		return stacktrace.Propagate(err, "failed to create forge client")
+	}
+
+	publishCtx, err := newPublishContextFromState(forge, state)
+	if err != nil {
+		return stacktrace.Propagate(err, "failed to load publish of branch '%s'", state.Branch)
+	}
+	// Resuming always finishes in the foreground, including the local cleanup a background watcher may have skipped
+	plan := buildPublishPlan(publishCtx, false)
+	plan.state = state
+
+	if publishDryRun {
+		fmt.Printf("Resuming the publish of '%s' would take the following steps (dry run; nothing has been changed):\n", state.Branch)
+		plan.Print()
+		return nil
+	}
+
+	fmt.Printf("Resuming the publish of '%s'...\n", state.Branch)
+	state.PID = os.Getpid()
+	return executeTrackedPlan(plan)
+}
+
+// executeTrackedPlan runs a plan that records its progress, marking the publish finished if every step ran
+func executeTrackedPlan(plan *PublishPlan) error {
+	if err := plan.Execute(); err != nil {
+		return err
 	}
 
-	// Monitor PR status
-	fmt.Println("Monitoring PR status...")
-	return monitorPRStatus(currentBranch)
+	// Otherwise the publish was interrupted, or handed off to a background watcher
+	if plan.state.Status != PublishStatusRunning {
+		return nil
+	}
+	return plan.saveProgress(PublishStatusPublished, "Merged and published")
 }
 
 func getCurrentBranch() (string, error) {
@@ -114,138 +366,213 @@ func getCurrentBranch() (string, error) {
 	return strings.TrimSpace(string(output)), nil
 }
 
-func isBranchMerged(branch string) (bool, error) {
-	cmd := exec.Command("git", "branch", "--merged", "main")
-	output, err := cmd.Output()
+// isBranchMerged asks the forge whether the branch's PR was merged, since squash and rebase merges leave the
+// branch's own commits out of main and so git can't tell
+func isBranchMerged(forge Forge, branch string) (bool, error) {
+	pullRequest, err := getPRForBranch(forge, branch)
 	if err != nil {
-		return false, stacktrace.Propagate(err, "failed to check merged branches")
+		return false, err
 	}
+	return pullRequest != nil && pullRequest.State == MergeRequestMerged, nil
+}
 
-	scanner := bufio.NewScanner(strings.NewReader(string(output)))
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		// Remove * prefix for current branch
-		if strings.HasPrefix(line, "*") {
-			line = strings.TrimSpace(line[1:])
-		}
-		if line == branch {
-			return true, nil
-		}
+// getPRForBranch returns the branch's open or merged PR, or nil if no such PR exists
+func getPRForBranch(forge Forge, branch string) (*MergeRequest, error) {
+	pullRequest, err := forge.FindMergeRequest(branch)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to look up PR for branch '%s' on %s", branch, forge.Name())
 	}
-	return false, nil
+	return pullRequest, nil
 }
 
-func getPRForBranch(branch string) (string, error) {
-	cmd := exec.Command("gh", "pr", "view", branch, "--json", "url")
-	output, err := cmd.Output()
+// pushBranch pushes the branch to origin, since it must exist on the forge before a PR can be opened from it
+func pushBranch(branch string) error {
+	pushCmd := exec.Command("git", "push", "--set-upstream", "origin", branch)
+	if output, err := pushCmd.CombinedOutput(); err != nil {
+		return stacktrace.NewError("failed to push branch '%s': %s", branch, string(output))
+	}
+	return nil
+}
+
+func createPR(forge Forge, branch string, options MergeRequestOptions) (*MergeRequest, error) {
+	pullRequest, err := forge.CreateMergeRequest(branch, MainBranchName, options)
 	if err != nil {
-		// If gh pr view fails, likely no PR exists
-		return "", nil
+		return nil, stacktrace.Propagate(err, "failed to create PR on %s", forge.Name())
 	}
+	return pullRequest, nil
+}
+
+// waitForChecks polls the PR until its required checks pass (returning true) or the user interrupts
+// the wait (returning false), erroring if a required check fails or the timeout expires. With untilMerged,
+// it keeps waiting after the checks pass until the forge has merged the PR (i.e. for auto-merge).
+func waitForChecks(forge Forge, prNumber int, untilMerged bool) (bool, error) {
+	fmt.Println("Waiting for checks to pass (Ctrl+C to stop monitoring)...")
 
-	var pr struct {
-		URL string `json:"url"`
+	var blockingChecks []StatusCheck
+	poll := func() (bool, error) {
+		var status PRStatusEnum
+		status, blockingChecks = checkPRStatusOnce(forge, prNumber)
+		switch status {
+		case StatusSuccess:
+			if !untilMerged {
+				return true, nil
+			}
+			pullRequest, err := forge.GetMergeRequest(prNumber)
+			if err != nil {
+				fmt.Printf("Error getting PR: %v\n", err)
+				return false, nil
+			}
+			switch pullRequest.State {
+			case MergeRequestMerged:
+				return true, nil
+			case MergeRequestClosed:
+				return false, stacktrace.NewError("the PR was closed without being merged")
+			}
+			fmt.Printf("Checks passed; waiting for %s to merge the PR...\n", forge.Name())
+		case StatusFailure:
+			printFailureReport(blockingChecks)
+			return false, stacktrace.NewError("%d required check(s) failed; fix them and run publish again", len(blockingChecks))
+		}
+		return false, nil
 	}
-	if err := json.Unmarshal(output, &pr); err != nil {
-		return "", stacktrace.Propagate(err, "failed to parse PR JSON")
+	describePending := func() string {
+		var pendingContexts []string
+		for _, check := range blockingChecks {
+			pendingContexts = append(pendingContexts, check.Context)
+		}
+		return fmt.Sprintf("checks to finish; still pending: %v", pendingContexts)
 	}
 
-	return pr.URL, nil
+	return pollPR(poll, describePending)
 }
 
-func createPR(branch string) (string, error) {
-	cmd := exec.Command("gh", "pr", "create", "--title", branch, "--body", "")
-	output, err := cmd.Output()
-	if err != nil {
-		return "", stacktrace.Propagate(err, "failed to create PR")
+// waitForApprovals polls the PR until it has the required number of approving reviews and nobody is
+// requesting changes (returning true) or the user interrupts the wait (returning false), erroring if the
+// timeout expires
+func waitForApprovals(forge Forge, prNumber int, requiredApprovals int) (bool, error) {
+	fmt.Printf("Waiting for %d approving review(s) (Ctrl+C to stop monitoring)...\n", requiredApprovals)
+
+	var approvers []string
+	poll := func() (bool, error) {
+		status, err := getPRStatus(forge, prNumber)
+		if err != nil {
+			fmt.Printf("Error getting PR status: %v\n", err)
+			return false, nil // Continue monitoring on error
+		}
+		if status.State == MergeRequestMerged {
+			fmt.Println("PR has been merged externally, proceeding with cleanup...")
+			return true, nil
+		}
+
+		var changesRequestedBy []string
+		approvers, changesRequestedBy = summarizeReviews(status.Reviews)
+		fmt.Printf("👍 %d/%d approvals", len(approvers), requiredApprovals)
+		if len(approvers) > 0 {
+			fmt.Printf(" (%s)", strings.Join(approvers, ", "))
+		}
+		fmt.Println()
+		if len(changesRequestedBy) > 0 {
+			fmt.Printf("✋ Changes requested by %s; run 'opwriting review comments' to see the feedback\n", strings.Join(changesRequestedBy, ", "))
+		}
+
+		return len(approvers) >= requiredApprovals && len(changesRequestedBy) == 0, nil
 	}
-	return strings.TrimSpace(string(output)), nil
+	describePending := func() string {
+		return fmt.Sprintf("%d approving review(s); have %d", requiredApprovals, len(approvers))
+	}
+
+	return pollPR(poll, describePending)
 }
 
-func monitorPRStatus(branch string) error {
+// pollPR calls poll immediately and then on every poll interval until it reports that it's done (returning
+// true) or the user interrupts the wait (returning false), erroring if poll errors or the timeout expires
+func pollPR(poll func() (bool, error), describePending func() string) (bool, error) {
 	// Set up interrupt handler
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(c)
 
-	fmt.Println("Waiting for checks to pass (Ctrl+C to stop monitoring)...")
-
-	// Check immediately first
-	if checkPRStatusOnce(branch) {
-		return handleSuccessfulChecks(branch)
+	// A zero timeout means waiting forever, which a nil channel does
+	var timeout <-chan time.Time
+	if publishTimeout > 0 {
+		timeout = time.After(publishTimeout)
 	}
 
-	ticker := time.NewTicker(10 * time.Second)
+	ticker := time.NewTicker(prStatusPollInterval)
 	defer ticker.Stop()
 
 	for {
+		// Check immediately first
+		done, err := poll()
+		if err != nil {
+			return false, err
+		}
+		if done {
+			return true, nil
+		}
+		if publishPollOnce {
+			return false, nil
+		}
+
 		select {
 		case <-c:
 			fmt.Println("\nMonitoring interrupted by user")
-			return nil
+			return false, nil
+		case <-timeout:
+			return false, stacktrace.NewError("timed out after %v waiting for %s", publishTimeout, describePending())
 		case <-ticker.C:
-			if checkPRStatusOnce(branch) {
-				return handleSuccessfulChecks(branch)
-			}
 		}
 	}
 }
 
-func checkPRStatusOnce(branch string) bool {
-	status, err := getPRStatus()
+// checkPRStatusOnce prints the PR's checks and evaluates them, returning the checks that are blocking
+// the merge (i.e. the failed ones on failure and the pending ones while pending)
+func checkPRStatusOnce(forge Forge, prNumber int) (PRStatusEnum, []StatusCheck) {
+	status, err := getPRStatus(forge, prNumber)
 	if err != nil {
 		fmt.Printf("Error getting PR status: %v\n", err)
-		return false // Continue monitoring on error
+		return StatusPending, nil // Continue monitoring on error
 	}
 
 	// Check if PR has been merged externally
-	if status.State == "MERGED" {
+	if status.State == MergeRequestMerged {
 		fmt.Println("PR has been merged externally, proceeding with cleanup...")
-		return true
+		return StatusSuccess, nil
 	}
 
 	if len(status.StatusCheckRollup) == 0 {
 		fmt.Println("No status checks found, continuing to wait...")
-		return false
+		return StatusPending, nil
 	}
 
-	allPassed := true
-	hasFailure := false
-
 	for _, check := range status.StatusCheckRollup {
-		switch check.State {
-		case "SUCCESS":
-			fmt.Printf("✅ %s: %s\n", check.Context, check.State)
-		case "FAILURE", "ERROR":
-			fmt.Printf("❌ %s: %s\n", check.Context, check.State)
-			hasFailure = true
-			allPassed = false
-		default:
-			fmt.Printf("⏳ %s: %s\n", check.Context, check.State)
-			allPassed = false
-		}
+		printCheck(check)
 	}
 
-	if hasFailure {
-		return false
-	}
-	
-	return allPassed
+	return evaluateChecks(status.StatusCheckRollup)
 }
 
+func getPRStatus(forge Forge, prNumber int) (*PRBranch, error) {
+	pullRequest, err := forge.GetMergeRequest(prNumber)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to get PR")
+	}
 
-func getPRStatus() (*PRBranch, error) {
-	cmd := exec.Command("gh", "pr", "status", "--json", "statusCheckRollup,reviews,state")
-	output, err := cmd.Output()
+	checks, err := forge.GetChecks(pullRequest)
 	if err != nil {
-		return nil, stacktrace.Propagate(err, "failed to get PR status")
+		return nil, stacktrace.Propagate(err, "failed to get PR checks")
 	}
 
-	var statusResponse PRStatusResponse
-	if err := json.Unmarshal(output, &statusResponse); err != nil {
-		return nil, stacktrace.Propagate(err, "failed to parse PR status JSON")
+	reviews, err := forge.GetReviews(prNumber)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to get PR reviews")
 	}
 
-	return &statusResponse.CurrentBranch, nil
+	return &PRBranch{
+		StatusCheckRollup: checks,
+		Reviews:           reviews,
+		State:             pullRequest.State,
+	}, nil
 }
 
 func validateWritingDirectory() error {
@@ -280,86 +607,53 @@ func validateWritingDirectory() error {
 
 	// If relative path starts with "..", we're outside the writing directory
 	if strings.HasPrefix(relPath, "..") {
-		return stacktrace.NewError("must run publish command from within the writing directory (%s) or one of its subdirectories", absWritingDir)
+		return stacktrace.NewError("must run this command from within the writing directory (%s) or one of its subdirectories", absWritingDir)
 	}
 
 	return nil
 }
 
-func getSubstackURL() string {
-	// Check if env file exists
-	if _, err := os.Stat(EnvFilename); os.IsNotExist(err) {
-		return DefaultSubstackURL
+func publishAffectedPost(publisher Publisher, post AffectedPost) error {
+	switch post.Change {
+	case PostAdded:
+		fmt.Printf("\n📝 New post: %s\n", post.Dir)
+		warnAboutAccessibility(post.Dir)
+		return publisher.PublishNewPost(post.Dir)
+	case PostModified:
+		fmt.Printf("\n✏️  Updated post: %s\n", post.Dir)
+		warnAboutAccessibility(post.Dir)
+		return publisher.UpdatePost(post.Dir)
+	case PostDeleted:
+		fmt.Printf("\n🗑️  Deleted post: %s\n", post.Dir)
+		return publisher.RemovePost(post.Dir)
+	default:
+		return stacktrace.NewError("unrecognized change kind '%d' for post '%s'", post.Change, post.Dir)
 	}
-
-	// Load env file using godotenv
-	envVars, err := godotenv.Read(EnvFilename)
-	if err != nil {
-		return DefaultSubstackURL
-	}
-
-	// Get SUBSTACK_URL from env vars
-	if url, exists := envVars[SubstackURLEnvVar]; exists && url != "" {
-		return url + "/publish/post?type=newsletter"
-	}
-
-	return DefaultSubstackURL
 }
 
-func handleSuccessfulChecks(branch string) error {
-	fmt.Println("Merging PR and cleaning up...")
-
-	// Find the post directory that was added in this branch (before deleting branch)
-	postDir, err := getAddedPostDirectory()
+func mergePR(forge Forge, prNumber int, mergeMethod string) error {
+	// The PR may already have been merged externally
+	pullRequest, err := forge.GetMergeRequest(prNumber)
 	if err != nil {
-		return stacktrace.Propagate(err, "failed to find added post directory")
-	}
-
-	// Merge PR and delete remote branch
-	if err := mergePR(branch); err != nil {
-		return stacktrace.Propagate(err, "failed to merge PR")
-	}
-
-	// Switch to main branch
-	if err := switchToMain(); err != nil {
-		return stacktrace.Propagate(err, "failed to switch to main branch")
-	}
-
-	// Pull latest changes
-	if err := pullMain(); err != nil {
-		return stacktrace.Propagate(err, "failed to pull main branch")
-	}
-
-	// Delete local branch
-	if err := deleteLocalBranch(branch); err != nil {
-		return stacktrace.Propagate(err, "failed to delete local branch")
+		return stacktrace.Propagate(err, "failed to get PR")
 	}
-
-	// Open post in Chrome
-	if err := openPostInChrome(postDir); err != nil {
-		return stacktrace.Propagate(err, "failed to open post in Chrome")
+	if pullRequest.State == MergeRequestMerged {
+		fmt.Println("PR already merged")
+		return nil
 	}
 
-	// Print Substack URL
-	substackURL := getSubstackURL()
-	fmt.Println("\nPaste the rendered Markdown output into:")
-	fmt.Println(substackURL)
-
-	// Show tip if using placeholder URL
-	if substackURL == DefaultSubstackURL {
-		fmt.Printf("\n💡 Tip: Create a %s file with %s=https://yourname.substack.com to get a working link.\n", EnvFilename, SubstackURLEnvVar)
+	if err := forge.Merge(prNumber, mergeMethod); err != nil {
+		return stacktrace.Propagate(err, "failed to merge PR")
 	}
-
+	fmt.Println("PR merged")
 	return nil
 }
 
-func mergePR(branch string) error {
-	cmd := exec.Command("gh", "pr", "merge", branch, "--merge", "--delete-branch")
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return stacktrace.NewError("failed to merge PR: %s", string(output))
+func deleteRemoteBranch(forge Forge, branch string) error {
+	if err := forge.DeleteBranch(branch); err != nil {
+		return stacktrace.Propagate(err, "failed to delete remote branch")
 	}
-	fmt.Println("PR merged and remote branch deleted")
+	fmt.Println("Deleted remote branch")
 	return nil
 }
 
@@ -397,8 +691,9 @@ func deleteLocalBranch(branch string) error {
 		return nil
 	}
 
-	// Branch exists, try to delete it
-	cmd = exec.Command("git", "branch", "-d", branch)
+	// Branch exists, so force-delete it: the forge has confirmed the merge by now, but after a squash or rebase
+	// merge the branch's commits aren't in main, so 'git branch -d' would refuse
+	cmd = exec.Command("git", "branch", "-D", branch)
 	output, err = cmd.CombinedOutput()
 	if err != nil {
 		return stacktrace.NewError("failed to delete local branch: %s", string(output))
@@ -407,45 +702,84 @@ func deleteLocalBranch(branch string) error {
 	return nil
 }
 
-func getAddedPostDirectory() (string, error) {
-	// Get files that were added in this branch compared to main
-	cmd := exec.Command("git", "diff", "--name-only", "--diff-filter=A", "main...HEAD")
+// getAffectedPosts determines which posts were added, modified, or deleted on the branch relative to
+// main, based on every changed file that lives under a post directory (including images)
+func getAffectedPosts(branch string) ([]AffectedPost, error) {
+	return getRepoAffectedPosts(".", branch)
+}
+
+// getRepoAffectedPosts is getAffectedPosts for the repo at the given path
+func getRepoAffectedPosts(repoPath string, branch string) ([]AffectedPost, error) {
+	mainPosts, err := getPostDirsFromBranch(repoPath, MainBranchName)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to get posts on %s", MainBranchName)
+	}
+	headPosts, err := getPostDirsFromBranch(repoPath, branch)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to get posts on branch '%s'", branch)
+	}
+
+	isOnMain := make(map[string]bool)
+	for _, post := range mainPosts {
+		isOnMain[post.Dir] = true
+	}
+	isOnHead := make(map[string]bool)
+	for _, post := range headPosts {
+		isOnHead[post.Dir] = true
+	}
+
+	// Every post directory that exists on either side, longest first so nested directories win
+	var allPostDirs []string
+	for dir := range isOnMain {
+		allPostDirs = append(allPostDirs, dir)
+	}
+	for dir := range isOnHead {
+		if !isOnMain[dir] {
+			allPostDirs = append(allPostDirs, dir)
+		}
+	}
+	sort.Slice(allPostDirs, func(i, j int) bool {
+		return len(allPostDirs[i]) > len(allPostDirs[j])
+	})
+
+	// Get all files that changed in this branch compared to main (renames are reported as delete + add)
+	cmd := exec.Command("git", "-C", repoPath, "diff", "--name-only", "--no-renames", fmt.Sprintf("%s...%s", MainBranchName, branch))
 	output, err := cmd.Output()
 	if err != nil {
-		return "", stacktrace.Propagate(err, "failed to get added files")
+		return nil, stacktrace.Propagate(err, "failed to get changed files")
 	}
 
-	var addedPostDirs []string
+	var affectedPosts []AffectedPost
+	seenDirs := make(map[string]bool)
 	scanner := bufio.NewScanner(strings.NewReader(string(output)))
 	for scanner.Scan() {
 		file := scanner.Text()
-		if strings.HasSuffix(file, "/post.md") {
-			dir := filepath.Dir(file)
-			// Skip TEMPLATE directory
-			if dir != "TEMPLATE" {
-				addedPostDirs = append(addedPostDirs, dir)
+
+		postDir := ""
+		for _, dir := range allPostDirs {
+			if strings.HasPrefix(file, dir+"/") {
+				postDir = dir
+				break
 			}
 		}
-	}
 
-	if len(addedPostDirs) == 0 {
-		return "", stacktrace.NewError("no post.md files were added in this branch")
-	}
+		// Skip files outside of post directories, and the TEMPLATE directory
+		if postDir == "" || postDir == TemplateDirname || seenDirs[postDir] {
+			continue
+		}
+		seenDirs[postDir] = true
 
-	if len(addedPostDirs) > 1 {
-		return "", stacktrace.NewError("multiple post.md files were added in this branch: %v", addedPostDirs)
+		change := PostModified
+		if !isOnMain[postDir] {
+			change = PostAdded
+		} else if !isOnHead[postDir] {
+			change = PostDeleted
+		}
+		affectedPosts = append(affectedPosts, AffectedPost{
+			Dir:    postDir,
+			Change: change,
+		})
 	}
 
-	return addedPostDirs[0], nil
-}
-
-func openPostInChrome(postDir string) error {
-	postPath := fmt.Sprintf("%s/post.md", postDir)
-	cmd := exec.Command("open", "-a", "Google Chrome", postPath)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return stacktrace.NewError("failed to open post in Chrome: %s", string(output))
-	}
-	fmt.Printf("Opened %s in Chrome\n", postPath)
-	return nil
+	return affectedPosts, nil
 }
@@ -0,0 +1,569 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kurtosis-tech/stacktrace"
+)
+
+// errPublishStopped is returned by a step to stop the plan early without failing, e.g. when the user
+// interrupts the wait for checks
+var errPublishStopped = errors.New("publishing stopped")
+
+// publishContext is everything the publish steps need to know, which the steps also update as they run
+// (e.g. the PR once it's been created)
+type publishContext struct {
+	forge     Forge
+	publisher Publisher
+	branch    string
+
+	// Nil until the PR has been created
+	pullRequest *MergeRequest
+
+	// The posts touched by the branch, determined before merging since the branch gets deleted
+	affectedPosts []AffectedPost
+
+	// The checks the base branch's protection rules require; empty means all checks are required
+	requiredChecks []string
+
+	options PublishOptions
+
+	// Rendered from the affected posts' front matter; only set if the PR still needs creating
+	prTitle string
+	prBody  string
+
+	// When the branch's posts are scheduled to go out (the latest of their publish dates); zero means as
+	// soon as possible
+	publishAt time.Time
+}
+
+// PublishStep is a single action taken while publishing, described so that it can be shown in a dry run.
+// Every step must be safe to re-run, since a resumed publish may repeat the step that failed.
+type PublishStep struct {
+	// Identifies the step in the persisted progress, so a resumed publish knows which steps are done
+	Key         string
+	Description string
+	run         func(ctx *publishContext) error
+}
+
+// PublishPlan is the ordered list of steps needed to publish a branch
+type PublishPlan struct {
+	context *publishContext
+	Steps   []PublishStep
+
+	// Where progress is recorded so the publish can be resumed; nil means progress isn't tracked
+	state *PublishState
+}
+
+// newPublishContext gathers everything needed to plan the publish of the branch without changing anything
+func newPublishContext(forge Forge, branch string, options PublishOptions) (*publishContext, error) {
+	pullRequest, err := getPRForBranch(forge, branch)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to check for existing PR")
+	}
+
+	affectedPosts, err := getAffectedPosts(branch)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to find the posts affected by branch '%s'", branch)
+	}
+
+	requiredChecks, err := forge.GetRequiredChecks(MainBranchName)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to get the checks required on '%s'", MainBranchName)
+	}
+
+	ctx := &publishContext{
+		forge:          forge,
+		publisher:      newSubstackPublisher(),
+		branch:         branch,
+		pullRequest:    pullRequest,
+		affectedPosts:  affectedPosts,
+		requiredChecks: requiredChecks,
+		options:        options,
+	}
+	if err := ctx.renderPRTitleAndBodyIfNeeded(); err != nil {
+		return nil, err
+	}
+
+	publishAt, err := getBranchPublishAt(branch, affectedPosts)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to get the publish dates of the posts on branch '%s'", branch)
+	}
+	ctx.publishAt = publishAt
+	return ctx, nil
+}
+
+// newPublishContextFromState rebuilds the context of an earlier publish from its persisted state, since
+// the branch may have been merged and deleted since (so its affected posts can't be worked out again)
+func newPublishContextFromState(forge Forge, state *PublishState) (*publishContext, error) {
+	var pullRequest *MergeRequest
+	if state.PRNumber != 0 {
+		var err error
+		pullRequest, err = forge.GetMergeRequest(state.PRNumber)
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "failed to get PR #%d", state.PRNumber)
+		}
+	}
+
+	ctx := &publishContext{
+		forge:          forge,
+		publisher:      newSubstackPublisher(),
+		branch:         state.Branch,
+		pullRequest:    pullRequest,
+		affectedPosts:  state.AffectedPosts,
+		requiredChecks: state.RequiredChecks,
+		options:        state.Options,
+		publishAt:      state.PublishAt,
+	}
+	if err := ctx.renderPRTitleAndBodyIfNeeded(); err != nil {
+		return nil, err
+	}
+	return ctx, nil
+}
+
+func (ctx *publishContext) renderPRTitleAndBodyIfNeeded() error {
+	if ctx.pullRequest != nil {
+		return nil
+	}
+
+	title, body, err := renderPRTitleAndBody(ctx.branch, ctx.affectedPosts)
+	if err != nil {
+		return stacktrace.Propagate(err, "failed to render the PR's title and body")
+	}
+	ctx.prTitle = title
+	ctx.prBody = body
+	return nil
+}
+
+// newPublishState starts tracking the progress of a publish described by the context
+func newPublishState(ctx *publishContext) *PublishState {
+	now := time.Now()
+	state := &PublishState{
+		Branch:         ctx.branch,
+		Status:         PublishStatusRunning,
+		AffectedPosts:  ctx.affectedPosts,
+		RequiredChecks: ctx.requiredChecks,
+		Options:        ctx.options,
+		PublishAt:      ctx.publishAt,
+		StartedAt:      now,
+		UpdatedAt:      now,
+	}
+	if ctx.pullRequest != nil {
+		state.PRNumber = ctx.pullRequest.Number
+		state.PRURL = ctx.pullRequest.URL
+	}
+	return state
+}
+
+// buildPublishPlan plans the full publish: getting a PR, then either waiting for its checks and merging,
+// handing the rest off to a background watcher, or leaving it for the scheduler if the posts are scheduled
+func buildPublishPlan(ctx *publishContext, detach bool) *PublishPlan {
+	plan := &PublishPlan{context: ctx}
+	plan.Steps = append(plan.Steps, buildPRSteps(ctx)...)
+
+	// Drafts are left for reviewers; publishing again without --draft picks up from here
+	if ctx.options.Draft {
+		plan.Steps = append(plan.Steps, PublishStep{
+			Key:         "leave-draft",
+			Description: "Leave the PR as a draft for review (publish again without --draft to mark it ready and merge it)",
+			run: func(ctx *publishContext) error {
+				plan.state.Status = PublishStatusDraft
+				fmt.Println("PR left as a draft for review; run 'opwriting publish' again without --draft once it's ready")
+				return nil
+			},
+		})
+		return plan
+	}
+
+	// Scheduled posts are merged and published by the scheduler once their time comes
+	if ctx.publishAt.After(time.Now()) {
+		plan.Steps = append(plan.Steps, PublishStep{
+			Key:         "hold-for-schedule",
+			Description: fmt.Sprintf("Leave the PR for 'opwriting scheduler run' to merge and publish at %s", formatScheduledTime(ctx.publishAt)),
+			run: func(ctx *publishContext) error {
+				plan.state.Status = PublishStatusScheduled
+				fmt.Printf("PR left open until %s; 'opwriting scheduler run' will merge and publish it then\n", formatScheduledTime(ctx.publishAt))
+				return nil
+			},
+		})
+		return plan
+	}
+
+	if detach {
+		plan.Steps = append(plan.Steps, PublishStep{
+			Key:         "start-watcher",
+			Description: "Start a background watcher to wait for checks, merge, and publish",
+			run: func(ctx *publishContext) error {
+				return startPublishWatcher(plan.state)
+			},
+		})
+		return plan
+	}
+
+	plan.Steps = append(plan.Steps, buildWaitSteps(ctx)...)
+	plan.Steps = append(plan.Steps, buildMergeSteps(ctx, true)...)
+	return plan
+}
+
+func buildPRSteps(ctx *publishContext) []PublishStep {
+	if ctx.pullRequest != nil {
+		steps := []PublishStep{
+			{
+				Key:         "reuse-pr",
+				Description: fmt.Sprintf("Reuse existing PR #%d (%s)", ctx.pullRequest.Number, ctx.pullRequest.URL),
+				run: func(ctx *publishContext) error {
+					fmt.Printf("Found existing PR: %s\n", ctx.pullRequest.URL)
+					return nil
+				},
+			},
+		}
+
+		if ctx.pullRequest.Draft && !ctx.options.Draft {
+			steps = append(steps, PublishStep{
+				Key:         "mark-ready",
+				Description: "Mark the draft PR as ready for review",
+				run: func(ctx *publishContext) error {
+					if err := ctx.forge.MarkReadyForReview(ctx.pullRequest.Number); err != nil {
+						return stacktrace.Propagate(err, "failed to mark PR as ready")
+					}
+					fmt.Println("Marked PR as ready for review")
+					return nil
+				},
+			})
+		}
+		return steps
+	}
+
+	createDescription := fmt.Sprintf("Create a PR on %s from '%s' into '%s' titled '%s'", ctx.forge.Name(), ctx.branch, MainBranchName, ctx.prTitle)
+	if ctx.options.Draft {
+		createDescription += " as a draft"
+	}
+	if len(ctx.options.Labels) > 0 {
+		createDescription += fmt.Sprintf(", labelled %s", strings.Join(ctx.options.Labels, ", "))
+	}
+	if len(ctx.options.Reviewers) > 0 {
+		createDescription += fmt.Sprintf(", requesting reviews from %s", strings.Join(ctx.options.Reviewers, ", "))
+	}
+
+	var steps []PublishStep
+	if !ctx.options.Force {
+		steps = append(steps, PublishStep{
+			Key:         "local-checks",
+			Description: "Run the local checks against the branch's posts, stopping if any fail",
+			run: func(ctx *publishContext) error {
+				return runPublishLocalChecks(ctx)
+			},
+		})
+	}
+
+	return append(steps, []PublishStep{
+		{
+			Key:         "push-branch",
+			Description: fmt.Sprintf("Push branch '%s' to origin", ctx.branch),
+			run: func(ctx *publishContext) error {
+				return pushBranch(ctx.branch)
+			},
+		},
+		{
+			Key:         "create-pr",
+			Description: createDescription,
+			run: func(ctx *publishContext) error {
+				fmt.Printf("Creating PR for branch '%s'...\n", ctx.branch)
+				options := MergeRequestOptions{
+					Title:     ctx.prTitle,
+					Body:      ctx.prBody,
+					Draft:     ctx.options.Draft,
+					Labels:    ctx.options.Labels,
+					Reviewers: ctx.options.Reviewers,
+				}
+				pullRequest, err := createPR(ctx.forge, ctx.branch, options)
+				if err != nil {
+					return stacktrace.Propagate(err, "failed to create PR")
+				}
+				ctx.pullRequest = pullRequest
+				fmt.Printf("PR created: %s\n", pullRequest.URL)
+				return nil
+			},
+		},
+	}...)
+}
+
+// runPublishLocalChecks runs the local checks against the posts the branch adds or changes, which are read
+// from the working tree, so they're only run if the branch is checked out
+func runPublishLocalChecks(ctx *publishContext) error {
+	currentBranch, err := getCurrentBranch()
+	if err != nil {
+		return stacktrace.Propagate(err, "failed to get current branch")
+	}
+	if currentBranch != ctx.branch {
+		fmt.Printf("Skipping the local checks, since '%s' isn't checked out\n", ctx.branch)
+		return nil
+	}
+
+	var postDirs []string
+	for _, post := range ctx.affectedPosts {
+		if post.Change != PostDeleted {
+			postDirs = append(postDirs, post.Dir)
+		}
+	}
+	if len(postDirs) == 0 {
+		return nil
+	}
+
+	fmt.Println("Running local checks...")
+	passed, err := runLocalChecks(os.Getenv(WritingDirEnvVar), postDirs)
+	if err != nil {
+		return stacktrace.Propagate(err, "failed to run local checks")
+	}
+	if !passed {
+		return stacktrace.NewError("local checks failed; fix the problems above, or publish with --force to create the PR anyway")
+	}
+	return nil
+}
+
+// buildWaitSteps plans waiting for the PR's approvals (if any are required) and checks to pass, or with
+// auto-merge, handing the merge to the forge and waiting for it to happen
+func buildWaitSteps(ctx *publishContext) []PublishStep {
+	description := "Wait for all checks to pass"
+	if len(ctx.requiredChecks) > 0 {
+		description = fmt.Sprintf("Wait for the required checks to pass: %s", strings.Join(ctx.requiredChecks, ", "))
+	}
+	if ctx.options.AutoMerge {
+		description += fmt.Sprintf(" and %s to merge the PR", ctx.forge.Name())
+	}
+	if publishTimeout > 0 {
+		description += fmt.Sprintf(" (giving up after %v)", publishTimeout)
+	}
+
+	var steps []PublishStep
+	// Approvals come first, so that auto-merge can't merge the PR before the reviewers have signed off
+	if ctx.options.RequiredApprovals > 0 {
+		steps = append(steps, PublishStep{
+			Key:         "wait-for-approvals",
+			Description: fmt.Sprintf("Wait for %d approving review(s), with no outstanding requests for changes", ctx.options.RequiredApprovals),
+			run: func(ctx *publishContext) error {
+				approved, err := waitForApprovals(ctx.forge, ctx.pullRequest.Number, ctx.options.RequiredApprovals)
+				if err != nil {
+					return err
+				}
+				if !approved {
+					return errPublishStopped
+				}
+				return nil
+			},
+		})
+	}
+
+	if ctx.options.AutoMerge {
+		steps = append(steps, PublishStep{
+			Key:         "enable-auto-merge",
+			Description: fmt.Sprintf("Enable auto-merge on %s using the '%s' method", ctx.forge.Name(), ctx.options.MergeMethod),
+			run: func(ctx *publishContext) error {
+				if err := ctx.forge.EnableAutoMerge(ctx.pullRequest.Number, ctx.options.MergeMethod); err != nil {
+					return stacktrace.Propagate(err, "failed to enable auto-merge")
+				}
+				fmt.Println("Auto-merge enabled")
+				return nil
+			},
+		})
+	}
+
+	steps = append(steps, PublishStep{
+		Key:         "wait-for-checks",
+		Description: description,
+		run: func(ctx *publishContext) error {
+			fmt.Println("Monitoring PR status...")
+			passed, err := waitForChecks(ctx.forge, ctx.pullRequest.Number, ctx.options.AutoMerge)
+			if err != nil {
+				return err
+			}
+			if !passed {
+				return errPublishStopped
+			}
+			return nil
+		},
+	})
+	return steps
+}
+
+// buildMergeSteps plans everything that happens once the checks have passed. Local cleanup (switching to
+// main, pulling, deleting the local branch) and publishing, which reads the posts from the working tree, can
+// be left out for when the user's working tree isn't on the branch anymore.
+func buildMergeSteps(ctx *publishContext, includeLocalCleanup bool) []PublishStep {
+	var steps []PublishStep
+	// With auto-merge, the forge has already merged the PR by the time the checks have passed
+	if !ctx.options.AutoMerge {
+		steps = append(steps, PublishStep{
+			Key:         "merge-pr",
+			Description: fmt.Sprintf("Merge the PR into '%s' using the '%s' method", MainBranchName, ctx.options.MergeMethod),
+			run: func(ctx *publishContext) error {
+				fmt.Println("Merging PR and cleaning up...")
+				return mergePR(ctx.forge, ctx.pullRequest.Number, ctx.options.MergeMethod)
+			},
+		})
+	}
+
+	steps = append(steps, PublishStep{
+		Key:         "delete-remote-branch",
+		Description: fmt.Sprintf("Delete remote branch '%s'", ctx.branch),
+		run: func(ctx *publishContext) error {
+			return deleteRemoteBranch(ctx.forge, ctx.branch)
+		},
+	})
+
+	if !includeLocalCleanup {
+		return steps
+	}
+
+	steps = append(steps, []PublishStep{
+		{
+			Key:         "switch-to-main",
+			Description: fmt.Sprintf("Switch to '%s'", MainBranchName),
+			run: func(ctx *publishContext) error {
+				return switchToMain()
+			},
+		},
+		{
+			Key:         "pull-main",
+			Description: fmt.Sprintf("Pull the latest changes to '%s'", MainBranchName),
+			run: func(ctx *publishContext) error {
+				return pullMain()
+			},
+		},
+		{
+			Key:         "delete-local-branch",
+			Description: fmt.Sprintf("Delete local branch '%s'", ctx.branch),
+			run: func(ctx *publishContext) error {
+				return deleteLocalBranch(ctx.branch)
+			},
+		},
+	}...)
+
+	if len(ctx.affectedPosts) == 0 {
+		steps = append(steps, PublishStep{
+			Key:         "publish-posts",
+			Description: "Skip publishing, since no posts were changed on this branch",
+			run: func(ctx *publishContext) error {
+				fmt.Println("No posts were changed on this branch; nothing to publish")
+				return nil
+			},
+		})
+		return steps
+	}
+
+	// Hand each post off to the publishing platform
+	for _, post := range ctx.affectedPosts {
+		post := post
+		steps = append(steps, PublishStep{
+			Key:         "publish-post:" + post.Dir,
+			Description: describeAffectedPostPublish(ctx.publisher, post),
+			run: func(ctx *publishContext) error {
+				return publishAffectedPost(ctx.publisher, post)
+			},
+		})
+	}
+	return steps
+}
+
+func describeAffectedPostPublish(publisher Publisher, post AffectedPost) string {
+	switch post.Change {
+	case PostAdded:
+		return fmt.Sprintf("Publish new post '%s' on %s", post.Dir, publisher.Name())
+	case PostModified:
+		return fmt.Sprintf("Update published post '%s' on %s", post.Dir, publisher.Name())
+	case PostDeleted:
+		return fmt.Sprintf("Remove deleted post '%s' from %s", post.Dir, publisher.Name())
+	default:
+		return fmt.Sprintf("Handle post '%s'", post.Dir)
+	}
+}
+
+// Print shows the steps the plan would take, without taking them
+func (plan *PublishPlan) Print() {
+	for idx, step := range plan.Steps {
+		suffix := ""
+		if plan.isStepCompleted(step) {
+			suffix = " (already done)"
+		}
+		fmt.Printf("  %d. %s%s\n", idx+1, step.Description, suffix)
+	}
+}
+
+// Execute runs the plan's steps in order, stopping at the first failure. If the plan is tracking progress,
+// steps completed by an earlier run are skipped and each step is recorded as it completes, so that a failed
+// or interrupted publish can be resumed.
+func (plan *PublishPlan) Execute() error {
+	if err := plan.saveProgress(PublishStatusRunning, "Starting"); err != nil {
+		return err
+	}
+
+	for _, step := range plan.Steps {
+		if plan.isStepCompleted(step) {
+			fmt.Printf("Skipping already-completed step: %s\n", step.Description)
+			continue
+		}
+
+		if err := step.run(plan.context); err != nil {
+			if err == errPublishStopped {
+				message := fmt.Sprintf("Stopped at step: %s; run 'opwriting publish --resume' to continue", step.Description)
+				return plan.saveProgress(PublishStatusInterrupted, message)
+			}
+
+			message := fmt.Sprintf("Failed at step: %s; run 'opwriting publish --resume' to continue", step.Description)
+			if saveErr := plan.saveProgress(PublishStatusFailed, message); saveErr != nil {
+				fmt.Printf("⚠️  Couldn't record publish progress: %v\n", saveErr)
+			}
+			return stacktrace.Propagate(err, "publish step failed: %s", step.Description)
+		}
+
+		if plan.state != nil {
+			plan.state.CompletedSteps = append(plan.state.CompletedSteps, step.Key)
+		}
+		// A step may have handed the publish off (to a background watcher, or to reviewers), which takes
+		// over the publish's status
+		status := PublishStatusRunning
+		if plan.state != nil {
+			status = plan.state.Status
+		}
+		if err := plan.saveProgress(status, fmt.Sprintf("Completed step: %s", step.Description)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (plan *PublishPlan) isStepCompleted(step PublishStep) bool {
+	if plan.state == nil {
+		return false
+	}
+	for _, completedKey := range plan.state.CompletedSteps {
+		if completedKey == step.Key {
+			return true
+		}
+	}
+	return false
+}
+
+// saveProgress records the publish's status along with anything the steps have learned (e.g. the PR that was
+// created), if the plan is tracking progress
+func (plan *PublishPlan) saveProgress(status string, message string) error {
+	if plan.state == nil {
+		return nil
+	}
+
+	if plan.context.pullRequest != nil {
+		plan.state.PRNumber = plan.context.pullRequest.Number
+		plan.state.PRURL = plan.context.pullRequest.URL
+	}
+	plan.state.Status = status
+	plan.state.Message = message
+	plan.state.UpdatedAt = time.Now()
+	if err := savePublishState(plan.state); err != nil {
+		return stacktrace.Propagate(err, "failed to save publish progress")
+	}
+	return nil
+}
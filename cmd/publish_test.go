@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// commitTestFile writes the file and commits it on the repo's current branch
+func commitTestFile(t *testing.T, repoPath string, relFilepath string, contents string) {
+	if err := os.WriteFile(filepath.Join(repoPath, relFilepath), []byte(contents), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", relFilepath, err)
+	}
+	runTestGit(t, repoPath, "add", relFilepath)
+	runTestGit(t, repoPath, "-c", "user.name=Test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "Update "+relFilepath)
+}
+
+func TestDeleteLocalBranchAfterSquashMerge(t *testing.T) {
+	repoPath := newTestRepo(t)
+	commitTestFile(t, repoPath, "README.md", "My writing\n")
+	runTestGit(t, repoPath, "checkout", "--quiet", "-b", "my-post")
+	commitTestFile(t, repoPath, "post.md", "# My post\n")
+	commitTestFile(t, repoPath, "post.md", "# My post\n\nMore words.\n")
+
+	// Squashing leaves the branch's own commits out of main, like a squash merge on the forge
+	runTestGit(t, repoPath, "checkout", "--quiet", MainBranchName)
+	runTestGit(t, repoPath, "merge", "--quiet", "--squash", "my-post")
+	runTestGit(t, repoPath, "-c", "user.name=Test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "My post (#7)")
+
+	captureStdout(t, func() {
+		if err := deleteLocalBranch("my-post"); err != nil {
+			t.Fatalf("expected the squash-merged branch to be deleted, got: %v", err)
+		}
+	})
+	if err := exec.Command("git", "-C", repoPath, "rev-parse", "--verify", "--quiet", "refs/heads/my-post").Run(); err == nil {
+		t.Error("expected the local branch to be gone")
+	}
+}
+
+func TestIsBranchMerged(t *testing.T) {
+	testCases := []struct {
+		name           string
+		pullRequests   []map[string]interface{}
+		expectedMerged bool
+	}{
+		{name: "no PR"},
+		{name: "open PR", pullRequests: []map[string]interface{}{{"number": 7, "state": "open"}}},
+		{name: "closed PR", pullRequests: []map[string]interface{}{{"number": 7, "state": "closed", "merged_at": nil}}},
+		// A squash or rebase merge leaves nothing in main's history for git to find
+		{name: "merged PR", pullRequests: []map[string]interface{}{{"number": 7, "state": "closed", "merged_at": "2024-01-01T00:00:00Z"}}, expectedMerged: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := newFakeGitHub(t, map[string]http.HandlerFunc{
+				"GET " + testGitHubRepoPath("pulls"): func(writer http.ResponseWriter, request *http.Request) {
+					pullRequests := testCase.pullRequests
+					if pullRequests == nil {
+						pullRequests = []map[string]interface{}{}
+					}
+					respondJSON(writer, http.StatusOK, pullRequests)
+				},
+			})
+			merged, err := isBranchMerged(client, "my-post")
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if merged != testCase.expectedMerged {
+				t.Errorf("expected merged to be %v, got %v", testCase.expectedMerged, merged)
+			}
+		})
+	}
+}
@@ -0,0 +1,442 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kurtosis-tech/stacktrace"
+	"github.com/spf13/cobra"
+)
+
+const (
+	watchBranchFlag = "watch-branch"
+
+	// Relative to the repo's .git directory
+	publishStateDirpath = "opwriting/publish"
+
+	PublishStatusRunning     = "running"
+	PublishStatusWatching    = "watching"
+	PublishStatusPublished   = "published"
+	PublishStatusFailed      = "failed"
+	PublishStatusInterrupted = "interrupted"
+	PublishStatusDraft       = "draft"
+	PublishStatusScheduled   = "scheduled"
+)
+
+// PublishState tracks the progress of a publish, whether it's running in the foreground or being monitored
+// in the background, and is stored in .git/opwriting/publish/<branch>.json
+type PublishState struct {
+	Branch    string    `json:"branch"`
+	PRNumber  int       `json:"pr_number"`
+	PRURL     string    `json:"pr_url"`
+	Status    string    `json:"status"`
+	Message   string    `json:"message"`
+	PID       int       `json:"pid"`
+	StartedAt time.Time `json:"started_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Worked out before merging, since the branch may be gone by the time the publish is resumed
+	AffectedPosts  []AffectedPost `json:"affected_posts"`
+	RequiredChecks []string       `json:"required_checks"`
+	Options        PublishOptions `json:"options"`
+	PublishAt      time.Time      `json:"publish_at"`
+
+	// Keys of the plan steps that have finished, in the order they finished
+	CompletedSteps []string `json:"completed_steps"`
+}
+
+// getWatcherExecutablePath finds the binary that startPublishWatcher runs, and is swapped out in tests
+var getWatcherExecutablePath = os.Executable
+
+var publishStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show all in-flight and recent publishes",
+	Long:  "List every publish (including those started with --detach), along with its PR and whether it's still running, published, or failed",
+	Args:  cobra.NoArgs,
+	RunE:  showPublishStatus,
+}
+
+// startPublishWatcher starts a detached copy of this binary to monitor the publish, handing its state over
+func startPublishWatcher(state *PublishState) error {
+	branch := state.Branch
+
+	executablePath, err := getWatcherExecutablePath()
+	if err != nil {
+		return stacktrace.Propagate(err, "failed to find the opwriting executable")
+	}
+
+	stateFilepath, err := getPublishStateFilepath(branch)
+	if err != nil {
+		return stacktrace.Propagate(err, "failed to get publish state filepath")
+	}
+	if err := os.MkdirAll(filepath.Dir(stateFilepath), 0755); err != nil {
+		return stacktrace.Propagate(err, "failed to create publish state directory")
+	}
+
+	// The watcher's output goes to a log file next to its state file
+	logFilepath := strings.TrimSuffix(stateFilepath, ".json") + ".log"
+	logFile, err := os.Create(logFilepath)
+	if err != nil {
+		return stacktrace.Propagate(err, "failed to create watcher log file: %s", logFilepath)
+	}
+	defer logFile.Close()
+
+	watcherCmd := exec.Command(executablePath, "publish", "--"+watchBranchFlag, branch, "--timeout", publishTimeout.String())
+	watcherCmd.Stdout = logFile
+	watcherCmd.Stderr = logFile
+	detachProcess(watcherCmd)
+
+	state.Status = PublishStatusWatching
+	state.Message = "Waiting for checks to pass"
+	state.UpdatedAt = time.Now()
+	if err := savePublishState(state); err != nil {
+		return stacktrace.Propagate(err, "failed to save publish state")
+	}
+
+	if err := watcherCmd.Start(); err != nil {
+		return stacktrace.Propagate(err, "failed to start background watcher")
+	}
+
+	state.PID = watcherCmd.Process.Pid
+	if err := savePublishState(state); err != nil {
+		return stacktrace.Propagate(err, "failed to save publish state")
+	}
+	if err := watcherCmd.Process.Release(); err != nil {
+		return stacktrace.Propagate(err, "failed to detach from background watcher")
+	}
+
+	fmt.Printf("Monitoring PR in the background (logs: %s)\n", logFilepath)
+	fmt.Println("Run 'opwriting publish status' to check on it; you'll get a notification when it's done.")
+	return nil
+}
+
+// runPublishWatcher is the body of the background process started by startPublishWatcher
+func runPublishWatcher(branch string) error {
+	notifier := newNotifier()
+
+	state, err := loadPublishState(branch)
+	if err != nil {
+		return stacktrace.Propagate(err, "failed to load publish state for branch '%s'", branch)
+	}
+	// Recorded here as well as by the parent, since the parent may save its copy after we've loaded ours
+	state.PID = os.Getpid()
+
+	// Record how the watch ended, no matter how it ends
+	finish := func(status string, message string) error {
+		state.Status = status
+		state.Message = message
+		state.UpdatedAt = time.Now()
+		return savePublishState(state)
+	}
+
+	forge, err := newForgeForCurrentRepo()
+	if err != nil {
+		finish(PublishStatusFailed, "Couldn't connect to the forge; run 'opwriting publish --resume' to try again")
+		notifier.Notify("❌ Publishing failed", fmt.Sprintf("Couldn't connect to the forge for '%s'", branch))
+		return stacktrace.Propagate(err, "failed to create forge client")
+	}
+
+	publishCtx, err := newPublishContextFromState(forge, state)
+	if err != nil {
+		finish(PublishStatusFailed, "Couldn't find the PR; run 'opwriting publish --resume' to try again")
+		notifier.Notify("❌ Publishing failed", fmt.Sprintf("Couldn't find the PR for '%s'", branch))
+		return stacktrace.Propagate(err, "failed to load publish")
+	}
+
+	waitPlan := &PublishPlan{
+		context: publishCtx,
+		Steps:   buildWaitSteps(publishCtx),
+		state:   state,
+	}
+	if err := waitPlan.Execute(); err != nil {
+		notifier.Notify("❌ Publishing failed", fmt.Sprintf("Checks for '%s' failed: %s", branch, state.PRURL))
+		return stacktrace.Propagate(err, "checks didn't pass")
+	}
+	if state.Status == PublishStatusInterrupted {
+		return nil
+	}
+
+	// The user has probably carried on working, so only switch branches underneath them if they're
+	// still sitting on the branch being published with nothing uncommitted
+	safeToCleanUp, err := isSafeForLocalCleanup(branch)
+	if err != nil {
+		return stacktrace.Propagate(err, "failed to check whether local cleanup is safe")
+	}
+
+	mergePlan := &PublishPlan{
+		context: publishCtx,
+		Steps:   buildMergeSteps(publishCtx, safeToCleanUp),
+		state:   state,
+	}
+	if err := mergePlan.Execute(); err != nil {
+		notifier.Notify("❌ Publishing failed", fmt.Sprintf("Couldn't finish publishing '%s'", branch))
+		return stacktrace.Propagate(err, "failed to merge and publish")
+	}
+
+	if !safeToCleanUp {
+		message := fmt.Sprintf("Merged, but you've moved on from '%s' so local cleanup was skipped; run 'opwriting publish --resume %s' to finish up", branch, branch)
+		// Left resumable, so that the skipped cleanup and publishing steps can still be run
+		finish(PublishStatusInterrupted, message)
+		notifier.Notify("✅ Post merged", fmt.Sprintf("'%s' was merged", branch))
+		return nil
+	}
+
+	if err := finish(PublishStatusPublished, "Merged and published"); err != nil {
+		return stacktrace.Propagate(err, "failed to save publish state")
+	}
+	notifier.Notify("✅ Post published", fmt.Sprintf("'%s' was merged and published", branch))
+	return nil
+}
+
+// isSafeForLocalCleanup returns true if the given branch is checked out with no uncommitted changes
+func isSafeForLocalCleanup(branch string) (bool, error) {
+	currentBranch, err := getCurrentBranch()
+	if err != nil {
+		return false, stacktrace.Propagate(err, "failed to get current branch")
+	}
+	if currentBranch != branch {
+		return false, nil
+	}
+
+	cmd := exec.Command("git", "status", "--porcelain")
+	output, err := cmd.Output()
+	if err != nil {
+		return false, stacktrace.Propagate(err, "failed to get working tree status")
+	}
+	return strings.TrimSpace(string(output)) == "", nil
+}
+
+func showPublishStatus(cmd *cobra.Command, args []string) error {
+	states, err := loadAllPublishStates()
+	if err != nil {
+		return stacktrace.Propagate(err, "failed to load publish states")
+	}
+
+	if len(states) == 0 {
+		fmt.Println("No background publishes found")
+		return nil
+	}
+
+	for _, state := range states {
+		status := state.Status
+		// A publish that's gone without recording an outcome must have crashed or been killed
+		if isPublishStateStale(state) {
+			status = "stopped unexpectedly; run 'opwriting publish --resume' to continue"
+		}
+
+		emoji := "⏳"
+		switch state.Status {
+		case PublishStatusPublished:
+			emoji = "✅"
+		case PublishStatusFailed:
+			emoji = "❌"
+		case PublishStatusInterrupted:
+			emoji = "⏸️ "
+		case PublishStatusDraft:
+			emoji = "📝"
+		case PublishStatusScheduled:
+			emoji = "🗓️ "
+		}
+
+		fmt.Printf("%s %s (%s, updated %s)\n", emoji, state.Branch, status, state.UpdatedAt.Format(time.RFC822))
+		fmt.Printf("    PR: %s\n", state.PRURL)
+		if state.Message != "" {
+			fmt.Printf("    %s\n", state.Message)
+		}
+	}
+	return nil
+}
+
+// isPublishStateStale returns true if the state claims the publish is still in progress, but the process
+// doing it has gone
+func isPublishStateStale(state *PublishState) bool {
+	inProgress := state.Status == PublishStatusRunning || state.Status == PublishStatusWatching
+	return inProgress && !isProcessRunning(state.PID)
+}
+
+// isPublishInProgress returns true if a process is still working on the publish
+func isPublishInProgress(state *PublishState) bool {
+	inProgress := state.Status == PublishStatusRunning || state.Status == PublishStatusWatching
+	return inProgress && isProcessRunning(state.PID)
+}
+
+// isPublishResumable returns true if the publish didn't finish and nothing is working on it anymore
+func isPublishResumable(state *PublishState) bool {
+	switch state.Status {
+	case PublishStatusFailed, PublishStatusInterrupted:
+		return true
+	case PublishStatusRunning, PublishStatusWatching:
+		return isPublishStateStale(state)
+	default:
+		return false
+	}
+}
+
+// findPublishToResume picks the unfinished publish to resume: the given branch's if a branch is given, else
+// the current branch's, else the only unfinished one (e.g. after a failure that left the user on main)
+func findPublishToResume(branch string, currentBranch string) (*PublishState, error) {
+	if branch != "" {
+		state, err := loadPublishState(branch)
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "no publish found for branch '%s'", branch)
+		}
+		return state, nil
+	}
+
+	states, err := loadAllPublishStates()
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to load publish states")
+	}
+
+	var resumable []*PublishState
+	for _, state := range states {
+		if state.Branch == currentBranch {
+			return state, nil
+		}
+		if isPublishResumable(state) {
+			resumable = append(resumable, state)
+		}
+	}
+
+	switch len(resumable) {
+	case 0:
+		return nil, stacktrace.NewError("there are no unfinished publishes to resume")
+	case 1:
+		return resumable[0], nil
+	default:
+		var branches []string
+		for _, state := range resumable {
+			branches = append(branches, state.Branch)
+		}
+		return nil, stacktrace.NewError("there are several unfinished publishes; pass the branch to resume, one of: %s", strings.Join(branches, ", "))
+	}
+}
+
+// getPublishStateFilepath returns the path of the state file for the branch, inside the repo's .git directory
+func getPublishStateFilepath(branch string) (string, error) {
+	stateDirpath, err := getPublishStateDirpath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(stateDirpath, branch+".json"), nil
+}
+
+func getPublishStateDirpath() (string, error) {
+	gitDirpath, err := getGitCommonDirpath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(gitDirpath, publishStateDirpath), nil
+}
+
+// getGitCommonDirpath returns the absolute path of the current repo's .git directory, where opwriting keeps its
+// working files
+func getGitCommonDirpath() (string, error) {
+	return getRepoGitCommonDirpath(".")
+}
+
+// getRepoRootDirpath returns the absolute path of the top of the current repo's working tree, which post
+// directories are relative to
+func getRepoRootDirpath() (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", stacktrace.Propagate(err, "failed to find the top of the repo")
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// getRepoGitCommonDirpath returns the absolute path of the .git directory of the repo at the given path
+func getRepoGitCommonDirpath(repoPath string) (string, error) {
+	// The common dir is shared between worktrees, unlike '--git-dir'
+	cmd := exec.Command("git", "-C", repoPath, "rev-parse", "--path-format=absolute", "--git-common-dir")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", stacktrace.Propagate(err, "failed to find the .git directory")
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func savePublishState(state *PublishState) error {
+	stateFilepath, err := getPublishStateFilepath(state.Branch)
+	if err != nil {
+		return stacktrace.Propagate(err, "failed to get publish state filepath")
+	}
+	// Branches with a '/' in their name, like revision branches, get nested state files
+	if err := os.MkdirAll(filepath.Dir(stateFilepath), 0755); err != nil {
+		return stacktrace.Propagate(err, "failed to create publish state directory")
+	}
+
+	stateBytes, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return stacktrace.Propagate(err, "failed to serialize publish state")
+	}
+	if err := os.WriteFile(stateFilepath, stateBytes, 0644); err != nil {
+		return stacktrace.Propagate(err, "failed to write publish state file: %s", stateFilepath)
+	}
+	return nil
+}
+
+func loadPublishState(branch string) (*PublishState, error) {
+	stateFilepath, err := getPublishStateFilepath(branch)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to get publish state filepath")
+	}
+	return readPublishStateFile(stateFilepath)
+}
+
+func readPublishStateFile(stateFilepath string) (*PublishState, error) {
+	stateBytes, err := os.ReadFile(stateFilepath)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to read publish state file: %s", stateFilepath)
+	}
+
+	var state PublishState
+	if err := json.Unmarshal(stateBytes, &state); err != nil {
+		return nil, stacktrace.Propagate(err, "failed to parse publish state file: %s", stateFilepath)
+	}
+	return &state, nil
+}
+
+// loadAllPublishStates returns the state of every background publish, most recently updated first
+func loadAllPublishStates() ([]*PublishState, error) {
+	stateDirpath, err := getPublishStateDirpath()
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to get publish state directory")
+	}
+
+	var states []*PublishState
+	// Branch names can contain slashes, so state files may be nested
+	err = filepath.WalkDir(stateDirpath, func(path string, entry os.DirEntry, err error) error {
+		if os.IsNotExist(err) {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+
+		state, err := readPublishStateFile(path)
+		if err != nil {
+			return stacktrace.Propagate(err, "failed to load publish state")
+		}
+		states = append(states, state)
+		return nil
+	})
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to walk publish state directory: %s", stateDirpath)
+	}
+
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].UpdatedAt.After(states[j].UpdatedAt)
+	})
+	return states, nil
+}
@@ -0,0 +1,315 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeNotifier records notifications instead of sending them
+type fakeNotifier struct {
+	notifications []string
+}
+
+func (notifier *fakeNotifier) Notify(title string, message string) error {
+	notifier.notifications = append(notifier.notifications, title+": "+message)
+	return nil
+}
+
+func installFakeNotifier(t *testing.T) *fakeNotifier {
+	notifier := &fakeNotifier{}
+	originalNewNotifier := newNotifier
+	newNotifier = func() Notifier { return notifier }
+	t.Cleanup(func() { newNotifier = originalNewNotifier })
+	return notifier
+}
+
+// newTestRepo creates an empty writing repo in a temp dir and moves into it for the rest of the test, so that
+// publish state is kept in the temp repo's .git directory
+func newTestRepo(t *testing.T) string {
+	repoPath := t.TempDir()
+	runTestGit(t, repoPath, "init", "--quiet", "--initial-branch", MainBranchName)
+
+	originalDirpath, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get the current directory: %v", err)
+	}
+	if err := os.Chdir(repoPath); err != nil {
+		t.Fatalf("failed to move into the test repo: %v", err)
+	}
+	t.Cleanup(func() { os.Chdir(originalDirpath) })
+	t.Setenv(WritingDirEnvVar, repoPath)
+	return repoPath
+}
+
+func runTestGit(t *testing.T, repoPath string, args ...string) {
+	cmd := exec.Command("git", append([]string{"-C", repoPath}, args...)...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("'git %s' failed: %s", strings.Join(args, " "), string(output))
+	}
+}
+
+// captureStdout returns everything the function prints
+func captureStdout(t *testing.T, run func()) string {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+	originalStdout := os.Stdout
+	os.Stdout = writer
+	defer func() { os.Stdout = originalStdout }()
+
+	outputChan := make(chan string)
+	go func() {
+		output, _ := io.ReadAll(reader)
+		outputChan <- string(output)
+	}()
+
+	run()
+	writer.Close()
+	return <-outputChan
+}
+
+func TestPublishStateLifecycle(t *testing.T) {
+	repoPath := newTestRepo(t)
+
+	states, err := loadAllPublishStates()
+	if err != nil || len(states) != 0 {
+		t.Fatalf("expected no publish states before anything's been published, got: %v, %v", states, err)
+	}
+
+	startedAt := time.Now().Add(-time.Hour)
+	postState := &PublishState{Branch: "my-post", PRNumber: 3, Status: PublishStatusRunning, StartedAt: startedAt, UpdatedAt: startedAt}
+	// Revision branches have a '/' in their name, so their state file is nested
+	revisionState := &PublishState{Branch: "revise/my-post-1", PRNumber: 4, Status: PublishStatusRunning, StartedAt: startedAt, UpdatedAt: startedAt.Add(time.Minute)}
+	for _, state := range []*PublishState{postState, revisionState} {
+		if err := savePublishState(state); err != nil {
+			t.Fatalf("expected the state for '%s' to be saved, got: %v", state.Branch, err)
+		}
+	}
+	revisionStateFilepath := filepath.Join(repoPath, ".git", publishStateDirpath, "revise", "my-post-1.json")
+	if _, err := os.Stat(revisionStateFilepath); err != nil {
+		t.Errorf("expected a state file at %s, got: %v", revisionStateFilepath, err)
+	}
+
+	postState.Status = PublishStatusPublished
+	postState.Message = "Merged and published"
+	postState.UpdatedAt = time.Now()
+	if err := savePublishState(postState); err != nil {
+		t.Fatalf("expected the updated state to be saved, got: %v", err)
+	}
+
+	loadedState, err := loadPublishState("my-post")
+	if err != nil {
+		t.Fatalf("expected the state to load, got: %v", err)
+	}
+	if loadedState.Status != PublishStatusPublished || loadedState.PRNumber != 3 {
+		t.Errorf("expected the updated state, got: %+v", loadedState)
+	}
+
+	states, err = loadAllPublishStates()
+	if err != nil {
+		t.Fatalf("expected every state to load, got: %v", err)
+	}
+	if len(states) != 2 || states[0].Branch != "my-post" || states[1].Branch != "revise/my-post-1" {
+		t.Errorf("expected both states, most recently updated first, got: %+v", states)
+	}
+
+	if _, err := loadPublishState("never-published"); err == nil {
+		t.Error("expected an error loading the state of a branch that was never published")
+	}
+}
+
+func TestShowPublishStatus(t *testing.T) {
+	newTestRepo(t)
+
+	output := captureStdout(t, func() {
+		if err := showPublishStatus(publishStatusCmd, nil); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	})
+	if !strings.Contains(output, "No background publishes found") {
+		t.Errorf("expected to be told there are no publishes, got: %s", output)
+	}
+
+	now := time.Now()
+	states := []*PublishState{
+		{Branch: "published-post", PRURL: "https://example.com/pull/1", Status: PublishStatusPublished, Message: "Merged and published", UpdatedAt: now},
+		{Branch: "failed-post", PRURL: "https://example.com/pull/2", Status: PublishStatusFailed, Message: "Failed at step: Merge the PR", UpdatedAt: now.Add(-time.Minute)},
+		// No process has a PID of 0, so this watcher must have died
+		{Branch: "crashed-post", PRURL: "https://example.com/pull/3", Status: PublishStatusWatching, PID: 0, UpdatedAt: now.Add(-2 * time.Minute)},
+	}
+	for _, state := range states {
+		if err := savePublishState(state); err != nil {
+			t.Fatalf("failed to save state for '%s': %v", state.Branch, err)
+		}
+	}
+
+	output = captureStdout(t, func() {
+		if err := showPublishStatus(publishStatusCmd, nil); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	})
+	for _, expected := range []string{
+		"✅ published-post (published,",
+		"❌ failed-post (failed,",
+		"Failed at step: Merge the PR",
+		"crashed-post (stopped unexpectedly; run 'opwriting publish --resume' to continue,",
+		"PR: https://example.com/pull/3",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected the status output to contain '%s', got:\n%s", expected, output)
+		}
+	}
+	if strings.Index(output, "published-post") > strings.Index(output, "crashed-post") {
+		t.Errorf("expected the most recently updated publish first, got:\n%s", output)
+	}
+}
+
+func TestStartPublishWatcher(t *testing.T) {
+	repoPath := newTestRepo(t)
+
+	// Stands in for the opwriting binary, recording the arguments the watcher was started with
+	argsFilepath := filepath.Join(t.TempDir(), "args")
+	fakeExecutablePath := filepath.Join(t.TempDir(), "opwriting")
+	script := fmt.Sprintf("#!/bin/sh\necho watching\necho \"$@\" > %s.tmp && mv %s.tmp %s\n", argsFilepath, argsFilepath, argsFilepath)
+	if err := os.WriteFile(fakeExecutablePath, []byte(script), 0755); err != nil {
+		t.Fatalf("failed to write fake executable: %v", err)
+	}
+	originalGetWatcherExecutablePath := getWatcherExecutablePath
+	getWatcherExecutablePath = func() (string, error) { return fakeExecutablePath, nil }
+	t.Cleanup(func() { getWatcherExecutablePath = originalGetWatcherExecutablePath })
+
+	state := &PublishState{Branch: "revise/my-post-1", PRNumber: 4, Status: PublishStatusRunning, StartedAt: time.Now()}
+	captureStdout(t, func() {
+		if err := startPublishWatcher(state); err != nil {
+			t.Fatalf("expected the watcher to start, got: %v", err)
+		}
+	})
+
+	savedState, err := loadPublishState("revise/my-post-1")
+	if err != nil {
+		t.Fatalf("expected the watcher's state to be saved, got: %v", err)
+	}
+	if savedState.Status != PublishStatusWatching || savedState.PID == 0 {
+		t.Errorf("expected the state to be handed to a watcher process, got: %+v", savedState)
+	}
+
+	var watcherArgs []byte
+	for attempt := 0; attempt < 100; attempt++ {
+		if watcherArgs, err = os.ReadFile(argsFilepath); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("expected the watcher to run, got: %v", err)
+	}
+	if !strings.Contains(string(watcherArgs), "publish --"+watchBranchFlag+" revise/my-post-1") {
+		t.Errorf("expected the watcher to be told which branch to watch, got: %s", watcherArgs)
+	}
+
+	logFilepath := filepath.Join(repoPath, ".git", publishStateDirpath, "revise", "my-post-1.log")
+	logBytes, err := os.ReadFile(logFilepath)
+	if err != nil || !strings.Contains(string(logBytes), "watching") {
+		t.Errorf("expected the watcher's output in %s, got: '%s', %v", logFilepath, logBytes, err)
+	}
+}
+
+func TestRunPublishWatcherNotifiesWhenForgeUnreachable(t *testing.T) {
+	newTestRepo(t)
+	notifier := installFakeNotifier(t)
+
+	// Without an origin remote, there's no forge to watch the PR on
+	if err := savePublishState(&PublishState{Branch: "my-post", PRNumber: 7, Status: PublishStatusWatching}); err != nil {
+		t.Fatalf("failed to save publish state: %v", err)
+	}
+	if err := runPublishWatcher("my-post"); err == nil {
+		t.Fatal("expected the watcher to fail")
+	}
+
+	state, err := loadPublishState("my-post")
+	if err != nil {
+		t.Fatalf("expected the state to load, got: %v", err)
+	}
+	if state.Status != PublishStatusFailed || !isPublishResumable(state) {
+		t.Errorf("expected a resumable failed publish, got: %+v", state)
+	}
+	if len(notifier.notifications) != 1 || !strings.Contains(notifier.notifications[0], "Couldn't connect to the forge for 'my-post'") {
+		t.Errorf("expected a notification that the forge couldn't be reached, got: %v", notifier.notifications)
+	}
+}
+
+func TestRunPublishWatcherNotifiesWhenChecksFail(t *testing.T) {
+	repoPath := newTestRepo(t)
+	notifier := installFakeNotifier(t)
+
+	serverURL := newFakeForge(t, "Authorization", "Bearer "+testGitHubToken, map[string]http.HandlerFunc{
+		"GET " + testGitHubRepoPath("pulls/7"): func(writer http.ResponseWriter, request *http.Request) {
+			respondJSON(writer, http.StatusOK, map[string]interface{}{
+				"number":   7,
+				"state":    "open",
+				"html_url": "https://github.com/owner/repo/pull/7",
+				"head":     map[string]string{"ref": "my-post", "sha": "abc"},
+				"base":     map[string]string{"ref": MainBranchName},
+			})
+		},
+		"GET " + testGitHubRepoPath("commits/abc/status"): func(writer http.ResponseWriter, request *http.Request) {
+			respondJSON(writer, http.StatusOK, map[string]interface{}{"statuses": []interface{}{}})
+		},
+		"GET " + testGitHubRepoPath("commits/abc/check-runs"): func(writer http.ResponseWriter, request *http.Request) {
+			respondJSON(writer, http.StatusOK, map[string]interface{}{
+				"check_runs": []map[string]string{{"name": "spellcheck", "status": "completed", "conclusion": "failure"}},
+			})
+		},
+		"GET " + testGitHubRepoPath("branches/"+MainBranchName): func(writer http.ResponseWriter, request *http.Request) {
+			respondJSON(writer, http.StatusOK, map[string]interface{}{})
+		},
+		"GET " + testGitHubRepoPath("pulls/7/reviews"): func(writer http.ResponseWriter, request *http.Request) {
+			respondJSON(writer, http.StatusOK, []interface{}{})
+		},
+	})
+	runTestGit(t, repoPath, "remote", "add", "origin", fmt.Sprintf("git@github.com:%s/%s.git", testGitHubOwner, testGitHubRepo))
+	envFileContents := fmt.Sprintf("%s=%s\n%s=%s\n", GitHubAPIURLEnvVar, serverURL, GitHubTokenEnvVar, testGitHubToken)
+	if err := os.WriteFile(filepath.Join(repoPath, EnvFilename), []byte(envFileContents), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	state := &PublishState{
+		Branch:   "my-post",
+		PRNumber: 7,
+		PRURL:    "https://github.com/owner/repo/pull/7",
+		Status:   PublishStatusWatching,
+		Options:  PublishOptions{MergeMethod: MergeMethodSquash},
+	}
+	if err := savePublishState(state); err != nil {
+		t.Fatalf("failed to save publish state: %v", err)
+	}
+
+	captureStdout(t, func() {
+		if err := runPublishWatcher("my-post"); err == nil {
+			t.Error("expected the watcher to fail when a check fails")
+		}
+	})
+
+	savedState, err := loadPublishState("my-post")
+	if err != nil {
+		t.Fatalf("expected the state to load, got: %v", err)
+	}
+	if savedState.Status != PublishStatusFailed || !strings.Contains(savedState.Message, "Wait for all checks to pass") {
+		t.Errorf("expected the publish to have failed waiting for checks, got: %+v", savedState)
+	}
+	if savedState.PID != os.Getpid() {
+		t.Errorf("expected the watcher to record its own PID, got: %d", savedState.PID)
+	}
+	expectedNotification := "❌ Publishing failed: Checks for 'my-post' failed: https://github.com/owner/repo/pull/7"
+	if len(notifier.notifications) != 1 || notifier.notifications[0] != expectedNotification {
+		t.Errorf("expected a notification that the checks failed, got: %v", notifier.notifications)
+	}
+}
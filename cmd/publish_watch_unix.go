@@ -0,0 +1,21 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detachProcess starts the command in a new session so that closing the terminal doesn't kill it
+func detachProcess(command *exec.Cmd) {
+	command.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
+
+func isProcessRunning(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	// Signal 0 checks for the process's existence without actually signalling it
+	return syscall.Kill(pid, 0) == nil
+}
@@ -0,0 +1,33 @@
+//go:build windows
+
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// Not exported by the syscall package
+const detachedProcessFlag = 0x00000008
+
+// detachProcess starts the command without a console and in its own process group, so that closing the
+// terminal or pressing Ctrl+C in it doesn't kill the command
+func detachProcess(command *exec.Cmd) {
+	command.SysProcAttr = &syscall.SysProcAttr{
+		CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP | detachedProcessFlag,
+	}
+}
+
+func isProcessRunning(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	// On Windows, finding a process opens a handle to it, which fails if there's no such process
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	process.Release()
+	return true
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+const (
+	DefaultSubstackURL = "<your Substack URL here>/post/new"
+	SubstackURLEnvVar  = "SUBSTACK_URL"
+)
+
+// Publisher hands a merged post off to the platform where it gets published
+type Publisher interface {
+	// Name returns the human-readable name of the publishing platform
+	Name() string
+
+	// PublishNewPost publishes a post that has never been published before
+	PublishNewPost(postDir string) error
+
+	// UpdatePost pushes changes to a post that has already been published
+	UpdatePost(postDir string) error
+
+	// RemovePost takes down a published post whose directory was deleted
+	RemovePost(postDir string) error
+}
+
+// substackPublisher renders the post locally and tells the user where to paste it, since Substack
+// has no API for creating or editing posts
+type substackPublisher struct {
+	baseURL string
+}
+
+func newSubstackPublisher() *substackPublisher {
+	return &substackPublisher{
+		baseURL: getSubstackBaseURL(),
+	}
+}
+
+func (publisher *substackPublisher) Name() string {
+	return "Substack"
+}
+
+func (publisher *substackPublisher) PublishNewPost(postDir string) error {
+	showRenderedPost(postDir)
+
+	// Print Substack URL
+	substackURL := DefaultSubstackURL
+	if publisher.baseURL != "" {
+		substackURL = publisher.baseURL + "/publish/post?type=newsletter"
+	}
+	fmt.Println("\nPaste the rendered post into:")
+	fmt.Println(substackURL)
+
+	publisher.printConfigurationTip()
+	return nil
+}
+
+func (publisher *substackPublisher) UpdatePost(postDir string) error {
+	showRenderedPost(postDir)
+
+	// Substack posts can only be edited through the dashboard, so point the user at their published posts
+	substackURL := DefaultSubstackURL
+	if publisher.baseURL != "" {
+		substackURL = publisher.baseURL + "/publish/posts/published"
+	}
+	fmt.Printf("\nOpen the published version of '%s' and replace its content with the rendered post:\n", postDir)
+	fmt.Println(substackURL)
+
+	publisher.printConfigurationTip()
+	return nil
+}
+
+func (publisher *substackPublisher) RemovePost(postDir string) error {
+	// The post directory no longer exists, so there's nothing to render
+	substackURL := DefaultSubstackURL
+	if publisher.baseURL != "" {
+		substackURL = publisher.baseURL + "/publish/posts/published"
+	}
+	fmt.Printf("\nThe '%s' post was deleted; unpublish it manually from:\n", postDir)
+	fmt.Println(substackURL)
+
+	publisher.printConfigurationTip()
+	return nil
+}
+
+func (publisher *substackPublisher) printConfigurationTip() {
+	// Show tip if using placeholder URL
+	if publisher.baseURL == "" {
+		fmt.Printf("\n💡 Tip: Create a %s file with %s=https://yourname.substack.com to get a working link.\n", EnvFilename, SubstackURLEnvVar)
+	}
+}
+
+// getSubstackBaseURL returns the configured Substack URL, or the empty string if none is configured
+func getSubstackBaseURL() string {
+	return getConfigValue(SubstackURLEnvVar)
+}
+
+// showRenderedPost renders the post to HTML and opens it for the user to copy from. It never fails, since it
+// runs after the post has been merged; if the post can't be rendered or opened, the user is told where to find it.
+func showRenderedPost(postDir string) {
+	postPath := filepath.Join(postDir, PostFilename)
+	renderedPath, err := renderPostToHTML(postDir)
+	if err != nil {
+		fmt.Printf("⚠️  Couldn't render %s, so showing the Markdown instead: %v\n", postPath, err)
+		renderedPath = postPath
+	}
+
+	description, err := newOpener().Open(renderedPath)
+	if err != nil {
+		fmt.Printf("⚠️  %v\n", err)
+		fmt.Printf("Open %s to see the post\n", renderedPath)
+		return
+	}
+	fmt.Println(description)
+}
@@ -0,0 +1,265 @@
+package cmd
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/yuin/goldmark/ast"
+	extast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// Sentences ending in one of these, followed by a space, keep going
+var sentenceAbbreviations = map[string]bool{
+	"mr": true, "mrs": true, "ms": true, "dr": true, "prof": true, "sr": true, "jr": true, "st": true,
+	"vs": true, "e.g": true, "i.e": true, "cf": true, "approx": true, "no": true, "fig": true,
+}
+
+var sentenceEndRegex = regexp.MustCompile(`[.!?]+["'”’)\]]*\s+`)
+
+// proseBlock is the text of a paragraph or heading, with its formatting, code and HTML taken out
+type proseBlock struct {
+	text    string
+	heading bool
+
+	// Where each piece of the text starts, in the text and in the post file
+	pieces []prosePiece
+}
+
+type prosePiece struct {
+	textOffset int
+	fileOffset int
+}
+
+// proseSpan is a range of bytes in a block of prose
+type proseSpan struct {
+	start int
+	end   int
+}
+
+// fileOffset returns where the given position in the block's text is in the post file
+func (block proseBlock) fileOffset(textOffset int) int {
+	piece := block.pieces[0]
+	for _, candidate := range block.pieces {
+		if candidate.textOffset > textOffset {
+			break
+		}
+		piece = candidate
+	}
+	return piece.fileOffset + textOffset - piece.textOffset
+}
+
+// extractProse returns the paragraphs and headings of a post's Markdown body, skipping its front matter, code, HTML,
+// tables and image alt text. The content must already have its line endings normalized.
+func extractProse(content string) []proseBlock {
+	_, body, _ := splitFrontMatter(content)
+	bodyOffset := len(content) - len(body)
+	source := []byte(body)
+	document := lintMarkdownParser.Parse(text.NewReader(source))
+
+	var blocks []proseBlock
+	var current *proseBlock
+	blockStart := 0
+	addText := func(value string, fileOffset int) {
+		current.pieces = append(current.pieces, prosePiece{textOffset: len(current.text), fileOffset: fileOffset})
+		current.text += value
+	}
+	// Where text with no position of its own would be, if it were in the file
+	nextFileOffset := func() int {
+		if len(current.pieces) == 0 {
+			return blockStart
+		}
+		last := current.pieces[len(current.pieces)-1]
+		return last.fileOffset + len(current.text) - last.textOffset
+	}
+
+	_ = ast.Walk(document, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		switch node := node.(type) {
+		case *ast.Paragraph, *ast.TextBlock, *ast.Heading:
+			if entering {
+				_, isHeading := node.(*ast.Heading)
+				current = &proseBlock{heading: isHeading}
+				if node.Lines().Len() > 0 {
+					blockStart = bodyOffset + node.Lines().At(0).Start
+				}
+			} else {
+				if strings.TrimSpace(current.text) != "" {
+					blocks = append(blocks, *current)
+				}
+				current = nil
+			}
+		case *ast.CodeBlock, *ast.FencedCodeBlock, *ast.HTMLBlock, *extast.Table:
+			return ast.WalkSkipChildren, nil
+		case *ast.CodeSpan, *ast.RawHTML, *ast.AutoLink, *ast.Image:
+			if entering && current != nil {
+				// Keeps the words on either side apart
+				addText(" ", nextFileOffset())
+			}
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			if entering && current != nil {
+				addText(string(node.Segment.Value(source)), bodyOffset+node.Segment.Start)
+				if node.SoftLineBreak() || node.HardLineBreak() {
+					addText(" ", bodyOffset+node.Segment.Stop)
+				}
+			}
+		case *ast.String:
+			if entering && current != nil {
+				addText(string(node.Value), nextFileOffset())
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return blocks
+}
+
+// splitSentences returns where each sentence in a block of prose starts and ends
+func splitSentences(prose string) []proseSpan {
+	var sentences []proseSpan
+	addSentence := func(start int, end int) {
+		for start < end && unicode.IsSpace(rune(prose[start])) {
+			start++
+		}
+		for end > start && unicode.IsSpace(rune(prose[end-1])) {
+			end--
+		}
+		if spellWordRegex.MatchString(prose[start:end]) {
+			sentences = append(sentences, proseSpan{start: start, end: end})
+		}
+	}
+
+	start := 0
+	for _, location := range sentenceEndRegex.FindAllStringIndex(prose, -1) {
+		// A sentence only ends if the next one starts like one
+		if location[1] < len(prose) {
+			next := []rune(prose[location[1]:])[0]
+			if unicode.IsLower(next) {
+				continue
+			}
+		}
+
+		// Abbreviations and initials end in a period without ending the sentence
+		if prose[location[0]] == '.' && strings.Count(strings.TrimRight(prose[location[0]:location[1]], " \t\n"), ".") == 1 {
+			before := prose[start:location[0]]
+			lastWord := strings.ToLower(before[strings.LastIndexFunc(before, unicode.IsSpace)+1:])
+			if sentenceAbbreviations[lastWord] || (len([]rune(lastWord)) == 1 && unicode.IsLetter([]rune(lastWord)[0])) {
+				continue
+			}
+		}
+
+		addSentence(start, location[1])
+		start = location[1]
+	}
+	addSentence(start, len(prose))
+	return sentences
+}
+
+// countSyllables estimates how many syllables an English word has from its vowels, which is right for most words
+// and close enough for readability scores
+func countSyllables(word string) int {
+	word = strings.ToLower(strings.ReplaceAll(word, "’", "'"))
+	word = strings.TrimSuffix(word, "'s")
+	letters := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r
+		}
+		return -1
+	}, word)
+	if len(letters) <= 3 {
+		return 1
+	}
+
+	// Endings that don't add a syllable
+	switch {
+	case strings.HasSuffix(letters, "es") && !hasAnySuffix(letters, "ses", "zes", "ces", "ges", "xes", "shes", "ches"):
+		letters = letters[:len(letters)-2]
+	case strings.HasSuffix(letters, "ed") && !hasAnySuffix(letters, "ted", "ded"):
+		letters = letters[:len(letters)-2]
+	case strings.HasSuffix(letters, "e") && !strings.HasSuffix(letters, "le"):
+		letters = letters[:len(letters)-1]
+	}
+
+	syllables := 0
+	previousWasVowel := false
+	for idx, letter := range letters {
+		isVowel := strings.ContainsRune("aeiou", letter) || (letter == 'y' && idx > 0)
+		if isVowel && !previousWasVowel {
+			syllables++
+		}
+		previousWasVowel = isVowel
+	}
+	return max(syllables, 1)
+}
+
+func hasAnySuffix(word string, suffixes ...string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(word, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// fleschKincaidGrade is the US school grade needed to follow text with the given words per sentence and syllables
+// per word
+func fleschKincaidGrade(wordsPerSentence float64, syllablesPerWord float64) float64 {
+	return 0.39*wordsPerSentence + 11.8*syllablesPerWord - 15.59
+}
+
+// ReadabilityScores are the standard readability formulas, all but Flesch reading ease being US school grades
+type ReadabilityScores struct {
+	// 0-100, higher being easier
+	FleschReadingEase         float64 `json:"flesch_reading_ease"`
+	FleschKincaidGrade        float64 `json:"flesch_kincaid_grade"`
+	GunningFog                float64 `json:"gunning_fog"`
+	SMOG                      float64 `json:"smog"`
+	ColemanLiau               float64 `json:"coleman_liau"`
+	AutomatedReadabilityIndex float64 `json:"automated_readability_index"`
+}
+
+// computeReadability scores text from its counts of words, sentences, syllables, letters, and words with three or
+// more syllables
+func computeReadability(words int, sentences int, syllables int, letters int, polysyllables int) ReadabilityScores {
+	if words == 0 || sentences == 0 {
+		return ReadabilityScores{}
+	}
+	wordsPerSentence := float64(words) / float64(sentences)
+	syllablesPerWord := float64(syllables) / float64(words)
+	lettersPerWord := float64(letters) / float64(words)
+
+	return ReadabilityScores{
+		FleschReadingEase:         roundScore(206.835 - 1.015*wordsPerSentence - 84.6*syllablesPerWord),
+		FleschKincaidGrade:        roundScore(fleschKincaidGrade(wordsPerSentence, syllablesPerWord)),
+		GunningFog:                roundScore(0.4 * (wordsPerSentence + 100*float64(polysyllables)/float64(words))),
+		SMOG:                      roundScore(1.043*math.Sqrt(float64(polysyllables)*30/float64(sentences)) + 3.1291),
+		ColemanLiau:               roundScore(0.0588*lettersPerWord*100 - 0.296*100/wordsPerSentence - 15.8),
+		AutomatedReadabilityIndex: roundScore(4.71*lettersPerWord + 0.5*wordsPerSentence - 21.43),
+	}
+}
+
+// describeReadingEase puts a Flesch reading ease score into words
+func describeReadingEase(score float64) string {
+	switch {
+	case score >= 90:
+		return "very easy"
+	case score >= 80:
+		return "easy"
+	case score >= 70:
+		return "fairly easy"
+	case score >= 60:
+		return "plain English"
+	case score >= 50:
+		return "fairly difficult"
+	case score >= 30:
+		return "difficult"
+	default:
+		return "very difficult"
+	}
+}
+
+// roundScore rounds to one decimal place
+func roundScore(score float64) float64 {
+	return math.Round(score*10) / 10
+}
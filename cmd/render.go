@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kurtosis-tech/stacktrace"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+const (
+	// Relative to the repo's .git directory
+	renderedPostsDirpath = "opwriting/rendered"
+)
+
+// The page the rendered post is shown in, kept plain so that what's copied out of it pastes cleanly into
+// the publishing platform's editor. The base URL makes the post's relative image links resolve.
+const renderedPostTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<base href="%s">
+<title>%s</title>
+<style>
+body { max-width: 42em; margin: 2em auto; padding: 0 1em; font-family: Georgia, serif; font-size: 1.1em; line-height: 1.6; }
+img { max-width: 100%%; }
+pre { overflow-x: auto; }
+.post-header { color: #666; border-bottom: 1px solid #ddd; margin-bottom: 2em; font-family: sans-serif; font-size: 0.9em; }
+.series-nav { color: #444; }
+</style>
+</head>
+<body>
+<div class="post-header">
+%s
+</div>
+%s%s%s
+</body>
+</html>
+`
+
+var markdownRenderer = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+)
+
+// renderPostToHTML renders the post in the given directory to a standalone HTML page inside the repo's .git
+// directory, returning the page's path. The front matter's title and summary are shown above the post, since
+// they're entered separately from the body on most platforms.
+func renderPostToHTML(postDir string) (string, error) {
+	// Post directories are relative to the top of the repo, not to wherever this is run from
+	repoRootDirpath, err := getRepoRootDirpath()
+	if err != nil {
+		return "", err
+	}
+	absPostDir := filepath.Join(repoRootDirpath, postDir)
+	postFilepath := filepath.Join(absPostDir, PostFilename)
+	content, err := os.ReadFile(postFilepath)
+	if err != nil {
+		return "", stacktrace.Propagate(err, "failed to read post: %s", postFilepath)
+	}
+
+	frontMatter, body, err := parsePost(string(content))
+	if err != nil {
+		return "", stacktrace.Propagate(err, "failed to parse post: %s", postFilepath)
+	}
+
+	// Wiki links to other posts become links to wherever those posts were published
+	body, err = resolveWikiLinks(repoRootDirpath, body)
+	if err != nil {
+		return "", stacktrace.Propagate(err, "failed to resolve the links to other posts in: %s", postFilepath)
+	}
+
+	var renderedBody bytes.Buffer
+	if err := markdownRenderer.Convert([]byte(body), &renderedBody); err != nil {
+		return "", stacktrace.Propagate(err, "failed to render post: %s", postFilepath)
+	}
+
+	title := frontMatter.Title
+	if title == "" {
+		title = filepath.Base(postDir)
+	}
+	var header strings.Builder
+	fmt.Fprintf(&header, "<p>Title: <strong>%s</strong></p>\n", html.EscapeString(title))
+	if frontMatter.Summary != "" {
+		fmt.Fprintf(&header, "<p>Subtitle: <em>%s</em></p>\n", html.EscapeString(frontMatter.Summary))
+	}
+
+	// Posts in a series are rendered with navigation between its parts
+	seriesHeader, seriesFooter := "", ""
+	if frontMatter.Series != "" {
+		if seriesHeader, seriesFooter, err = renderSeriesNavigation(repoRootDirpath, postDir, frontMatter.Series); err != nil {
+			return "", err
+		}
+	}
+
+	baseURL := "file://" + filepath.ToSlash(absPostDir) + "/"
+	page := fmt.Sprintf(renderedPostTemplate, html.EscapeString(baseURL), html.EscapeString(title), header.String(), seriesHeader, renderedBody.String(), seriesFooter)
+
+	gitDirpath, err := getGitCommonDirpath()
+	if err != nil {
+		return "", err
+	}
+	// Nested post directories are flattened into the file name
+	htmlFilename := strings.ReplaceAll(filepath.ToSlash(filepath.Clean(postDir)), "/", "_") + ".html"
+	htmlFilepath := filepath.Join(gitDirpath, renderedPostsDirpath, htmlFilename)
+	if err := os.MkdirAll(filepath.Dir(htmlFilepath), 0755); err != nil {
+		return "", stacktrace.Propagate(err, "failed to create rendered posts directory")
+	}
+	if err := os.WriteFile(htmlFilepath, []byte(page), 0644); err != nil {
+		return "", stacktrace.Propagate(err, "failed to write rendered post: %s", htmlFilepath)
+	}
+	return htmlFilepath, nil
+}
@@ -0,0 +1,244 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kurtosis-tech/stacktrace"
+	"github.com/spf13/cobra"
+)
+
+var reviewCommentsContext int
+
+var reviewCmd = &cobra.Command{
+	Use:   "review",
+	Short: "Work with editors' reviews of the current branch's PR",
+	Long: `Editors review posts on the forge; these commands request their reviews and bring their
+feedback back to the terminal.`,
+}
+
+var reviewRequestCmd = &cobra.Command{
+	Use:   "request user [user...]",
+	Short: "Ask users to review the current branch's PR",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  requestReview,
+}
+
+var reviewCommentsCmd = &cobra.Command{
+	Use:   "comments",
+	Short: "Show the review comments on the current branch's PR inline with the posts",
+	Long: `Pull down the comments left on the current branch's PR and show each one beneath the line of
+the post it was left on, with the lines around it for context. Comments on the PR as a whole,
+and on lines that have changed since, are listed separately.`,
+	Args: cobra.NoArgs,
+	RunE: showReviewComments,
+}
+
+func init() {
+	reviewCommentsCmd.Flags().IntVarP(&reviewCommentsContext, "context", "C", 2, "Number of lines to show around each commented line")
+
+	reviewCmd.AddCommand(reviewRequestCmd)
+	reviewCmd.AddCommand(reviewCommentsCmd)
+}
+
+func requestReview(cmd *cobra.Command, args []string) error {
+	forge, pullRequest, err := getPRForCurrentBranch()
+	if err != nil {
+		return err
+	}
+
+	if err := forge.RequestReviewers(pullRequest.Number, args); err != nil {
+		return stacktrace.Propagate(err, "failed to request reviews")
+	}
+	fmt.Printf("Requested reviews from %s on %s\n", strings.Join(args, ", "), pullRequest.URL)
+	return nil
+}
+
+func showReviewComments(cmd *cobra.Command, args []string) error {
+	forge, pullRequest, err := getPRForCurrentBranch()
+	if err != nil {
+		return err
+	}
+
+	comments, err := forge.GetReviewComments(pullRequest.Number)
+	if err != nil {
+		return stacktrace.Propagate(err, "failed to get review comments")
+	}
+	if len(comments) == 0 {
+		fmt.Printf("No comments on %s yet\n", pullRequest.URL)
+		return nil
+	}
+	fmt.Printf("💬 %d comment(s) on %s\n", len(comments), pullRequest.URL)
+
+	// Group the comments by the file they're on, with comments on the PR as a whole under the empty path
+	commentsByPath := map[string][]ReviewComment{}
+	for _, comment := range comments {
+		commentsByPath[comment.Path] = append(commentsByPath[comment.Path], comment)
+	}
+
+	if generalComments, found := commentsByPath[""]; found {
+		sort.SliceStable(generalComments, func(i, j int) bool {
+			return generalComments[i].CreatedAt.Before(generalComments[j].CreatedAt)
+		})
+		fmt.Println()
+		fmt.Println("On the PR:")
+		for _, comment := range generalComments {
+			printReviewComment(comment, "  ")
+		}
+	}
+
+	var paths []string
+	for path := range commentsByPath {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	writingRepoPath := os.Getenv(WritingDirEnvVar)
+	for _, path := range paths {
+		fmt.Println()
+		fmt.Printf("%s:\n", path)
+		if err := printFileWithComments(filepath.Join(writingRepoPath, path), commentsByPath[path], reviewCommentsContext); err != nil {
+			return stacktrace.Propagate(err, "failed to show comments on '%s'", path)
+		}
+	}
+	return nil
+}
+
+// getPRForCurrentBranch finds the PR for the branch that's checked out in the writing repo
+func getPRForCurrentBranch() (Forge, *MergeRequest, error) {
+	if err := validateWritingDirectory(); err != nil {
+		return nil, nil, stacktrace.Propagate(err, "directory validation failed")
+	}
+
+	currentBranch, err := getCurrentBranch()
+	if err != nil {
+		return nil, nil, stacktrace.Propagate(err, "failed to get current branch")
+	}
+	if currentBranch == MainBranchName {
+		return nil, nil, stacktrace.NewError("posts on %s have no PR to review; check out the post's branch", MainBranchName)
+	}
+
+	forge, err := newForgeForCurrentRepo()
+	if err != nil {
+		return nil, nil, stacktrace.Propagate(err, "failed to create forge client")
+	}
+
+	pullRequest, err := getPRForBranch(forge, currentBranch)
+	if err != nil {
+		return nil, nil, err
+	}
+	if pullRequest == nil {
+		return nil, nil, stacktrace.NewError("branch '%s' has no PR yet; run 'opwriting publish --draft' to open one for review", currentBranch)
+	}
+	return forge, pullRequest, nil
+}
+
+// printFileWithComments prints each commented line of the file with the given number of lines around it,
+// followed by its comments. Comments on lines that have since changed are printed after.
+func printFileWithComments(filePath string, comments []ReviewComment, context int) error {
+	sort.SliceStable(comments, func(i, j int) bool {
+		if comments[i].Line != comments[j].Line {
+			return comments[i].Line < comments[j].Line
+		}
+		return comments[i].CreatedAt.Before(comments[j].CreatedAt)
+	})
+
+	var lines []string
+	if content, err := os.ReadFile(filePath); err == nil {
+		lines = strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	} else if !os.IsNotExist(err) {
+		return stacktrace.Propagate(err, "failed to read file: %s", filePath)
+	}
+
+	commentsByLine := map[int][]ReviewComment{}
+	var outdatedComments []ReviewComment
+	for _, comment := range comments {
+		// Comments can't be shown inline if the line is gone, or the file has been deleted locally
+		if comment.Line == 0 || comment.Line > len(lines) {
+			outdatedComments = append(outdatedComments, comment)
+			continue
+		}
+		commentsByLine[comment.Line] = append(commentsByLine[comment.Line], comment)
+	}
+
+	// Work out which lines to print, so that overlapping context isn't printed twice
+	linesToPrint := map[int]bool{}
+	for line := range commentsByLine {
+		for contextLine := line - context; contextLine <= line+context; contextLine++ {
+			if contextLine >= 1 && contextLine <= len(lines) {
+				linesToPrint[contextLine] = true
+			}
+		}
+	}
+
+	lastPrintedLine := 0
+	for lineNumber := 1; lineNumber <= len(lines); lineNumber++ {
+		if !linesToPrint[lineNumber] {
+			continue
+		}
+		if lastPrintedLine != 0 && lineNumber > lastPrintedLine+1 {
+			fmt.Println("   ...")
+		}
+		fmt.Printf("%5d | %s\n", lineNumber, lines[lineNumber-1])
+		for _, comment := range commentsByLine[lineNumber] {
+			printReviewComment(comment, "      | ")
+		}
+		lastPrintedLine = lineNumber
+	}
+
+	if len(outdatedComments) > 0 {
+		fmt.Println("  On lines that have since changed:")
+		for _, comment := range outdatedComments {
+			printReviewComment(comment, "  ")
+		}
+	}
+	return nil
+}
+
+func printReviewComment(comment ReviewComment, prefix string) {
+	bodyLines := strings.Split(strings.TrimSpace(comment.Body), "\n")
+	fmt.Printf("%s💬 %s: %s\n", prefix, comment.Author, bodyLines[0])
+	// Line the rest of the comment up with the author's name, past the (double-width) emoji
+	continuationPrefix := prefix + "   "
+	for _, bodyLine := range bodyLines[1:] {
+		fmt.Printf("%s%s\n", continuationPrefix, bodyLine)
+	}
+}
+
+// summarizeReviews works out who currently approves of the PR and who is requesting changes, going by
+// each reviewer's most recent approval, request for changes, or dismissal
+func summarizeReviews(reviews []Review) ([]string, []string) {
+	latestStateByAuthor := map[string]string{}
+	var authors []string
+	for _, review := range reviews {
+		state := strings.ToUpper(review.State)
+		switch state {
+		case "APPROVED", "CHANGES_REQUESTED", "REQUEST_CHANGES", "DISMISSED":
+		default:
+			// Comments don't change a reviewer's verdict
+			continue
+		}
+
+		if _, found := latestStateByAuthor[review.Author]; !found {
+			authors = append(authors, review.Author)
+		}
+		latestStateByAuthor[review.Author] = state
+	}
+
+	var approvers []string
+	var changesRequestedBy []string
+	for _, author := range authors {
+		switch latestStateByAuthor[author] {
+		case "APPROVED":
+			approvers = append(approvers, author)
+		case "CHANGES_REQUESTED", "REQUEST_CHANGES":
+			changesRequestedBy = append(changesRequestedBy, author)
+		}
+	}
+	return approvers, changesRequestedBy
+}
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/kurtosis-tech/stacktrace"
+	"github.com/spf13/cobra"
+)
+
+const (
+	RevisionBranchPrefix = "revise/"
+
+	RevisionFrontMatterKey = "revision"
+)
+
+// Revision branches look like 'revise/<post dir>-<N>'
+var revisionBranchRegex = regexp.MustCompile(`^` + regexp.QuoteMeta(RevisionBranchPrefix) + `(.+)-(\d+)$`)
+
+var reviseCmd = &cobra.Command{
+	Use:   "revise post_dir",
+	Short: "Create a revision branch for an already-published post",
+	Long: `Create a new '` + RevisionBranchPrefix + `<post>-N' branch off main for editing a post that has already
+been published, so that corrections go through a PR like new posts do. The branch
+number is incremented for each revision of the same post, and recorded as '` + RevisionFrontMatterKey + `' in the
+post's front matter so that numbers aren't reused once revision branches are merged and deleted.`,
+	Args: cobra.ExactArgs(1),
+	RunE: revisePost,
+}
+
+func revisePost(cmd *cobra.Command, args []string) error {
+	// Get writing directory from environment variable
+	writingRepoPath := os.Getenv(WritingDirEnvVar)
+	if writingRepoPath == "" {
+		return stacktrace.NewError("writing directory not configured: %s environment variable not set", WritingDirEnvVar)
+	}
+
+	postDir := filepath.Clean(strings.TrimSuffix(args[0], "/"))
+	if postDir == TemplateDirname {
+		return stacktrace.NewError("can't revise the %s directory", TemplateDirname)
+	}
+
+	// Only published posts (i.e. those on main) can be revised
+	checkPostCmd := exec.Command("git", "-C", writingRepoPath, "cat-file", "-e", fmt.Sprintf("%s:%s/%s", MainBranchName, postDir, PostFilename))
+	if err := checkPostCmd.Run(); err != nil {
+		return stacktrace.NewError("can't revise post; no published post found on %s at: %s", MainBranchName, postDir)
+	}
+
+	revisionNumber, err := getNextRevisionNumber(writingRepoPath, postDir)
+	if err != nil {
+		return stacktrace.Propagate(err, "failed to determine the next revision number for post: %s", postDir)
+	}
+	branchName := fmt.Sprintf("%s%s-%d", RevisionBranchPrefix, postDir, revisionNumber)
+
+	// Create the revision branch off main and check it out
+	checkoutNewBranchCmd := exec.Command("git", "-C", writingRepoPath, "checkout", "-b", branchName, MainBranchName)
+	if output, err := checkoutNewBranchCmd.CombinedOutput(); err != nil {
+		return stacktrace.NewError("failed to check out new branch '%s': %s", branchName, string(output))
+	}
+
+	if err := recordRevisionNumber(writingRepoPath, postDir, revisionNumber); err != nil {
+		return stacktrace.Propagate(err, "failed to record the revision number in post: %s", postDir)
+	}
+
+	// Output the post directory path
+	fmt.Println(filepath.Join(writingRepoPath, postDir))
+	return nil
+}
+
+// recordRevisionNumber sets the revision number in the post's front matter and commits it, so that it reaches main
+// when the revision is merged
+func recordRevisionNumber(repoPath string, postDir string, revisionNumber int) error {
+	postFilepath := filepath.Join(repoPath, postDir, PostFilename)
+	content, err := os.ReadFile(postFilepath)
+	if err != nil {
+		return stacktrace.Propagate(err, "failed to read post: %s", postFilepath)
+	}
+	updatedContent := setFrontMatterField(string(content), RevisionFrontMatterKey, strconv.Itoa(revisionNumber))
+	if err := os.WriteFile(postFilepath, []byte(updatedContent), 0644); err != nil {
+		return stacktrace.Propagate(err, "failed to write post: %s", postFilepath)
+	}
+
+	commitCmd := exec.Command("git", "-C", repoPath, "commit", "-m", fmt.Sprintf("Start revision %d of %s", revisionNumber, postDir), "--", filepath.Join(postDir, PostFilename))
+	if output, err := commitCmd.CombinedOutput(); err != nil {
+		return stacktrace.NewError("failed to commit the revision number: %s", string(output))
+	}
+	return nil
+}
+
+// getNextRevisionNumber returns one more than the highest revision number used by any local or remote revision
+// branch for the given post, or recorded in the published post by revisions that have since been merged
+func getNextRevisionNumber(repoPath, postDir string) (int, error) {
+	content, err := readPostFromBranch(repoPath, MainBranchName, postDir)
+	if err != nil {
+		return 0, err
+	}
+	frontMatter, _, err := parsePost(content)
+	if err != nil {
+		return 0, stacktrace.Propagate(err, "failed to parse post '%s' on %s", postDir, MainBranchName)
+	}
+	highestNumber := frontMatter.Revision
+
+	cmd := exec.Command("git", "-C", repoPath, "branch", "--all", "--format=%(refname:short)")
+	output, err := cmd.Output()
+	if err != nil {
+		return 0, stacktrace.Propagate(err, "failed to list branches")
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		// Remote branches are listed as e.g. 'origin/revise/some-post-1'
+		branch := strings.TrimSpace(scanner.Text())
+		if idx := strings.Index(branch, RevisionBranchPrefix); idx > 0 {
+			branch = branch[idx:]
+		}
+
+		revisedPostDir, revisionNumber, isRevision := parseRevisionBranch(branch)
+		if !isRevision || revisedPostDir != postDir {
+			continue
+		}
+		if revisionNumber > highestNumber {
+			highestNumber = revisionNumber
+		}
+	}
+
+	return highestNumber + 1, nil
+}
+
+// parseRevisionBranch extracts the post directory and revision number from a revision branch name
+func parseRevisionBranch(branch string) (string, int, bool) {
+	matches := revisionBranchRegex.FindStringSubmatch(branch)
+	if matches == nil {
+		return "", 0, false
+	}
+
+	revisionNumber, err := strconv.Atoi(matches[2])
+	if err != nil {
+		return "", 0, false
+	}
+
+	return matches[1], revisionNumber, true
+}
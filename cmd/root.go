@@ -27,4 +27,20 @@ func init() {
 	rootCmd.AddCommand(addCmd)
 	rootCmd.AddCommand(shellCmd)
 	rootCmd.AddCommand(publishCmd)
+	rootCmd.AddCommand(doctorCmd)
+	rootCmd.AddCommand(reviseCmd)
+	rootCmd.AddCommand(reviewCmd)
+	rootCmd.AddCommand(scheduleCmd)
+	rootCmd.AddCommand(schedulerCmd)
+	rootCmd.AddCommand(lintCmd)
+	rootCmd.AddCommand(checkCmd)
+	rootCmd.AddCommand(imagesCmd)
+	rootCmd.AddCommand(a11yCmd)
+	rootCmd.AddCommand(spellCmd)
+	rootCmd.AddCommand(analyzeCmd)
+	rootCmd.AddCommand(statsCmd)
+	rootCmd.AddCommand(goalCmd)
+	rootCmd.AddCommand(tagsCmd)
+	rootCmd.AddCommand(seriesCmd)
+	rootCmd.AddCommand(backlinksCmd)
 }
\ No newline at end of file
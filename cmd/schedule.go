@@ -0,0 +1,256 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kurtosis-tech/stacktrace"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// IANA time zone (e.g. 'America/New_York') that publish dates without an explicit offset are in, and that
+	// scheduled posts are listed in; defaults to the system's local time zone
+	TimezoneEnvVar = "TIMEZONE"
+
+	PublishAtFrontMatterKey = "publish_at"
+
+	scheduledTimeFormat = "Mon Jan 2 2006 15:04 MST"
+)
+
+// Publish dates may include an offset, in which case they're absolute, or not, in which case they're in the
+// configured time zone
+var (
+	publishAtLayoutsWithZone = []string{
+		time.RFC3339,
+		"2006-01-02T15:04Z07:00",
+		"2006-01-02 15:04:05Z07:00",
+		"2006-01-02 15:04Z07:00",
+	}
+	publishAtLayoutsWithoutZone = []string{
+		"2006-01-02T15:04:05",
+		"2006-01-02T15:04",
+		"2006-01-02 15:04:05",
+		"2006-01-02 15:04",
+		"2006-01-02",
+	}
+)
+
+var scheduleClear bool
+
+// ScheduledPost is a post on an unmerged branch with a publish date
+type ScheduledPost struct {
+	Dir       string
+	Branch    string
+	PublishAt time.Time
+}
+
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule post_dir datetime",
+	Short: "Schedule a post to be published at a future time",
+	Long: `Set the post's '` + PublishAtFrontMatterKey + `' front matter field and commit it on the current branch.
+The datetime is either absolute (e.g. '2024-06-01T09:00:00-04:00') or in the time zone set with
+` + TimezoneEnvVar + ` in ` + EnvFilename + ` (e.g. '2024-06-01 09:00', or '2024-06-01' for midnight).
+'opwriting publish' opens the post's PR but leaves the merge to 'opwriting scheduler run',
+which merges and publishes the post once its time has come. Use --clear to unschedule the post.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: schedulePost,
+}
+
+func init() {
+	scheduleCmd.Flags().BoolVar(&scheduleClear, "clear", false, "Remove the post's publish date, so it's published as soon as its checks pass")
+}
+
+func schedulePost(cmd *cobra.Command, args []string) error {
+	if scheduleClear != (len(args) == 1) {
+		return stacktrace.NewError("give either a datetime or --clear")
+	}
+
+	if err := validateWritingDirectory(); err != nil {
+		return stacktrace.Propagate(err, "directory validation failed")
+	}
+	writingRepoPath := os.Getenv(WritingDirEnvVar)
+
+	currentBranch, err := getCurrentBranch()
+	if err != nil {
+		return stacktrace.Propagate(err, "failed to get current branch")
+	}
+	if currentBranch == MainBranchName {
+		return stacktrace.NewError("posts on %s are already published; check out the post's branch to schedule it", MainBranchName)
+	}
+
+	postDir := filepath.Clean(strings.TrimSuffix(args[0], "/"))
+	postFilepath := filepath.Join(writingRepoPath, postDir, PostFilename)
+	content, err := os.ReadFile(postFilepath)
+	if err != nil {
+		return stacktrace.Propagate(err, "failed to read post: %s", postFilepath)
+	}
+
+	var publishAt time.Time
+	publishAtValue := ""
+	commitMessage := fmt.Sprintf("Unschedule %s", postDir)
+	if !scheduleClear {
+		publishAt, err = parsePublishAt(args[1])
+		if err != nil {
+			return err
+		}
+		// Stored with an explicit offset, so the post's publish date doesn't depend on who's reading it
+		publishAtValue = publishAt.Format(time.RFC3339)
+		commitMessage = fmt.Sprintf("Schedule %s for %s", postDir, publishAtValue)
+
+		if publishAt.Before(time.Now()) {
+			fmt.Printf("⚠️  %s is in the past, so the post will be published on the next scheduler run\n", formatScheduledTime(publishAt))
+		}
+	}
+
+	updatedContent := setFrontMatterField(string(content), PublishAtFrontMatterKey, publishAtValue)
+	if err := os.WriteFile(postFilepath, []byte(updatedContent), 0644); err != nil {
+		return stacktrace.Propagate(err, "failed to write post: %s", postFilepath)
+	}
+
+	// Commit just the post, since the scheduler reads the publish date from the branch
+	commitCmd := exec.Command("git", "-C", writingRepoPath, "commit", "-m", commitMessage, "--", filepath.Join(postDir, PostFilename))
+	if output, err := commitCmd.CombinedOutput(); err != nil {
+		return stacktrace.NewError("failed to commit the publish date: %s", string(output))
+	}
+
+	if scheduleClear {
+		fmt.Printf("Unscheduled %s\n", postDir)
+		return nil
+	}
+	fmt.Printf("Scheduled %s for %s\n", postDir, formatScheduledTime(publishAt))
+	fmt.Println("Run 'opwriting publish' to open its PR, and run 'opwriting scheduler run' regularly (e.g. from cron) to publish it on time.")
+	return nil
+}
+
+// parsePublishAt parses a publish date, interpreting dates without an offset in the configured time zone
+func parsePublishAt(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range publishAtLayoutsWithZone {
+		if publishAt, err := time.Parse(layout, value); err == nil {
+			return publishAt, nil
+		}
+	}
+
+	location, err := getScheduleLocation()
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, layout := range publishAtLayoutsWithoutZone {
+		if publishAt, err := time.ParseInLocation(layout, value, location); err == nil {
+			return publishAt, nil
+		}
+	}
+
+	return time.Time{}, stacktrace.NewError("couldn't parse publish date '%s'; use a format like '2006-01-02 15:04' or '2006-01-02T15:04:05-07:00'", value)
+}
+
+func getScheduleLocation() (*time.Location, error) {
+	timezone := getConfigValue(TimezoneEnvVar)
+	if timezone == "" {
+		return time.Local, nil
+	}
+
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "unrecognized time zone '%s' in %s", timezone, TimezoneEnvVar)
+	}
+	return location, nil
+}
+
+// formatScheduledTime shows the time in the configured time zone
+func formatScheduledTime(publishAt time.Time) string {
+	location, err := getScheduleLocation()
+	if err != nil {
+		location = time.Local
+	}
+	return publishAt.In(location).Format(scheduledTimeFormat)
+}
+
+// getPostPublishAt returns the post's publish date as of the given branch, or the zero time if it has none
+func getPostPublishAt(repoPath string, branch string, postDir string) (time.Time, error) {
+	content, err := readPostFromBranch(repoPath, branch, postDir)
+	if err != nil {
+		return time.Time{}, err
+	}
+	frontMatter, _, err := parsePost(content)
+	if err != nil {
+		return time.Time{}, stacktrace.Propagate(err, "failed to parse post '%s' on branch '%s'", postDir, branch)
+	}
+	if frontMatter.PublishAt == "" {
+		return time.Time{}, nil
+	}
+
+	publishAt, err := parsePublishAt(frontMatter.PublishAt)
+	if err != nil {
+		return time.Time{}, stacktrace.Propagate(err, "invalid %s in post '%s' on branch '%s'", PublishAtFrontMatterKey, postDir, branch)
+	}
+	return publishAt, nil
+}
+
+// collectScheduledPosts finds every post with a publish date on a branch that hasn't been merged yet,
+// soonest first. Only the posts a branch changed count, since a branch's stale copies of published posts
+// still have their old (past) publish dates.
+func collectScheduledPosts(repoPath string) ([]ScheduledPost, error) {
+	branches, err := getBranchesSortedByDistance(repoPath)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to get unmerged branches")
+	}
+
+	var scheduledPosts []ScheduledPost
+	for _, branchDist := range branches {
+		affectedPosts, err := getRepoAffectedPosts(repoPath, branchDist.Branch)
+		if err != nil {
+			return nil, stacktrace.Propagate(err, "failed to find the posts changed on branch '%s'", branchDist.Branch)
+		}
+		for _, post := range affectedPosts {
+			if post.Change == PostDeleted {
+				continue
+			}
+
+			publishAt, err := getPostPublishAt(repoPath, branchDist.Branch, post.Dir)
+			if err != nil {
+				return nil, err
+			}
+			if publishAt.IsZero() {
+				continue
+			}
+			scheduledPosts = append(scheduledPosts, ScheduledPost{
+				Dir:       post.Dir,
+				Branch:    branchDist.Branch,
+				PublishAt: publishAt,
+			})
+		}
+	}
+
+	sort.SliceStable(scheduledPosts, func(i, j int) bool {
+		return scheduledPosts[i].PublishAt.Before(scheduledPosts[j].PublishAt)
+	})
+	return scheduledPosts, nil
+}
+
+// getBranchPublishAt returns when the branch's posts are due to go out, which is the latest of their publish
+// dates since the branch is published as a whole, or the zero time if none of them are scheduled
+func getBranchPublishAt(branch string, affectedPosts []AffectedPost) (time.Time, error) {
+	var latest time.Time
+	for _, post := range affectedPosts {
+		// Deleted posts have no front matter left to schedule them with
+		if post.Change == PostDeleted {
+			continue
+		}
+
+		publishAt, err := getPostPublishAt(".", branch, post.Dir)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if publishAt.After(latest) {
+			latest = publishAt
+		}
+	}
+	return latest, nil
+}
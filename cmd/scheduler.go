@@ -0,0 +1,259 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/kurtosis-tech/stacktrace"
+	"github.com/spf13/cobra"
+)
+
+var schedulerCmd = &cobra.Command{
+	Use:   "scheduler",
+	Short: "Publish scheduled posts once their time comes",
+	Long: `Posts are scheduled with 'opwriting schedule'. 'scheduler run' is meant to be run regularly
+(e.g. every 15 minutes from cron), and 'scheduler list' shows what's coming up.`,
+}
+
+var schedulerRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Merge and publish every scheduled post that's due",
+	Long: `Find every branch whose scheduled posts are due, then open its PR if needed, check its approvals
+and checks once, and merge and publish it if they've passed. Nothing blocks: a PR that isn't
+ready yet is picked up again on the next run, and running it repeatedly is safe since each
+branch's progress is recorded. Publishes that failed are reported rather than retried; finish
+them with 'opwriting publish --resume'. The working tree is only touched if it's clean and on
+main or the branch being published; otherwise the post is merged and the rest is left for
+'opwriting publish --resume'.`,
+	Args: cobra.NoArgs,
+	RunE: runScheduler,
+}
+
+var schedulerListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List scheduled posts, soonest first",
+	Args:  cobra.NoArgs,
+	RunE:  listScheduledPosts,
+}
+
+func init() {
+	schedulerCmd.AddCommand(schedulerRunCmd)
+	schedulerCmd.AddCommand(schedulerListCmd)
+}
+
+func listScheduledPosts(cmd *cobra.Command, args []string) error {
+	writingRepoPath, err := changeToWritingRepo()
+	if err != nil {
+		return err
+	}
+
+	scheduledPosts, err := collectScheduledPosts(writingRepoPath)
+	if err != nil {
+		return stacktrace.Propagate(err, "failed to find scheduled posts")
+	}
+	if len(scheduledPosts) == 0 {
+		fmt.Println("No posts are scheduled")
+		return nil
+	}
+
+	now := time.Now()
+	for _, post := range scheduledPosts {
+		when := fmt.Sprintf("in %s", formatTimeUntil(post.PublishAt.Sub(now)))
+		if !post.PublishAt.After(now) {
+			when = "due now"
+		}
+
+		fmt.Printf("🗓️  %s  %s (%s)\n", formatScheduledTime(post.PublishAt), post.Dir, when)
+		fmt.Printf("    Branch: %s", post.Branch)
+		if state, err := loadPublishState(post.Branch); err == nil {
+			fmt.Printf(" (publish %s)", state.Status)
+		} else {
+			fmt.Print(" (no PR yet; 'scheduler run' will open one when it's due)")
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+func runScheduler(cmd *cobra.Command, args []string) error {
+	writingRepoPath, err := changeToWritingRepo()
+	if err != nil {
+		return err
+	}
+
+	scheduledPosts, err := collectScheduledPosts(writingRepoPath)
+	if err != nil {
+		return stacktrace.Propagate(err, "failed to find scheduled posts")
+	}
+
+	// A branch is due once all of its scheduled posts are, since it's merged as a whole
+	var branches []string
+	branchPublishAt := map[string]time.Time{}
+	for _, post := range scheduledPosts {
+		if _, found := branchPublishAt[post.Branch]; !found {
+			branches = append(branches, post.Branch)
+		}
+		if post.PublishAt.After(branchPublishAt[post.Branch]) {
+			branchPublishAt[post.Branch] = post.PublishAt
+		}
+	}
+
+	now := time.Now()
+	var dueBranches []string
+	for _, branch := range branches {
+		if !branchPublishAt[branch].After(now) {
+			dueBranches = append(dueBranches, branch)
+		}
+	}
+	if len(dueBranches) == 0 {
+		fmt.Println("No scheduled posts are due")
+		return nil
+	}
+
+	forge, err := newForgeForCurrentRepo()
+	if err != nil {
+		return stacktrace.Propagate(err, "failed to create forge client")
+	}
+
+	// Each check happens once per run; the next run checks again
+	publishPollOnce = true
+
+	notifier := newDesktopNotifier()
+	var failedBranches []string
+	for _, branch := range dueBranches {
+		fmt.Printf("\n🗓️  '%s' was scheduled for %s\n", branch, formatScheduledTime(branchPublishAt[branch]))
+		if err := publishScheduledBranch(forge, branch, branchPublishAt[branch]); err != nil {
+			fmt.Printf("❌ Publishing '%s' failed: %v\n", branch, err)
+			notifier.Notify("❌ Scheduled publish failed", fmt.Sprintf("Couldn't publish '%s'; run 'opwriting publish --resume %s'", branch, branch))
+			failedBranches = append(failedBranches, branch)
+			continue
+		}
+
+		state, err := loadPublishState(branch)
+		if err == nil && state.Status == PublishStatusPublished {
+			notifier.Notify("✅ Scheduled post published", fmt.Sprintf("'%s' was merged and published", branch))
+		}
+	}
+
+	if len(failedBranches) > 0 {
+		return stacktrace.NewError("failed to publish scheduled branch(es): %s", strings.Join(failedBranches, ", "))
+	}
+	return nil
+}
+
+// publishScheduledBranch takes the publish of a due branch as far as it can go without waiting, picking up
+// wherever an earlier publish or scheduler run left off
+func publishScheduledBranch(forge Forge, branch string, publishAt time.Time) error {
+	var publishCtx *publishContext
+	state, err := loadPublishState(branch)
+	switch {
+	case err != nil:
+		// Never published, so start from scratch with the configured defaults
+		options, err := getConfigPublishOptions()
+		if err != nil {
+			return stacktrace.Propagate(err, "invalid publish options")
+		}
+		publishCtx, err = newPublishContext(forge, branch, options)
+		if err != nil {
+			return stacktrace.Propagate(err, "failed to plan publish")
+		}
+		state = newPublishState(publishCtx)
+	case state.Status == PublishStatusPublished:
+		fmt.Println("Already published")
+		return nil
+	case state.Status == PublishStatusDraft:
+		fmt.Println("Its PR is still a draft; run 'opwriting publish' on the branch once it's ready")
+		return nil
+	case state.Status == PublishStatusFailed:
+		fmt.Printf("Skipping, since publishing it failed earlier: %s\n", state.Message)
+		return nil
+	case isPublishInProgress(state):
+		fmt.Printf("Skipping, since it's already being published (PID %d)\n", state.PID)
+		return nil
+	default:
+		publishCtx, err = newPublishContextFromState(forge, state)
+		if err != nil {
+			return stacktrace.Propagate(err, "failed to load publish")
+		}
+	}
+
+	// The posts may have been rescheduled since the publish was started
+	state.PublishAt = publishAt
+
+	safeToCleanUp, err := isSafeForScheduledCleanup(branch)
+	if err != nil {
+		return stacktrace.Propagate(err, "failed to check whether local cleanup is safe")
+	}
+
+	plan := &PublishPlan{context: publishCtx, state: state}
+	plan.Steps = append(plan.Steps, buildPRSteps(publishCtx)...)
+	plan.Steps = append(plan.Steps, buildWaitSteps(publishCtx)...)
+	plan.Steps = append(plan.Steps, buildMergeSteps(publishCtx, safeToCleanUp)...)
+
+	state.PID = os.Getpid()
+	if err := plan.Execute(); err != nil {
+		return err
+	}
+
+	if state.Status == PublishStatusInterrupted {
+		message := "Not ready to merge yet; the next 'opwriting scheduler run' will check again"
+		fmt.Println(message)
+		return plan.saveProgress(PublishStatusInterrupted, message)
+	}
+	if !safeToCleanUp {
+		message := fmt.Sprintf("Merged, but the working tree is busy so local cleanup and publishing were skipped; run 'opwriting publish --resume %s' to finish up", branch)
+		fmt.Println(message)
+		return plan.saveProgress(PublishStatusInterrupted, message)
+	}
+	return plan.saveProgress(PublishStatusPublished, "Merged and published")
+}
+
+// isSafeForScheduledCleanup returns true if the working tree is clean and on either main or the given branch,
+// so the scheduler can switch it to main without getting in the user's way
+func isSafeForScheduledCleanup(branch string) (bool, error) {
+	currentBranch, err := getCurrentBranch()
+	if err != nil {
+		return false, stacktrace.Propagate(err, "failed to get current branch")
+	}
+	if currentBranch != branch && currentBranch != MainBranchName {
+		return false, nil
+	}
+
+	cmd := exec.Command("git", "status", "--porcelain")
+	output, err := cmd.Output()
+	if err != nil {
+		return false, stacktrace.Propagate(err, "failed to get working tree status")
+	}
+	return strings.TrimSpace(string(output)) == "", nil
+}
+
+// changeToWritingRepo switches to the configured writing repo, since cron runs the scheduler from wherever but
+// publishing works on the repo in the working directory
+func changeToWritingRepo() (string, error) {
+	writingRepoPath := os.Getenv(WritingDirEnvVar)
+	if writingRepoPath == "" {
+		return "", stacktrace.NewError("writing directory not configured: %s environment variable not set", WritingDirEnvVar)
+	}
+	if err := os.Chdir(writingRepoPath); err != nil {
+		return "", stacktrace.Propagate(err, "failed to change to writing directory: %s", writingRepoPath)
+	}
+	return writingRepoPath, nil
+}
+
+// formatTimeUntil describes a duration in days, hours, and minutes (e.g. '2d 3h')
+func formatTimeUntil(duration time.Duration) string {
+	days := int(duration.Hours()) / 24
+	hours := int(duration.Hours()) % 24
+	minutes := int(duration.Minutes()) % 60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
@@ -0,0 +1,236 @@
+package cmd
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kurtosis-tech/stacktrace"
+	"github.com/spf13/cobra"
+)
+
+// SeriesPost is a post's place in a series
+type SeriesPost struct {
+	Dir       string
+	Title     string
+	Part      int
+	PublishAt string
+}
+
+var seriesCmd = &cobra.Command{
+	Use:   "series [name]",
+	Short: "List series of posts and their parts, in order",
+	Long: `List every series in posts' front matter across all branches, with its posts in order, warning about
+parts that share a number or numbers that are skipped. Posts join a series with 'series' in their front
+matter and are ordered by 'series_part', then by 'publish_at'. Given a series name, only that series is
+listed. Rendered posts in a series get a 'part N of M' line above them and a list of the series' parts
+below them.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runSeriesCommand,
+}
+
+func runSeriesCommand(cmd *cobra.Command, args []string) error {
+	writingRepoPath := os.Getenv(WritingDirEnvVar)
+	if writingRepoPath == "" {
+		return stacktrace.NewError("writing directory not configured: %s environment variable not set", WritingDirEnvVar)
+	}
+
+	postEntries, err := collectPostEntries(writingRepoPath)
+	if err != nil {
+		return stacktrace.Propagate(err, "failed to collect posts across branches")
+	}
+
+	// A post with several versions is listed as its first one, which is main's if it's been published
+	seenDirs := map[string]bool{}
+	postsBySeries := map[string][]SeriesPost{}
+	for _, entry := range postEntries {
+		if entry.Dir == TemplateDirname || seenDirs[entry.Dir] {
+			continue
+		}
+		seenDirs[entry.Dir] = true
+		frontMatter, err := readPostEntryFrontMatter(writingRepoPath, entry)
+		if err != nil {
+			return err
+		}
+		if frontMatter.Series == "" {
+			continue
+		}
+		postsBySeries[frontMatter.Series] = append(postsBySeries[frontMatter.Series], newSeriesPost(entry.Dir, frontMatter))
+	}
+
+	var seriesNames []string
+	for name := range postsBySeries {
+		if len(args) == 0 || strings.EqualFold(name, args[0]) {
+			seriesNames = append(seriesNames, name)
+		}
+	}
+	if len(seriesNames) == 0 {
+		if len(args) > 0 {
+			return stacktrace.NewError("no posts are in a series called '%s'", args[0])
+		}
+		fmt.Println("No posts are in a series")
+		return nil
+	}
+	sort.Strings(seriesNames)
+
+	for idx, name := range seriesNames {
+		if idx > 0 {
+			fmt.Println()
+		}
+		posts := postsBySeries[name]
+		sortSeriesPosts(posts)
+		fmt.Printf("📚 %s (%d part(s))\n", name, len(posts))
+		for partIdx, post := range posts {
+			fmt.Printf("  %2d. %s (%s)\n", partIdx+1, post.Title, post.Dir)
+		}
+		for _, problem := range findSeriesProblems(posts) {
+			fmt.Printf("  ⚠️  %s\n", problem)
+		}
+	}
+	return nil
+}
+
+func newSeriesPost(postDir string, frontMatter *PostFrontMatter) SeriesPost {
+	title := frontMatter.Title
+	if title == "" {
+		title = filepath.Base(postDir)
+	}
+	return SeriesPost{
+		Dir:       postDir,
+		Title:     title,
+		Part:      frontMatter.SeriesPart,
+		PublishAt: frontMatter.PublishAt,
+	}
+}
+
+// sortSeriesPosts puts a series' posts in reading order: numbered parts first, then the rest by publish date
+func sortSeriesPosts(posts []SeriesPost) {
+	sort.SliceStable(posts, func(i, j int) bool {
+		first, second := posts[i], posts[j]
+		if (first.Part > 0) != (second.Part > 0) {
+			return first.Part > 0
+		}
+		if first.Part != second.Part {
+			return first.Part < second.Part
+		}
+		if first.PublishAt != second.PublishAt {
+			// Posts without a publish date haven't been scheduled, so they come last
+			if first.PublishAt == "" || second.PublishAt == "" {
+				return second.PublishAt == ""
+			}
+			firstPublishAt, firstErr := parsePublishAt(first.PublishAt)
+			secondPublishAt, secondErr := parsePublishAt(second.PublishAt)
+			if firstErr == nil && secondErr == nil {
+				return firstPublishAt.Before(secondPublishAt)
+			}
+		}
+		return first.Dir < second.Dir
+	})
+}
+
+// findSeriesProblems returns what's inconsistent about a sorted series' part numbers
+func findSeriesProblems(posts []SeriesPost) []string {
+	var problems []string
+	dirsByPart := map[int][]string{}
+	for _, post := range posts {
+		if post.Part > 0 {
+			dirsByPart[post.Part] = append(dirsByPart[post.Part], post.Dir)
+		}
+	}
+	for idx, post := range posts {
+		if post.Part <= 0 {
+			continue
+		}
+		if dirs := dirsByPart[post.Part]; len(dirs) > 1 && dirs[0] == post.Dir {
+			problems = append(problems, fmt.Sprintf("%s share part %d", strings.Join(dirs, ", "), post.Part))
+		}
+		if post.Part != idx+1 && len(dirsByPart[post.Part]) == 1 {
+			problems = append(problems, fmt.Sprintf("%s is numbered part %d but is part %d in order", post.Dir, post.Part, idx+1))
+		}
+	}
+	return problems
+}
+
+// getSeriesPosts returns the posts in the checked-out copy of the repo that are in the given series, in order,
+// including the given post even if it hasn't been committed yet
+func getSeriesPosts(repoPath string, series string, postDir string) ([]SeriesPost, error) {
+	postEntries, err := getPostDirsFromBranch(repoPath, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	postDirs := []string{postDir}
+	for _, entry := range postEntries {
+		if entry.Dir != TemplateDirname && entry.Dir != postDir {
+			postDirs = append(postDirs, entry.Dir)
+		}
+	}
+
+	var posts []SeriesPost
+	for _, dir := range postDirs {
+		// Read from the working tree, so that uncommitted changes to the series show up
+		postFilepath := filepath.Join(repoPath, dir, PostFilename)
+		content, err := os.ReadFile(postFilepath)
+		if err != nil {
+			// Committed posts that have since been deleted from the working tree are no longer in the series
+			if os.IsNotExist(err) && dir != postDir {
+				continue
+			}
+			return nil, stacktrace.Propagate(err, "failed to read post: %s", postFilepath)
+		}
+		frontMatter, _, err := parsePost(string(content))
+		if err != nil {
+			if dir != postDir {
+				continue
+			}
+			return nil, stacktrace.Propagate(err, "failed to parse post: %s", postFilepath)
+		}
+		if dir != postDir && !strings.EqualFold(frontMatter.Series, series) {
+			continue
+		}
+		posts = append(posts, newSeriesPost(dir, frontMatter))
+	}
+	sortSeriesPosts(posts)
+	return posts, nil
+}
+
+// renderSeriesNavigation returns the HTML shown above and below a post in a series: which part it is, and a list
+// of every part with the previous and next ones
+func renderSeriesNavigation(repoPath string, postDir string, series string) (string, string, error) {
+	postDir = filepath.ToSlash(filepath.Clean(postDir))
+	posts, err := getSeriesPosts(repoPath, series, postDir)
+	if err != nil {
+		return "", "", stacktrace.Propagate(err, "failed to find the posts in series '%s'", series)
+	}
+	currentIdx := 0
+	for idx, post := range posts {
+		if post.Dir == postDir {
+			currentIdx = idx
+		}
+	}
+
+	escapedSeries := html.EscapeString(series)
+	header := fmt.Sprintf("<p class=\"series-nav\"><em>Part %d of %d in the series <strong>%s</strong></em></p>\n", currentIdx+1, len(posts), escapedSeries)
+
+	var footer strings.Builder
+	footer.WriteString("<div class=\"series-nav\">\n")
+	fmt.Fprintf(&footer, "<p><strong>%s</strong></p>\n<ol>\n", escapedSeries)
+	for idx, post := range posts {
+		if idx == currentIdx {
+			fmt.Fprintf(&footer, "<li><strong>%s</strong> (this post)</li>\n", html.EscapeString(post.Title))
+		} else {
+			fmt.Fprintf(&footer, "<li>%s</li>\n", html.EscapeString(post.Title))
+		}
+	}
+	footer.WriteString("</ol>\n")
+	if currentIdx > 0 {
+		fmt.Fprintf(&footer, "<p>← Previous: %s</p>\n", html.EscapeString(posts[currentIdx-1].Title))
+	}
+	if currentIdx < len(posts)-1 {
+		fmt.Fprintf(&footer, "<p>Next: %s →</p>\n", html.EscapeString(posts[currentIdx+1].Title))
+	}
+	footer.WriteString("</div>\n")
+	return header, footer.String(), nil
+}
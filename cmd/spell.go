@@ -0,0 +1,454 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/kurtosis-tech/stacktrace"
+	"github.com/spf13/cobra"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+const (
+	// Words that are spelled right but that the dictionary doesn't know (names, jargon, etc.), one per line, kept
+	// at the root of the writing repo so that they're shared by everyone who writes in it
+	PersonalDictionaryFilename = ".overpowered-writing.dic"
+
+	// Optional Hunspell dictionary to use instead of the built-in English one, as the path of its .dic file
+	// (its .aff file must be next to it), relative to the writing repo
+	SpellDictionaryEnvVar = "SPELL_DICTIONARY"
+)
+
+var (
+	spellWordRegex  = regexp.MustCompile(`[\p{L}\p{N}]+(?:['’][\p{L}\p{N}]+)*`)
+	spellEmailRegex = regexp.MustCompile(`[\w.+-]+@[\w-]+(?:\.[\w-]+)+`)
+)
+
+// Misspelling is a word in a post that isn't in the dictionary
+type Misspelling struct {
+	Post string `json:"post"`
+	Line int    `json:"line"`
+
+	// 1-based, in characters
+	Column      int      `json:"column"`
+	Word        string   `json:"word"`
+	Suggestions []string `json:"suggestions"`
+
+	// Byte offset of the word in the post file
+	offset int
+}
+
+var spellFormat string
+var spellFix bool
+
+var spellCmd = &cobra.Command{
+	Use:   "spell [post_dir...]",
+	Short: "Check posts' spelling",
+	Long: `Check the spelling of posts' prose, skipping code, HTML, URLs, link destinations and the front matter.
+Words are checked against a built-in English dictionary (or the Hunspell dictionary set with
+` + SpellDictionaryEnvVar + ` in ` + EnvFilename + `), plus the words in the repo's ` + PersonalDictionaryFilename + `.
+
+With --fix, goes through the misspellings one at a time, offering to replace each one with a suggestion or
+your own text, add it to ` + PersonalDictionaryFilename + `, or ignore it. Picks posts the same way 'opwriting lint' does,
+and exits non-zero if there are misspellings.`,
+	RunE: runSpellCommand,
+}
+
+func init() {
+	spellCmd.Flags().StringVar(&spellFormat, "format", lintFormatHuman, "Output format: 'human' or 'json'")
+	spellCmd.Flags().BoolVar(&spellFix, "fix", false, "Fix misspellings interactively")
+}
+
+func runSpellCommand(cmd *cobra.Command, args []string) error {
+	if spellFormat != lintFormatHuman && spellFormat != lintFormatJSON {
+		return stacktrace.NewError("unrecognized format '%s'; use '%s' or '%s'", spellFormat, lintFormatHuman, lintFormatJSON)
+	}
+	if spellFix && spellFormat == lintFormatJSON {
+		return stacktrace.NewError("--fix can't be used with --format %s", lintFormatJSON)
+	}
+
+	writingRepoPath := os.Getenv(WritingDirEnvVar)
+	if writingRepoPath == "" {
+		return stacktrace.NewError("writing directory not configured: %s environment variable not set", WritingDirEnvVar)
+	}
+	absWritingRepoPath, err := filepath.Abs(writingRepoPath)
+	if err != nil {
+		return stacktrace.Propagate(err, "failed to get absolute path for writing directory")
+	}
+
+	dictionary, err := loadSpellingDictionary(absWritingRepoPath)
+	if err != nil {
+		return err
+	}
+	postDirs, err := resolvePostDirs(absWritingRepoPath, args)
+	if err != nil {
+		return err
+	}
+
+	if spellFix {
+		return fixMisspellingsInteractively(absWritingRepoPath, postDirs, dictionary, os.Stdin)
+	}
+
+	misspellings := []Misspelling{}
+	for _, postDir := range postDirs {
+		postMisspellings, err := spellCheckPost(absWritingRepoPath, postDir, dictionary)
+		if err != nil {
+			return stacktrace.Propagate(err, "failed to check the spelling of post '%s'", postDir)
+		}
+		misspellings = append(misspellings, postMisspellings...)
+	}
+
+	if spellFormat == lintFormatJSON {
+		encoded, err := json.MarshalIndent(misspellings, "", "  ")
+		if err != nil {
+			return stacktrace.Propagate(err, "failed to encode misspellings")
+		}
+		fmt.Println(string(encoded))
+	} else {
+		printMisspellings(postDirs, misspellings)
+	}
+
+	if len(misspellings) > 0 {
+		return stacktrace.NewError("found %d misspelled word(s)", len(misspellings))
+	}
+	return nil
+}
+
+// loadSpellingDictionary loads the configured dictionary, with the repo's personal dictionary added to it
+func loadSpellingDictionary(writingRepoPath string) (*Dictionary, error) {
+	var dictionary *Dictionary
+	var err error
+	if dicFilepath := getConfigValue(SpellDictionaryEnvVar); dicFilepath != "" {
+		if !filepath.IsAbs(dicFilepath) {
+			dicFilepath = filepath.Join(writingRepoPath, dicFilepath)
+		}
+		dictionary, err = loadHunspellDictionary(dicFilepath)
+	} else {
+		dictionary, err = loadBuiltInDictionary()
+	}
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to load the spelling dictionary")
+	}
+
+	personalWords, err := readPersonalDictionary(writingRepoPath)
+	if err != nil {
+		return nil, err
+	}
+	dictionary.AddWords(personalWords)
+	return dictionary, nil
+}
+
+// readPersonalDictionary returns the words in the repo's personal dictionary, or nothing if it doesn't have one
+func readPersonalDictionary(writingRepoPath string) ([]string, error) {
+	dictionaryFilepath := filepath.Join(writingRepoPath, PersonalDictionaryFilename)
+	content, err := os.ReadFile(dictionaryFilepath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to read personal dictionary: %s", dictionaryFilepath)
+	}
+
+	var words []string
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			words = append(words, line)
+		}
+	}
+	return words, nil
+}
+
+// addToPersonalDictionary adds a word to the repo's personal dictionary, creating it if need be
+func addToPersonalDictionary(writingRepoPath string, word string) error {
+	dictionaryFilepath := filepath.Join(writingRepoPath, PersonalDictionaryFilename)
+	words, err := readPersonalDictionary(writingRepoPath)
+	if err != nil {
+		return err
+	}
+	words = append(words, word)
+	// Sorted, so that two people adding words don't conflict as often
+	sort.Slice(words, func(i, j int) bool {
+		return strings.ToLower(words[i]) < strings.ToLower(words[j])
+	})
+	if err := os.WriteFile(dictionaryFilepath, []byte(strings.Join(words, "\n")+"\n"), 0644); err != nil {
+		return stacktrace.Propagate(err, "failed to write personal dictionary: %s", dictionaryFilepath)
+	}
+	return nil
+}
+
+// spellCheckPost returns the misspelled words in the post's prose, in order
+func spellCheckPost(writingRepoPath string, postDir string, dictionary *Dictionary) ([]Misspelling, error) {
+	postFilepath := filepath.Join(writingRepoPath, postDir, PostFilename)
+	rawContent, err := os.ReadFile(postFilepath)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to read post: %s", postFilepath)
+	}
+	content := strings.ReplaceAll(string(rawContent), "\r\n", "\n")
+
+	misspellings := findMisspellings(content, dictionary)
+	for idx := range misspellings {
+		misspellings[idx].Post = postDir
+	}
+	return misspellings, nil
+}
+
+// findMisspellings returns the misspelled words in a post's Markdown body, ignoring its front matter, code, HTML,
+// and URLs. Words with digits, words in all caps (which are usually acronyms), and words with capitals in the
+// middle (which are usually names of things, like 'iPhone') are skipped too.
+func findMisspellings(content string, dictionary *Dictionary) []Misspelling {
+	_, body, _ := splitFrontMatter(content)
+	bodyOffset := len(content) - len(body)
+	source := []byte(body)
+	document := lintMarkdownParser.Parse(text.NewReader(source))
+
+	misspellings := []Misspelling{}
+	suggestionsCache := map[string][]string{}
+	checkText := func(prose []byte, offset int) {
+		// URLs and email addresses aren't prose, but they're split up into words like it
+		for _, pattern := range []*regexp.Regexp{bareURLRegex, spellEmailRegex} {
+			prose = pattern.ReplaceAllFunc(prose, func(match []byte) []byte {
+				return []byte(strings.Repeat(" ", len(match)))
+			})
+		}
+
+		for _, location := range spellWordRegex.FindAllIndex(prose, -1) {
+			word := string(prose[location[0]:location[1]])
+			if !shouldSpellCheck(word) || dictionary.Check(word) {
+				continue
+			}
+			suggestions, found := suggestionsCache[word]
+			if !found {
+				suggestions = dictionary.Suggest(word)
+				if suggestions == nil {
+					suggestions = []string{}
+				}
+				suggestionsCache[word] = suggestions
+			}
+
+			wordOffset := offset + location[0]
+			lineStart := strings.LastIndex(content[:wordOffset], "\n") + 1
+			misspellings = append(misspellings, Misspelling{
+				Line:        strings.Count(content[:wordOffset], "\n") + 1,
+				Column:      utf8.RuneCountInString(content[lineStart:wordOffset]) + 1,
+				Word:        word,
+				Suggestions: suggestions,
+				offset:      wordOffset,
+			})
+		}
+	}
+
+	_ = ast.Walk(document, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node := node.(type) {
+		case *ast.CodeSpan, *ast.CodeBlock, *ast.FencedCodeBlock, *ast.HTMLBlock, *ast.RawHTML, *ast.AutoLink:
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			checkText(node.Segment.Value(source), bodyOffset+node.Segment.Start)
+		}
+		return ast.WalkContinue, nil
+	})
+	return misspellings
+}
+
+// shouldSpellCheck returns false for words that aren't worth checking: single letters, numbers, acronyms, and
+// names with capitals in the middle
+func shouldSpellCheck(word string) bool {
+	if utf8.RuneCountInString(word) < 2 {
+		return false
+	}
+	if strings.IndexFunc(word, unicode.IsDigit) >= 0 {
+		return false
+	}
+	if strings.ToUpper(word) == word {
+		return false
+	}
+	_, firstSize := utf8.DecodeRuneInString(word)
+	return strings.IndexFunc(word[firstSize:], unicode.IsUpper) < 0
+}
+
+func printMisspellings(postDirs []string, misspellings []Misspelling) {
+	if len(postDirs) == 0 {
+		fmt.Println("No posts to check")
+		return
+	}
+
+	misspellingsByPost := map[string][]Misspelling{}
+	for _, misspelling := range misspellings {
+		misspellingsByPost[misspelling.Post] = append(misspellingsByPost[misspelling.Post], misspelling)
+	}
+
+	for _, postDir := range postDirs {
+		postMisspellings := misspellingsByPost[postDir]
+		if len(postMisspellings) == 0 {
+			fmt.Printf("✅ %s\n", filepath.Join(postDir, PostFilename))
+			continue
+		}
+
+		fmt.Printf("%s\n", filepath.Join(postDir, PostFilename))
+		for _, misspelling := range postMisspellings {
+			suggestions := "no suggestions"
+			if len(misspelling.Suggestions) > 0 {
+				suggestions = "did you mean " + strings.Join(misspelling.Suggestions, ", ") + "?"
+			}
+			fmt.Printf("  ❌ %4d:%-3d %-20s %s\n", misspelling.Line, misspelling.Column, misspelling.Word, suggestions)
+		}
+	}
+	fmt.Printf("\n%d misspelled word(s)\n", len(misspellings))
+}
+
+// fixMisspellingsInteractively goes through each post's misspellings, asking what to do about each one, and
+// saves each post's fixes once all its misspellings have been dealt with (or the user quits)
+func fixMisspellingsInteractively(writingRepoPath string, postDirs []string, dictionary *Dictionary, input io.Reader) error {
+	reader := bufio.NewReader(input)
+	ignoredWords := map[string]bool{}
+
+	for _, postDir := range postDirs {
+		postFilepath := filepath.Join(writingRepoPath, postDir, PostFilename)
+		rawContent, err := os.ReadFile(postFilepath)
+		if err != nil {
+			return stacktrace.Propagate(err, "failed to read post: %s", postFilepath)
+		}
+		content := strings.ReplaceAll(string(rawContent), "\r\n", "\n")
+
+		misspellings := findMisspellings(content, dictionary)
+		if len(misspellings) == 0 {
+			fmt.Printf("✅ %s\n", filepath.Join(postDir, PostFilename))
+			continue
+		}
+		fmt.Printf("%s\n", filepath.Join(postDir, PostFilename))
+
+		// Byte offset -> what replaces the misspelled word there
+		fixes := map[int]string{}
+		quit := false
+		for _, misspelling := range misspellings {
+			if ignoredWords[misspelling.Word] || dictionary.Check(misspelling.Word) {
+				continue
+			}
+
+			lineStart := strings.LastIndex(content[:misspelling.offset], "\n") + 1
+			lineEnd := strings.Index(content[misspelling.offset:], "\n")
+			if lineEnd < 0 {
+				lineEnd = len(content)
+			} else {
+				lineEnd += misspelling.offset
+			}
+			wordEnd := misspelling.offset + len(misspelling.Word)
+			fmt.Printf("\n  %d: %s«%s»%s\n", misspelling.Line, content[lineStart:misspelling.offset], misspelling.Word, content[wordEnd:lineEnd])
+
+			var options []string
+			for idx, suggestion := range misspelling.Suggestions {
+				options = append(options, fmt.Sprintf("%d) %s", idx+1, suggestion))
+			}
+			options = append(options, "r) replace with...", "a) add to dictionary", "i) ignore", "I) ignore all", "q) quit")
+			fmt.Printf("  %s\n", strings.Join(options, "  "))
+
+			replacement, action, err := promptForSpellingFix(reader, misspelling)
+			if err != nil {
+				return err
+			}
+			switch action {
+			case "replace":
+				fixes[misspelling.offset] = replacement
+			case "add":
+				if err := addToPersonalDictionary(writingRepoPath, misspelling.Word); err != nil {
+					return err
+				}
+				dictionary.AddWords([]string{misspelling.Word})
+				fmt.Printf("  📖 Added '%s' to %s\n", misspelling.Word, PersonalDictionaryFilename)
+			case "ignore-all":
+				ignoredWords[misspelling.Word] = true
+			case "quit":
+				quit = true
+			}
+			if quit {
+				break
+			}
+		}
+
+		if len(fixes) > 0 {
+			if err := applySpellingFixes(postFilepath, content, misspellings, fixes); err != nil {
+				return err
+			}
+			fmt.Printf("\n✏️  Fixed %d word(s) in %s\n", len(fixes), filepath.Join(postDir, PostFilename))
+		}
+		if quit {
+			return nil
+		}
+	}
+	return nil
+}
+
+// promptForSpellingFix reads what the user wants to do about a misspelling, asking again until it's something
+// valid, and returns the action along with the replacement text if it's 'replace'
+func promptForSpellingFix(reader *bufio.Reader, misspelling Misspelling) (string, string, error) {
+	for {
+		fmt.Print("  > ")
+		answer, err := reader.ReadString('\n')
+		if err != nil && answer == "" {
+			if err == io.EOF {
+				return "", "quit", nil
+			}
+			return "", "", stacktrace.Propagate(err, "failed to read answer")
+		}
+		answer = strings.TrimSpace(answer)
+
+		if number, err := strconv.Atoi(answer); err == nil && number >= 1 && number <= len(misspelling.Suggestions) {
+			return misspelling.Suggestions[number-1], "replace", nil
+		}
+		switch answer {
+		case "r":
+			fmt.Print("  Replace with: ")
+			replacement, err := reader.ReadString('\n')
+			if err != nil && replacement == "" {
+				return "", "", stacktrace.Propagate(err, "failed to read replacement")
+			}
+			if replacement = strings.TrimSpace(replacement); replacement != "" {
+				return replacement, "replace", nil
+			}
+		case "a":
+			return "", "add", nil
+		case "i", "":
+			return "", "ignore", nil
+		case "I":
+			return "", "ignore-all", nil
+		case "q":
+			return "", "quit", nil
+		}
+		fmt.Println("  Pick a suggestion's number, or r, a, i, I, or q")
+	}
+}
+
+// applySpellingFixes replaces the misspelled words that the user chose replacements for and saves the post
+func applySpellingFixes(postFilepath string, content string, misspellings []Misspelling, fixes map[int]string) error {
+	var builder strings.Builder
+	previousEnd := 0
+	for _, misspelling := range misspellings {
+		replacement, found := fixes[misspelling.offset]
+		if !found {
+			continue
+		}
+		builder.WriteString(content[previousEnd:misspelling.offset])
+		builder.WriteString(replacement)
+		previousEnd = misspelling.offset + len(misspelling.Word)
+	}
+	builder.WriteString(content[previousEnd:])
+
+	if err := os.WriteFile(postFilepath, []byte(builder.String()), 0644); err != nil {
+		return stacktrace.Propagate(err, "failed to write post: %s", postFilepath)
+	}
+	return nil
+}
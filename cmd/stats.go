@@ -0,0 +1,489 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kurtosis-tech/stacktrace"
+	"github.com/spf13/cobra"
+)
+
+const (
+	statsDateFormat = "2006-01-02"
+
+	// Widest bar in the weekly chart, in characters
+	statsChartWidth = 40
+
+	// Starts each commit in the history that stats reads, followed by the commit's fields
+	statsCommitMarker   = "\x1e"
+	statsFieldSeparator = "\x1f"
+
+	// Subject of the commit that 'opwriting add' makes, followed by the post's directory
+	initialCommitPrefix = "Initial commit for "
+
+	defaultStatsWeeks = 12
+	daysPerWeek       = 7
+)
+
+// WritingStats is the writing activity found in the writing repo's history, across every branch
+type WritingStats struct {
+	PostsStarted   int `json:"posts_started"`
+	PostsPublished int `json:"posts_published"`
+	WordsAdded     int `json:"words_added"`
+	WordsRemoved   int `json:"words_removed"`
+
+	// In days, over the posts that have been published
+	AverageDaysToPublish float64 `json:"average_days_to_publish"`
+
+	// Consecutive days with words added, the current one counting if it ended today or yesterday
+	CurrentStreak int `json:"current_streak"`
+	LongestStreak int `json:"longest_streak"`
+
+	// Only the days with writing, oldest first
+	Days []DailyWritingStats `json:"days"`
+
+	// The most recent weeks, oldest first
+	Weeks []WeeklyWritingStats `json:"weeks"`
+
+	Posts []PostWritingStats `json:"posts"`
+}
+
+// DailyWritingStats is the words written on a day, in total and per post
+type DailyWritingStats struct {
+	Date         string                  `json:"date"`
+	WordsAdded   int                     `json:"words_added"`
+	WordsRemoved int                     `json:"words_removed"`
+	Posts        []PostDailyWritingStats `json:"posts"`
+}
+
+// PostDailyWritingStats is the words written in a post on a day
+type PostDailyWritingStats struct {
+	Post         string `json:"post"`
+	WordsAdded   int    `json:"words_added"`
+	WordsRemoved int    `json:"words_removed"`
+}
+
+// WeeklyWritingStats is the words written in a week, starting on Monday
+type WeeklyWritingStats struct {
+	WeekOf       string `json:"week_of"`
+	WordsAdded   int    `json:"words_added"`
+	WordsRemoved int    `json:"words_removed"`
+}
+
+// PostWritingStats is a post's history, from when it was started to when it was merged into main
+type PostWritingStats struct {
+	Post         string `json:"post"`
+	WordsAdded   int    `json:"words_added"`
+	WordsRemoved int    `json:"words_removed"`
+	ActiveDays   int    `json:"active_days"`
+
+	// From the 'Initial commit for <post>' commit that 'opwriting add' makes, or else the post's first commit
+	StartedAt time.Time `json:"started_at"`
+
+	// When the post first appeared on main; null for posts that haven't been published
+	PublishedAt   *time.Time `json:"published_at"`
+	DaysToPublish *float64   `json:"days_to_publish"`
+}
+
+// postCommit is one commit's changes to one post
+type postCommit struct {
+	post         string
+	authoredAt   time.Time
+	initial      bool
+	wordsAdded   int
+	wordsRemoved int
+}
+
+var (
+	statsFormat string
+	statsWeeks  int
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show writing statistics and streaks from the repo's history",
+	Long: `Mine the writing repo's history, across every branch, for how much was written: words added and
+removed per day and per post, posts started and published, how long each post took from its
+'Initial commit for <post>' commit to being merged into main, the current and longest daily writing
+streaks, and a chart of the words written each week. Days are in the time zone set with
+` + TimezoneEnvVar + `, if any. The template that 'opwriting add' copies into new posts isn't counted as writing.`,
+	Args: cobra.NoArgs,
+	RunE: runStatsCommand,
+}
+
+func init() {
+	statsCmd.Flags().StringVar(&statsFormat, "format", lintFormatHuman, "Output format: 'human' or 'json'")
+	statsCmd.Flags().IntVar(&statsWeeks, "weeks", defaultStatsWeeks, "How many weeks to chart")
+}
+
+func runStatsCommand(cmd *cobra.Command, args []string) error {
+	if statsFormat != lintFormatHuman && statsFormat != lintFormatJSON {
+		return stacktrace.NewError("unrecognized format '%s'; use '%s' or '%s'", statsFormat, lintFormatHuman, lintFormatJSON)
+	}
+	if statsWeeks < 1 {
+		return stacktrace.NewError("--weeks must be at least 1, but was %d", statsWeeks)
+	}
+
+	writingRepoPath := os.Getenv(WritingDirEnvVar)
+	if writingRepoPath == "" {
+		return stacktrace.NewError("writing directory not configured: %s environment variable not set", WritingDirEnvVar)
+	}
+	location, err := getScheduleLocation()
+	if err != nil {
+		return err
+	}
+
+	commits, err := getPostCommits(writingRepoPath)
+	if err != nil {
+		return err
+	}
+	publishedAt, err := getPostPublishTimes(writingRepoPath)
+	if err != nil {
+		return err
+	}
+	stats := computeWritingStats(commits, publishedAt, time.Now().In(location), statsWeeks)
+
+	if statsFormat == lintFormatJSON {
+		encoded, err := json.MarshalIndent(stats, "", "  ")
+		if err != nil {
+			return stacktrace.Propagate(err, "failed to encode writing stats")
+		}
+		fmt.Println(string(encoded))
+		return nil
+	}
+	printWritingStats(stats)
+	return nil
+}
+
+// getPostCommits returns every change to a post.md on any branch, with the number of words it added and removed
+func getPostCommits(writingRepoPath string) ([]postCommit, error) {
+	// Merge commits are skipped, since the commits they merge are already counted
+	cmd := exec.Command(
+		"git", "-C", writingRepoPath, "log", "--branches", "--remotes", "--no-merges", "-p", "--word-diff=porcelain",
+		"--format="+statsCommitMarker+"%at"+statsFieldSeparator+"%s",
+		"--", "*/"+PostFilename,
+	)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to read the history of the writing repo")
+	}
+
+	var commits []postCommit
+	var authoredAt time.Time
+	var subject string
+	var current *postCommit
+	var oldFilename string
+	inHunk := false
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, statsCommitMarker):
+			timestamp, commitSubject, _ := strings.Cut(strings.TrimPrefix(line, statsCommitMarker), statsFieldSeparator)
+			seconds, err := strconv.ParseInt(timestamp, 10, 64)
+			if err != nil {
+				return nil, stacktrace.Propagate(err, "failed to parse commit time '%s'", timestamp)
+			}
+			authoredAt = time.Unix(seconds, 0)
+			subject = commitSubject
+			current = nil
+		case strings.HasPrefix(line, "diff --git "):
+			inHunk = false
+			current = nil
+		case !inHunk && strings.HasPrefix(line, "--- "):
+			oldFilename = parseDiffFilename(strings.TrimPrefix(line, "--- "))
+		case !inHunk && strings.HasPrefix(line, "+++ "):
+			// Deleted posts only have their old file name
+			filename := parseDiffFilename(strings.TrimPrefix(line, "+++ "))
+			if filename == "" {
+				filename = oldFilename
+			}
+			postDir := path.Dir(filename)
+			if postDir == TemplateDirname {
+				continue
+			}
+			commits = append(commits, postCommit{
+				post:       postDir,
+				authoredAt: authoredAt,
+				initial:    subject == initialCommitPrefix+postDir,
+			})
+			current = &commits[len(commits)-1]
+		case strings.HasPrefix(line, "@@"):
+			inHunk = true
+		case inHunk && current != nil && strings.HasPrefix(line, "+"):
+			current.wordsAdded += len(strings.Fields(line[1:]))
+		case inHunk && current != nil && strings.HasPrefix(line, "-"):
+			current.wordsRemoved += len(strings.Fields(line[1:]))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, stacktrace.Propagate(err, "failed to read the history of the writing repo")
+	}
+	return commits, nil
+}
+
+// parseDiffFilename returns the file name in a diff's '---' or '+++' line, or nothing for /dev/null
+func parseDiffFilename(value string) string {
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		value = unquoted
+	}
+	if value == "/dev/null" {
+		return ""
+	}
+	// Drop the 'a/' or 'b/' prefix
+	_, filename, _ := strings.Cut(value, "/")
+	return filename
+}
+
+// getPostPublishTimes returns when each post was first merged into main
+func getPostPublishTimes(writingRepoPath string) (map[string]time.Time, error) {
+	// Following only the first parent, a merged branch's changes show up as part of the commit that merged it
+	cmd := exec.Command(
+		"git", "-C", writingRepoPath, "log", MainBranchName, "--first-parent", "--reverse", "--diff-filter=A",
+		"--name-only", "--format="+statsCommitMarker+"%ct",
+		"--", "*/"+PostFilename,
+	)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to read the history of %s", MainBranchName)
+	}
+
+	publishedAt := map[string]time.Time{}
+	var committedAt time.Time
+	for _, line := range strings.Split(string(output), "\n") {
+		if timestamp, found := strings.CutPrefix(line, statsCommitMarker); found {
+			seconds, err := strconv.ParseInt(timestamp, 10, 64)
+			if err != nil {
+				return nil, stacktrace.Propagate(err, "failed to parse commit time '%s'", timestamp)
+			}
+			committedAt = time.Unix(seconds, 0)
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		postDir := path.Dir(line)
+		if _, found := publishedAt[postDir]; !found && postDir != TemplateDirname {
+			publishedAt[postDir] = committedAt
+		}
+	}
+	return publishedAt, nil
+}
+
+// computeWritingStats totals up the commits by day, week and post, with days in now's time zone
+func computeWritingStats(commits []postCommit, publishedAt map[string]time.Time, now time.Time, weekCount int) *WritingStats {
+	location := now.Location()
+	stats := &WritingStats{
+		Days:  []DailyWritingStats{},
+		Weeks: []WeeklyWritingStats{},
+		Posts: []PostWritingStats{},
+	}
+
+	postStats := map[string]*PostWritingStats{}
+	postDays := map[string]map[string]bool{}
+	hasInitialCommit := map[string]bool{}
+	dayPosts := map[string]map[string]*PostDailyWritingStats{}
+	for _, commit := range commits {
+		post, found := postStats[commit.post]
+		if !found {
+			post = &PostWritingStats{Post: commit.post, StartedAt: commit.authoredAt}
+			postStats[commit.post] = post
+			postDays[commit.post] = map[string]bool{}
+		}
+		// The first commit is the start, unless there's an initial commit to go by
+		if commit.initial || (commit.authoredAt.Before(post.StartedAt) && !hasInitialCommit[commit.post]) {
+			post.StartedAt = commit.authoredAt
+		}
+		if commit.initial {
+			hasInitialCommit[commit.post] = true
+			// It only copies in the template
+			continue
+		}
+		if commit.wordsAdded == 0 && commit.wordsRemoved == 0 {
+			continue
+		}
+
+		date := commit.authoredAt.In(location).Format(statsDateFormat)
+		post.WordsAdded += commit.wordsAdded
+		post.WordsRemoved += commit.wordsRemoved
+		postDays[commit.post][date] = true
+
+		if dayPosts[date] == nil {
+			dayPosts[date] = map[string]*PostDailyWritingStats{}
+		}
+		dayPost, found := dayPosts[date][commit.post]
+		if !found {
+			dayPost = &PostDailyWritingStats{Post: commit.post}
+			dayPosts[date][commit.post] = dayPost
+		}
+		dayPost.WordsAdded += commit.wordsAdded
+		dayPost.WordsRemoved += commit.wordsRemoved
+	}
+
+	// Days
+	var dates []string
+	for date := range dayPosts {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+	activeDates := map[string]bool{}
+	for _, date := range dates {
+		day := DailyWritingStats{Date: date, Posts: []PostDailyWritingStats{}}
+		for _, dayPost := range dayPosts[date] {
+			day.WordsAdded += dayPost.WordsAdded
+			day.WordsRemoved += dayPost.WordsRemoved
+			day.Posts = append(day.Posts, *dayPost)
+		}
+		sort.Slice(day.Posts, func(i, j int) bool {
+			if day.Posts[i].WordsAdded != day.Posts[j].WordsAdded {
+				return day.Posts[i].WordsAdded > day.Posts[j].WordsAdded
+			}
+			return day.Posts[i].Post < day.Posts[j].Post
+		})
+		stats.Days = append(stats.Days, day)
+		stats.WordsAdded += day.WordsAdded
+		stats.WordsRemoved += day.WordsRemoved
+		if day.WordsAdded > 0 {
+			activeDates[date] = true
+		}
+	}
+	stats.CurrentStreak, stats.LongestStreak = computeStreaks(activeDates, now)
+
+	// Weeks
+	thisWeek := startOfWeek(now)
+	weeksByStart := map[string]*WeeklyWritingStats{}
+	for offset := weekCount - 1; offset >= 0; offset-- {
+		weekOf := thisWeek.AddDate(0, 0, -daysPerWeek*offset).Format(statsDateFormat)
+		stats.Weeks = append(stats.Weeks, WeeklyWritingStats{WeekOf: weekOf})
+	}
+	for idx := range stats.Weeks {
+		weeksByStart[stats.Weeks[idx].WeekOf] = &stats.Weeks[idx]
+	}
+	for _, day := range stats.Days {
+		date, _ := time.ParseInLocation(statsDateFormat, day.Date, location)
+		if week, found := weeksByStart[startOfWeek(date).Format(statsDateFormat)]; found {
+			week.WordsAdded += day.WordsAdded
+			week.WordsRemoved += day.WordsRemoved
+		}
+	}
+
+	// Posts
+	totalDaysToPublish := 0.0
+	for postDir, post := range postStats {
+		post.ActiveDays = len(postDays[postDir])
+		stats.PostsStarted++
+		if published, found := publishedAt[postDir]; found {
+			post.PublishedAt = &published
+			daysToPublish := roundScore(max(published.Sub(post.StartedAt).Hours(), 0) / 24)
+			post.DaysToPublish = &daysToPublish
+			stats.PostsPublished++
+			totalDaysToPublish += daysToPublish
+		}
+		stats.Posts = append(stats.Posts, *post)
+	}
+	// Most recently started first
+	sort.Slice(stats.Posts, func(i, j int) bool {
+		if !stats.Posts[i].StartedAt.Equal(stats.Posts[j].StartedAt) {
+			return stats.Posts[i].StartedAt.After(stats.Posts[j].StartedAt)
+		}
+		return stats.Posts[i].Post < stats.Posts[j].Post
+	})
+	if stats.PostsPublished > 0 {
+		stats.AverageDaysToPublish = roundScore(totalDaysToPublish / float64(stats.PostsPublished))
+	}
+	return stats
+}
+
+// computeStreaks returns the current and longest runs of consecutive active days
+func computeStreaks(activeDates map[string]bool, now time.Time) (int, int) {
+	var dates []string
+	for date := range activeDates {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+
+	longest, run := 0, 0
+	var previous time.Time
+	for idx, date := range dates {
+		day, _ := time.Parse(statsDateFormat, date)
+		if idx > 0 && day.Equal(previous.AddDate(0, 0, 1)) {
+			run++
+		} else {
+			run = 1
+		}
+		longest = max(longest, run)
+		previous = day
+	}
+
+	// A streak that hasn't been added to today yet is still going
+	day := now
+	if !activeDates[day.Format(statsDateFormat)] {
+		day = day.AddDate(0, 0, -1)
+	}
+	current := 0
+	for activeDates[day.Format(statsDateFormat)] {
+		current++
+		day = day.AddDate(0, 0, -1)
+	}
+	return current, longest
+}
+
+// startOfWeek returns midnight on the Monday of the given time's week
+func startOfWeek(t time.Time) time.Time {
+	daysSinceMonday := (int(t.Weekday()) + daysPerWeek - 1) % daysPerWeek
+	return time.Date(t.Year(), t.Month(), t.Day()-daysSinceMonday, 0, 0, 0, 0, t.Location())
+}
+
+func printWritingStats(stats *WritingStats) {
+	if len(stats.Posts) == 0 {
+		fmt.Println("No posts in the writing repo's history")
+		return
+	}
+
+	fmt.Printf("📝 %d words added, %d removed\n", stats.WordsAdded, stats.WordsRemoved)
+	fmt.Printf("📚 %d post(s) started, %d published", stats.PostsStarted, stats.PostsPublished)
+	if stats.PostsPublished > 0 {
+		fmt.Printf(", taking %.1f days on average", stats.AverageDaysToPublish)
+	}
+	fmt.Println()
+	fmt.Printf("🔥 Current streak: %d day(s); longest: %d day(s)\n", stats.CurrentStreak, stats.LongestStreak)
+
+	fmt.Println()
+	fmt.Println("Words written per week")
+	maxWords := 0
+	for _, week := range stats.Weeks {
+		maxWords = max(maxWords, week.WordsAdded)
+	}
+	for _, week := range stats.Weeks {
+		weekOf, _ := time.Parse(statsDateFormat, week.WeekOf)
+		barWidth := 0
+		if maxWords > 0 {
+			barWidth = week.WordsAdded * statsChartWidth / maxWords
+		}
+		// Weeks with any writing get at least a sliver
+		if week.WordsAdded > 0 && barWidth == 0 {
+			barWidth = 1
+		}
+		fmt.Printf("  %s  %-*s %d\n", weekOf.Format("Jan 02"), statsChartWidth, strings.Repeat("█", barWidth), week.WordsAdded)
+	}
+
+	fmt.Println()
+	fmt.Println("Posts")
+	for _, post := range stats.Posts {
+		status := "in progress"
+		if post.PublishedAt != nil {
+			status = fmt.Sprintf("published %s, after %.1f days", post.PublishedAt.Format(statsDateFormat), *post.DaysToPublish)
+		}
+		fmt.Printf("  %-30s started %s, %s; +%d/-%d words over %d day(s)\n", post.Post, post.StartedAt.Format(statsDateFormat), status, post.WordsAdded, post.WordsRemoved, post.ActiveDays)
+	}
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/kurtosis-tech/stacktrace"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// PostTags are a post's tags, written in the front matter as either a YAML list or a comma-separated string. Tags
+// are compared without regard to case.
+type PostTags []string
+
+func (tags *PostTags) UnmarshalYAML(node *yaml.Node) error {
+	var values []string
+	switch node.Kind {
+	case yaml.ScalarNode:
+		values = strings.Split(node.Value, ",")
+	case yaml.SequenceNode:
+		if err := node.Decode(&values); err != nil {
+			return err
+		}
+	default:
+		return stacktrace.NewError("tags must be a list or a comma-separated string")
+	}
+
+	*tags = nil
+	for _, value := range values {
+		if tag := normalizeTag(value); tag != "" && !containsString(*tags, tag) {
+			*tags = append(*tags, tag)
+		}
+	}
+	return nil
+}
+
+// Has returns true if the post has the given tag
+func (tags PostTags) Has(tag string) bool {
+	return containsString(tags, normalizeTag(tag))
+}
+
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+var tagsCmd = &cobra.Command{
+	Use:   "tags",
+	Short: "List the tags used by posts, with how many posts use each",
+	Long: `List every tag in posts' front matter across all branches, most used first, with the number of posts
+that have it. A post with different versions on several branches counts once for each tag any of its
+versions has. Use 'opwriting find --tag <tag>' to pick from the posts with a tag.`,
+	Args: cobra.NoArgs,
+	RunE: runTagsCommand,
+}
+
+func runTagsCommand(cmd *cobra.Command, args []string) error {
+	writingRepoPath := os.Getenv(WritingDirEnvVar)
+	if writingRepoPath == "" {
+		return stacktrace.NewError("writing directory not configured: %s environment variable not set", WritingDirEnvVar)
+	}
+
+	postEntries, err := collectPostEntries(writingRepoPath)
+	if err != nil {
+		return stacktrace.Propagate(err, "failed to collect posts across branches")
+	}
+
+	postsByTag := map[string]map[string]bool{}
+	for _, entry := range postEntries {
+		if entry.Dir == TemplateDirname {
+			continue
+		}
+		frontMatter, err := readPostEntryFrontMatter(writingRepoPath, entry)
+		if err != nil {
+			return err
+		}
+		for _, tag := range frontMatter.Tags {
+			if postsByTag[tag] == nil {
+				postsByTag[tag] = map[string]bool{}
+			}
+			postsByTag[tag][entry.Dir] = true
+		}
+	}
+
+	if len(postsByTag) == 0 {
+		fmt.Println("No posts have tags")
+		return nil
+	}
+	var tags []string
+	for tag := range postsByTag {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if len(postsByTag[tags[i]]) != len(postsByTag[tags[j]]) {
+			return len(postsByTag[tags[i]]) > len(postsByTag[tags[j]])
+		}
+		return tags[i] < tags[j]
+	})
+	for _, tag := range tags {
+		fmt.Printf("%4d  %s\n", len(postsByTag[tag]), tag)
+	}
+	return nil
+}
+
+// readPostEntryFrontMatter parses the front matter of the post's version on the entry's branch
+func readPostEntryFrontMatter(repoPath string, entry PostEntry) (*PostFrontMatter, error) {
+	content, err := readPostFromBranch(repoPath, entry.Branch, entry.Dir)
+	if err != nil {
+		return nil, err
+	}
+	frontMatter, _, err := parsePost(content)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to parse post '%s' on branch '%s'", entry.Dir, entry.Branch)
+	}
+	return frontMatter, nil
+}
+
+// filterEntriesByTags keeps the post versions that have every one of the tags
+func filterEntriesByTags(repoPath string, entries []PostEntry, tags []string) ([]PostEntry, error) {
+	if len(tags) == 0 {
+		return entries, nil
+	}
+
+	var filtered []PostEntry
+	for _, entry := range entries {
+		frontMatter, err := readPostEntryFrontMatter(repoPath, entry)
+		if err != nil {
+			return nil, err
+		}
+		hasAllTags := true
+		for _, tag := range tags {
+			if !frontMatter.Tags.Has(tag) {
+				hasAllTags = false
+				break
+			}
+		}
+		if hasAllTags {
+			filtered = append(filtered, entry)
+		}
+	}
+	return filtered, nil
+}
@@ -0,0 +1,669 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/draw"
+	"math/bits"
+	"sort"
+
+	"github.com/kurtosis-tech/stacktrace"
+)
+
+// golang.org/x/image can only decode WebP, so this is a small lossless (VP8L) encoder. It uses the
+// subtract-green and predictor transforms plus LZ77 and Huffman coding, which is enough to beat PNG on most
+// screenshots. The format is described at https://developers.google.com/speed/webp/docs/webp_lossless_bitstream_specification
+
+const (
+	vp8lSignature    = 0x2f
+	vp8lMaxDimension = 1 << 14
+
+	// Predictor modes are picked per 16x16 tile
+	vp8lPredictorBits = 4
+
+	vp8lNumLiteralCodes  = 256
+	vp8lNumLengthCodes   = 24
+	vp8lNumDistanceCodes = 40
+
+	// Distances up to this are encoded through the 2D neighbourhood table, and everything else is offset by it
+	vp8lNumDistanceMapCodes = 120
+
+	vp8lMinCopyLength   = 3
+	vp8lMaxCopyLength   = 4096
+	vp8lMaxCopyDistance = 1<<20 - vp8lNumDistanceMapCodes
+	vp8lHashBits        = 16
+	vp8lMaxChainLength  = 64
+
+	vp8lMaxCodeLength           = 15
+	vp8lMaxCodeLengthCodeLength = 7
+	vp8lNumCodeLengthCodes      = 19
+)
+
+const (
+	vp8lTransformPredictor     = 0
+	vp8lTransformSubtractGreen = 2
+)
+
+// The order the code length code's lengths are written in
+var vp8lCodeLengthCodeOrder = [vp8lNumCodeLengthCodes]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// Maps the first distance codes to nearby pixels, each as (y offset << 4) | (8 - x offset)
+var vp8lDistanceMapTable = [vp8lNumDistanceMapCodes]uint8{
+	0x18, 0x07, 0x17, 0x19, 0x28, 0x06, 0x27, 0x29, 0x16, 0x1a,
+	0x26, 0x2a, 0x38, 0x05, 0x37, 0x39, 0x15, 0x1b, 0x36, 0x3a,
+	0x25, 0x2b, 0x48, 0x04, 0x47, 0x49, 0x14, 0x1c, 0x35, 0x3b,
+	0x46, 0x4a, 0x24, 0x2c, 0x58, 0x45, 0x4b, 0x34, 0x3c, 0x03,
+	0x57, 0x59, 0x13, 0x1d, 0x56, 0x5a, 0x23, 0x2d, 0x44, 0x4c,
+	0x55, 0x5b, 0x33, 0x3d, 0x68, 0x02, 0x67, 0x69, 0x12, 0x1e,
+	0x66, 0x6a, 0x22, 0x2e, 0x54, 0x5c, 0x43, 0x4d, 0x65, 0x6b,
+	0x32, 0x3e, 0x78, 0x01, 0x77, 0x79, 0x53, 0x5d, 0x11, 0x1f,
+	0x64, 0x6c, 0x42, 0x4e, 0x76, 0x7a, 0x21, 0x2f, 0x75, 0x7b,
+	0x31, 0x3f, 0x63, 0x6d, 0x52, 0x5e, 0x00, 0x74, 0x7c, 0x41,
+	0x4f, 0x10, 0x20, 0x62, 0x6e, 0x30, 0x73, 0x7d, 0x51, 0x5f,
+	0x40, 0x72, 0x7e, 0x61, 0x6f, 0x50, 0x71, 0x7f, 0x60, 0x70,
+}
+
+// encodeLosslessWebP encodes the image as a lossless WebP file
+func encodeLosslessWebP(img image.Image) ([]byte, error) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width < 1 || height < 1 || width > vp8lMaxDimension || height > vp8lMaxDimension {
+		return nil, stacktrace.NewError("can't encode a %dx%d image as WebP; each side must be between 1 and %d pixels", width, height, vp8lMaxDimension)
+	}
+
+	nrgba := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(nrgba, nrgba.Bounds(), img, bounds.Min, draw.Src)
+	pix := nrgba.Pix
+
+	hasAlpha := false
+	for idx := 3; idx < len(pix); idx += 4 {
+		if pix[idx] != 0xff {
+			hasAlpha = true
+			break
+		}
+	}
+
+	writer := &vp8lBitWriter{}
+	writer.writeBits(vp8lSignature, 8)
+	writer.writeBits(uint32(width-1), 14)
+	writer.writeBits(uint32(height-1), 14)
+	writer.writeBool(hasAlpha)
+	writer.writeBits(0, 3)
+
+	// Transforms are written in the order they're applied, and the decoder undoes them in reverse
+	vp8lSubtractGreen(pix)
+	writer.writeBits(1, 1)
+	writer.writeBits(vp8lTransformSubtractGreen, 2)
+
+	modes, tilesPerRow, tilesPerColumn := vp8lChoosePredictorModes(pix, width, height)
+	residuals := vp8lApplyPredictor(pix, width, height, modes, tilesPerRow)
+	writer.writeBits(1, 1)
+	writer.writeBits(vp8lTransformPredictor, 2)
+	writer.writeBits(vp8lPredictorBits-2, 3)
+	modesImage := make([]byte, 4*len(modes))
+	for idx, mode := range modes {
+		modesImage[4*idx+1] = mode
+		modesImage[4*idx+3] = 0xff
+	}
+	vp8lWriteImage(writer, modesImage, tilesPerRow, tilesPerColumn, false)
+	writer.writeBits(0, 1)
+
+	vp8lWriteImage(writer, residuals, width, height, true)
+	data := writer.finish()
+
+	var file bytes.Buffer
+	padding := len(data) % 2
+	file.WriteString("RIFF")
+	binary.Write(&file, binary.LittleEndian, uint32(4+8+len(data)+padding))
+	file.WriteString("WEBPVP8L")
+	binary.Write(&file, binary.LittleEndian, uint32(len(data)))
+	file.Write(data)
+	if padding != 0 {
+		file.WriteByte(0)
+	}
+	return file.Bytes(), nil
+}
+
+// vp8lBitWriter writes values least significant bit first, as VP8L expects
+type vp8lBitWriter struct {
+	buffer      []byte
+	accumulator uint64
+	numBits     uint
+}
+
+func (writer *vp8lBitWriter) writeBits(value uint32, numBits uint) {
+	writer.accumulator |= uint64(value) << writer.numBits
+	writer.numBits += numBits
+	for writer.numBits >= 8 {
+		writer.buffer = append(writer.buffer, byte(writer.accumulator))
+		writer.accumulator >>= 8
+		writer.numBits -= 8
+	}
+}
+
+func (writer *vp8lBitWriter) writeBool(value bool) {
+	if value {
+		writer.writeBits(1, 1)
+	} else {
+		writer.writeBits(0, 1)
+	}
+}
+
+// finish flushes the last partial byte and returns everything written
+func (writer *vp8lBitWriter) finish() []byte {
+	if writer.numBits > 0 {
+		writer.buffer = append(writer.buffer, byte(writer.accumulator))
+		writer.accumulator = 0
+		writer.numBits = 0
+	}
+	return writer.buffer
+}
+
+// vp8lSubtractGreen subtracts each pixel's green from its red and blue, which decorrelates most images
+func vp8lSubtractGreen(pix []byte) {
+	for idx := 0; idx < len(pix); idx += 4 {
+		pix[idx] -= pix[idx+1]
+		pix[idx+2] -= pix[idx+1]
+	}
+}
+
+// vp8lPredict returns the value the predictor mode guesses for the pixel at offset p, whose top neighbour is at
+// offset top. The pixel can't be in the first row or column.
+func vp8lPredict(pix []byte, p int, top int, mode uint8) [4]uint8 {
+	var predicted [4]uint8
+	if mode == 11 {
+		// Select whichever of L or T is closer to the gradient they form with TL
+		leftDistance, topDistance := 0, 0
+		for c := 0; c < 4; c++ {
+			leftDistance += vp8lAbs(int(pix[top-4+c]) - int(pix[top+c]))
+			topDistance += vp8lAbs(int(pix[top-4+c]) - int(pix[p-4+c]))
+		}
+		source := top
+		if leftDistance < topDistance {
+			source = p - 4
+		}
+		copy(predicted[:], pix[source:source+4])
+		return predicted
+	}
+
+	for c := 0; c < 4; c++ {
+		left, topLeft, topValue, topRight := pix[p-4+c], pix[top-4+c], pix[top+c], pix[top+4+c]
+		switch mode {
+		case 0:
+			if c == 3 {
+				predicted[c] = 0xff
+			}
+		case 1:
+			predicted[c] = left
+		case 2:
+			predicted[c] = topValue
+		case 3:
+			predicted[c] = topRight
+		case 4:
+			predicted[c] = topLeft
+		case 5:
+			predicted[c] = vp8lAverage(vp8lAverage(left, topRight), topValue)
+		case 6:
+			predicted[c] = vp8lAverage(left, topLeft)
+		case 7:
+			predicted[c] = vp8lAverage(left, topValue)
+		case 8:
+			predicted[c] = vp8lAverage(topLeft, topValue)
+		case 9:
+			predicted[c] = vp8lAverage(topValue, topRight)
+		case 10:
+			predicted[c] = vp8lAverage(vp8lAverage(left, topLeft), vp8lAverage(topValue, topRight))
+		case 12:
+			predicted[c] = vp8lClamp(int(left) + int(topValue) - int(topLeft))
+		case 13:
+			average := vp8lAverage(left, topValue)
+			predicted[c] = vp8lClamp(int(average) + (int(average)-int(topLeft))/2)
+		}
+	}
+	return predicted
+}
+
+func vp8lAverage(a uint8, b uint8) uint8 {
+	return uint8((int(a) + int(b)) / 2)
+}
+
+func vp8lClamp(value int) uint8 {
+	if value < 0 {
+		return 0
+	}
+	if value > 255 {
+		return 255
+	}
+	return uint8(value)
+}
+
+func vp8lAbs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
+
+// vp8lChoosePredictorModes picks the predictor mode for each tile that leaves the smallest residuals
+func vp8lChoosePredictorModes(pix []byte, width int, height int) ([]uint8, int, int) {
+	tileSize := 1 << vp8lPredictorBits
+	tilesPerRow := (width + tileSize - 1) / tileSize
+	tilesPerColumn := (height + tileSize - 1) / tileSize
+	modes := make([]uint8, tilesPerRow*tilesPerColumn)
+
+	for tileY := 0; tileY < tilesPerColumn; tileY++ {
+		for tileX := 0; tileX < tilesPerRow; tileX++ {
+			bestMode, bestCost := uint8(0), -1
+			for mode := uint8(0); mode < 14; mode++ {
+				cost := 0
+				// The first row and column always use fixed predictors
+				for y := max(tileY*tileSize, 1); y < min((tileY+1)*tileSize, height); y++ {
+					for x := max(tileX*tileSize, 1); x < min((tileX+1)*tileSize, width); x++ {
+						p := 4 * (y*width + x)
+						predicted := vp8lPredict(pix, p, p-4*width, mode)
+						for c := 0; c < 4; c++ {
+							cost += vp8lAbs(int(int8(pix[p+c] - predicted[c])))
+						}
+					}
+				}
+				if bestCost == -1 || cost < bestCost {
+					bestMode, bestCost = mode, cost
+				}
+			}
+			modes[tileY*tilesPerRow+tileX] = bestMode
+		}
+	}
+	return modes, tilesPerRow, tilesPerColumn
+}
+
+// vp8lApplyPredictor returns each pixel's difference from its prediction
+func vp8lApplyPredictor(pix []byte, width int, height int, modes []uint8, tilesPerRow int) []byte {
+	residuals := make([]byte, len(pix))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			p := 4 * (y*width + x)
+			// The first pixel is predicted as opaque black, the rest of the first row from the left and the
+			// first column from the top
+			var predicted [4]uint8
+			switch {
+			case x == 0 && y == 0:
+				predicted[3] = 0xff
+			case y == 0:
+				copy(predicted[:], pix[p-4:p])
+			case x == 0:
+				copy(predicted[:], pix[p-4*width:p-4*width+4])
+			default:
+				mode := modes[(y>>vp8lPredictorBits)*tilesPerRow+(x>>vp8lPredictorBits)]
+				predicted = vp8lPredict(pix, p, p-4*width, mode)
+			}
+			for c := 0; c < 4; c++ {
+				residuals[p+c] = pix[p+c] - predicted[c]
+			}
+		}
+	}
+	return residuals
+}
+
+// vp8lToken is either a literal pixel (length 0) or a backward reference of the given length to a distance code
+type vp8lToken struct {
+	value  uint32
+	length uint16
+}
+
+// vp8lFindCopies turns the pixels into literals and backward references, using hash chains to find matches
+func vp8lFindCopies(pix []byte, width int) []vp8lToken {
+	numPixels := len(pix) / 4
+	pixels := make([]uint32, numPixels)
+	for idx := range pixels {
+		pixels[idx] = binary.LittleEndian.Uint32(pix[4*idx:])
+	}
+
+	distanceCodes := map[int]uint32{}
+	for idx, entry := range vp8lDistanceMapTable {
+		yOffset, xOffset := int(entry>>4), 8-int(entry&0xf)
+		if distance := yOffset*width + xOffset; distance >= 1 {
+			if _, found := distanceCodes[distance]; !found {
+				distanceCodes[distance] = uint32(idx + 1)
+			}
+		}
+	}
+
+	hash := func(idx int) uint32 {
+		value := pixels[idx]*0x9e3779b1 ^ pixels[idx+1]*0x85ebca6b ^ pixels[idx+2]*0xc2b2ae35
+		return value >> (32 - vp8lHashBits)
+	}
+	heads := make([]int32, 1<<vp8lHashBits)
+	for idx := range heads {
+		heads[idx] = -1
+	}
+	previous := make([]int32, numPixels)
+	insert := func(idx int) {
+		if idx+vp8lMinCopyLength <= numPixels {
+			hashValue := hash(idx)
+			previous[idx] = heads[hashValue]
+			heads[hashValue] = int32(idx)
+		}
+	}
+
+	tokens := make([]vp8lToken, 0, numPixels/2)
+	for idx := 0; idx < numPixels; {
+		bestLength, bestDistance := 0, 0
+		if idx+vp8lMinCopyLength <= numPixels {
+			maxLength := min(vp8lMaxCopyLength, numPixels-idx)
+			candidate := heads[hash(idx)]
+			for chain := 0; candidate >= 0 && chain < vp8lMaxChainLength; chain++ {
+				distance := idx - int(candidate)
+				if distance > vp8lMaxCopyDistance {
+					break
+				}
+				if pixels[int(candidate)+bestLength] == pixels[idx+bestLength] {
+					length := 0
+					for length < maxLength && pixels[int(candidate)+length] == pixels[idx+length] {
+						length++
+					}
+					if length > bestLength {
+						bestLength, bestDistance = length, distance
+						if length == maxLength {
+							break
+						}
+					}
+				}
+				candidate = previous[candidate]
+			}
+		}
+
+		if bestLength < vp8lMinCopyLength {
+			tokens = append(tokens, vp8lToken{value: pixels[idx]})
+			insert(idx)
+			idx++
+			continue
+		}
+
+		distanceCode, found := distanceCodes[bestDistance]
+		if !found {
+			distanceCode = uint32(bestDistance + vp8lNumDistanceMapCodes)
+		}
+		tokens = append(tokens, vp8lToken{value: distanceCode, length: uint16(bestLength)})
+		for end := idx + bestLength; idx < end; idx++ {
+			insert(idx)
+		}
+	}
+	return tokens
+}
+
+// vp8lPrefixEncode splits a length or distance code into the prefix symbol and the extra bits that follow it
+func vp8lPrefixEncode(value uint32) (int, uint, uint32) {
+	value--
+	if value < 4 {
+		return int(value), 0, 0
+	}
+	highestBit := bits.Len32(value) - 1
+	secondBit := (value >> (highestBit - 1)) & 1
+	numExtraBits := uint(highestBit - 1)
+	return 2*highestBit + int(secondBit), numExtraBits, value & (1<<numExtraBits - 1)
+}
+
+// vp8lWriteImage entropy-codes the pixels with a single group of prefix codes. Only the main image can have meta
+// prefix codes, so only it writes the bit that says it doesn't.
+func vp8lWriteImage(writer *vp8lBitWriter, pix []byte, width int, height int, isMainImage bool) {
+	// No color cache
+	writer.writeBits(0, 1)
+	if isMainImage {
+		writer.writeBits(0, 1)
+	}
+
+	tokens := vp8lFindCopies(pix, width)
+
+	green := make([]int, vp8lNumLiteralCodes+vp8lNumLengthCodes)
+	red := make([]int, vp8lNumLiteralCodes)
+	blue := make([]int, vp8lNumLiteralCodes)
+	alpha := make([]int, vp8lNumLiteralCodes)
+	distance := make([]int, vp8lNumDistanceCodes)
+	for _, token := range tokens {
+		if token.length == 0 {
+			red[token.value&0xff]++
+			green[(token.value>>8)&0xff]++
+			blue[(token.value>>16)&0xff]++
+			alpha[token.value>>24]++
+			continue
+		}
+		lengthSymbol, _, _ := vp8lPrefixEncode(uint32(token.length))
+		green[vp8lNumLiteralCodes+lengthSymbol]++
+		distanceSymbol, _, _ := vp8lPrefixEncode(token.value)
+		distance[distanceSymbol]++
+	}
+
+	codes := []*vp8lPrefixCode{}
+	for _, histogram := range [][]int{green, red, blue, alpha, distance} {
+		code := newVP8LPrefixCode(histogram, vp8lMaxCodeLength)
+		code.writeDefinition(writer)
+		codes = append(codes, code)
+	}
+	greenCode, redCode, blueCode, alphaCode, distanceCode := codes[0], codes[1], codes[2], codes[3], codes[4]
+
+	for _, token := range tokens {
+		if token.length == 0 {
+			greenCode.writeSymbol(writer, int((token.value>>8)&0xff))
+			redCode.writeSymbol(writer, int(token.value&0xff))
+			blueCode.writeSymbol(writer, int((token.value>>16)&0xff))
+			alphaCode.writeSymbol(writer, int(token.value>>24))
+			continue
+		}
+		lengthSymbol, numExtraBits, extraBits := vp8lPrefixEncode(uint32(token.length))
+		greenCode.writeSymbol(writer, vp8lNumLiteralCodes+lengthSymbol)
+		writer.writeBits(extraBits, numExtraBits)
+		distanceSymbol, numExtraBits, extraBits := vp8lPrefixEncode(token.value)
+		distanceCode.writeSymbol(writer, distanceSymbol)
+		writer.writeBits(extraBits, numExtraBits)
+	}
+}
+
+// vp8lPrefixCode is a canonical Huffman code
+type vp8lPrefixCode struct {
+	lengths []uint8
+
+	// Bit-reversed, since the stream is read least significant bit first
+	codes []uint16
+
+	// A code with a single symbol takes no bits to write
+	isSingleSymbol bool
+}
+
+func newVP8LPrefixCode(histogram []int, maxLength int) *vp8lPrefixCode {
+	lengths := vp8lBuildCodeLengths(histogram, maxLength)
+
+	numSymbols := 0
+	numCodesOfLength := make([]int, maxLength+1)
+	for _, length := range lengths {
+		if length > 0 {
+			numSymbols++
+			numCodesOfLength[length]++
+		}
+	}
+
+	nextCode := make([]int, maxLength+1)
+	code := 0
+	for length := 1; length <= maxLength; length++ {
+		code = (code + numCodesOfLength[length-1]) << 1
+		nextCode[length] = code
+	}
+
+	codes := make([]uint16, len(lengths))
+	for symbol, length := range lengths {
+		if length == 0 {
+			continue
+		}
+		reversed := bits.Reverse16(uint16(nextCode[length])) >> (16 - length)
+		codes[symbol] = reversed
+		nextCode[length]++
+	}
+	return &vp8lPrefixCode{
+		lengths:        lengths,
+		codes:          codes,
+		isSingleSymbol: numSymbols == 1,
+	}
+}
+
+func (code *vp8lPrefixCode) writeSymbol(writer *vp8lBitWriter, symbol int) {
+	if code.isSingleSymbol {
+		return
+	}
+	writer.writeBits(uint32(code.codes[symbol]), uint(code.lengths[symbol]))
+}
+
+// writeDefinition writes the code's lengths, themselves compressed with a code length code
+func (code *vp8lPrefixCode) writeDefinition(writer *vp8lBitWriter) {
+	// Not the simple code length code
+	writer.writeBits(0, 1)
+
+	// Runs of zeros use symbols 17 and 18, and repeats of the previous length use 16
+	type lengthToken struct {
+		symbol       int
+		numExtraBits uint
+		extraBits    uint32
+	}
+	var lengthTokens []lengthToken
+	for idx := 0; idx < len(code.lengths); {
+		length := code.lengths[idx]
+		runLength := 1
+		for idx+runLength < len(code.lengths) && code.lengths[idx+runLength] == length {
+			runLength++
+		}
+		idx += runLength
+
+		if length == 0 {
+			for runLength > 0 {
+				switch {
+				case runLength >= 11:
+					repeat := min(runLength, 138)
+					lengthTokens = append(lengthTokens, lengthToken{symbol: 18, numExtraBits: 7, extraBits: uint32(repeat - 11)})
+					runLength -= repeat
+				case runLength >= 3:
+					lengthTokens = append(lengthTokens, lengthToken{symbol: 17, numExtraBits: 3, extraBits: uint32(runLength - 3)})
+					runLength = 0
+				default:
+					lengthTokens = append(lengthTokens, lengthToken{symbol: 0})
+					runLength--
+				}
+			}
+			continue
+		}
+
+		lengthTokens = append(lengthTokens, lengthToken{symbol: int(length)})
+		runLength--
+		for runLength > 0 {
+			if runLength < 3 {
+				lengthTokens = append(lengthTokens, lengthToken{symbol: int(length)})
+				runLength--
+				continue
+			}
+			repeat := min(runLength, 6)
+			lengthTokens = append(lengthTokens, lengthToken{symbol: 16, numExtraBits: 2, extraBits: uint32(repeat - 3)})
+			runLength -= repeat
+		}
+	}
+
+	histogram := make([]int, vp8lNumCodeLengthCodes)
+	for _, token := range lengthTokens {
+		histogram[token.symbol]++
+	}
+	lengthCode := newVP8LPrefixCode(histogram, vp8lMaxCodeLengthCodeLength)
+
+	numLengthCodes := 4
+	for idx, symbol := range vp8lCodeLengthCodeOrder {
+		if lengthCode.lengths[symbol] > 0 {
+			numLengthCodes = max(numLengthCodes, idx+1)
+		}
+	}
+	writer.writeBits(uint32(numLengthCodes-4), 4)
+	for _, symbol := range vp8lCodeLengthCodeOrder[:numLengthCodes] {
+		writer.writeBits(uint32(lengthCode.lengths[symbol]), 3)
+	}
+
+	// Every symbol's length is written, rather than stopping early
+	writer.writeBits(0, 1)
+	for _, token := range lengthTokens {
+		lengthCode.writeSymbol(writer, token.symbol)
+		writer.writeBits(token.extraBits, token.numExtraBits)
+	}
+}
+
+// vp8lBuildCodeLengths builds Huffman code lengths for the symbol frequencies, flattening the frequencies until no
+// code is longer than the maximum. There's always at least one symbol, since the decoder rejects empty codes.
+func vp8lBuildCodeLengths(histogram []int, maxLength int) []uint8 {
+	lengths := make([]uint8, len(histogram))
+
+	type node struct {
+		frequency int
+		symbol    int
+		children  [2]int
+	}
+	frequencies := append([]int{}, histogram...)
+	for {
+		var nodes []node
+		for symbol, frequency := range frequencies {
+			if frequency > 0 {
+				nodes = append(nodes, node{frequency: frequency, symbol: symbol, children: [2]int{-1, -1}})
+			}
+		}
+		switch len(nodes) {
+		case 0:
+			lengths[0] = 1
+			return lengths
+		case 1:
+			lengths[nodes[0].symbol] = 1
+			return lengths
+		}
+		sort.SliceStable(nodes, func(i, j int) bool {
+			return nodes[i].frequency < nodes[j].frequency
+		})
+
+		// Two queues: the sorted leaves, and the merged nodes, which are created in increasing frequency order
+		numLeaves := len(nodes)
+		leafIdx, mergedIdx := 0, numLeaves
+		takeSmallest := func() int {
+			if leafIdx < numLeaves && (mergedIdx >= len(nodes) || nodes[leafIdx].frequency <= nodes[mergedIdx].frequency) {
+				leafIdx++
+				return leafIdx - 1
+			}
+			mergedIdx++
+			return mergedIdx - 1
+		}
+		for len(nodes) < 2*numLeaves-1 {
+			first := takeSmallest()
+			second := takeSmallest()
+			nodes = append(nodes, node{
+				frequency: nodes[first].frequency + nodes[second].frequency,
+				symbol:    -1,
+				children:  [2]int{first, second},
+			})
+		}
+
+		depths := make([]int, len(nodes))
+		tooLong := false
+		for idx := len(nodes) - 1; idx >= 0; idx-- {
+			if nodes[idx].symbol >= 0 {
+				if depths[idx] > maxLength {
+					tooLong = true
+				}
+				continue
+			}
+			for _, child := range nodes[idx].children {
+				depths[child] = depths[idx] + 1
+			}
+		}
+		if !tooLong {
+			for idx := 0; idx < numLeaves; idx++ {
+				lengths[nodes[idx].symbol] = uint8(depths[idx])
+			}
+			return lengths
+		}
+
+		for symbol, frequency := range frequencies {
+			if frequency > 0 {
+				frequencies[symbol] = (frequency + 1) / 2
+			}
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/kurtosis-tech/stacktrace"
+	"github.com/spf13/cobra"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// WikiLink is a '[[post]]' or '[[post|text]]' link from one post to another, by the target's directory
+type WikiLink struct {
+	Target string
+
+	// Empty if the link should use the target's title
+	Text string
+
+	// Byte offsets of the whole '[[...]]' in the Markdown it was found in
+	Start int
+	End   int
+}
+
+var wikiLinkRegex = regexp.MustCompile(`\[\[([^\[\]|\n]+)(?:\|([^\[\]\n]+))?\]\]`)
+
+var backlinksCmd = &cobra.Command{
+	Use:   "backlinks <post_dir>",
+	Short: "List the posts that link to a post",
+	Long: `List every post that links to the given one (a directory relative to the writing repo) with a
+'[[post_dir]]' (or '[[post_dir|link text]]') wiki link, across main and the branches not yet merged into
+it, with the lines the links are on. When a post is rendered, its wiki links become links to the published
+URLs of the posts they point to: the 'url' in the target's front matter if it has one, else
+` + SubstackURLEnvVar + `/p/<the target's directory name>. Linking to a post that isn't on main yet is an
+error, both when rendering and in 'opwriting lint'.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runBacklinksCommand,
+}
+
+func runBacklinksCommand(cmd *cobra.Command, args []string) error {
+	writingRepoPath := os.Getenv(WritingDirEnvVar)
+	if writingRepoPath == "" {
+		return stacktrace.NewError("writing directory not configured: %s environment variable not set", WritingDirEnvVar)
+	}
+	// The post is named the way wiki links name it, relative to the repo rather than the current directory
+	target := normalizeWikiLinkTarget(args[0])
+
+	postEntries, err := collectPostEntries(writingRepoPath)
+	if err != nil {
+		return stacktrace.Propagate(err, "failed to collect posts across branches")
+	}
+	versionCounts := map[string]int{}
+	targetExists := false
+	for _, entry := range postEntries {
+		versionCounts[entry.Dir]++
+		if entry.Dir == target {
+			targetExists = true
+		}
+	}
+	if !targetExists {
+		return stacktrace.NewError("no post '%s' found on any branch", target)
+	}
+
+	var backlinks []string
+	for _, entry := range postEntries {
+		if entry.Dir == TemplateDirname || entry.Dir == target {
+			continue
+		}
+		content, err := readPostFromBranch(writingRepoPath, entry.Branch, entry.Dir)
+		if err != nil {
+			return err
+		}
+		content = strings.ReplaceAll(content, "\r\n", "\n")
+		_, body, err := parsePost(content)
+		if err != nil {
+			return stacktrace.Propagate(err, "failed to parse post '%s' on branch '%s'", entry.Dir, entry.Branch)
+		}
+		bodyLineOffset := strings.Count(content[:len(content)-len(body)], "\n")
+
+		var lineNumbers []string
+		for _, link := range findWikiLinks(body) {
+			if link.Target == target {
+				line := bodyLineOffset + lineOfOffset([]byte(body), link.Start)
+				lineNumbers = append(lineNumbers, fmt.Sprint(line))
+			}
+		}
+		if len(lineNumbers) > 0 {
+			backlinks = append(backlinks, fmt.Sprintf("%s (line %s)", formatPostEntry(entry, versionCounts[entry.Dir]), strings.Join(lineNumbers, ", ")))
+		}
+	}
+
+	if len(backlinks) == 0 {
+		fmt.Printf("No posts link to %s\n", target)
+		return nil
+	}
+	fmt.Printf("🔗 %d post(s) link to %s:\n", len(backlinks), target)
+	for _, backlink := range backlinks {
+		fmt.Printf("  %s\n", backlink)
+	}
+	return nil
+}
+
+func normalizeWikiLinkTarget(target string) string {
+	return path.Clean(strings.Trim(strings.TrimSpace(target), "/"))
+}
+
+// findWikiLinks returns the wiki links in a post's body, in order, leaving out anything in code or HTML
+func findWikiLinks(body string) []WikiLink {
+	source := []byte(body)
+	document := lintMarkdownParser.Parse(text.NewReader(source))
+
+	var excludedSpans []proseSpan
+	ast.Walk(document, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node.(type) {
+		case *ast.FencedCodeBlock, *ast.CodeBlock, *ast.HTMLBlock:
+			for idx := 0; idx < node.Lines().Len(); idx++ {
+				segment := node.Lines().At(idx)
+				excludedSpans = append(excludedSpans, proseSpan{start: segment.Start, end: segment.Stop})
+			}
+			return ast.WalkSkipChildren, nil
+		case *ast.CodeSpan:
+			for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+				if textNode, ok := child.(*ast.Text); ok {
+					excludedSpans = append(excludedSpans, proseSpan{start: textNode.Segment.Start, end: textNode.Segment.Stop})
+				}
+			}
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+
+	var links []WikiLink
+	for _, match := range wikiLinkRegex.FindAllStringSubmatchIndex(body, -1) {
+		excluded := false
+		for _, span := range excludedSpans {
+			if match[0] < span.end && match[1] > span.start {
+				excluded = true
+				break
+			}
+		}
+		if excluded {
+			continue
+		}
+		link := WikiLink{
+			Target: normalizeWikiLinkTarget(body[match[2]:match[3]]),
+			Start:  match[0],
+			End:    match[1],
+		}
+		if match[4] != -1 {
+			link.Text = strings.TrimSpace(body[match[4]:match[5]])
+		}
+		links = append(links, link)
+	}
+	return links
+}
+
+// resolveWikiLinks replaces the wiki links in a post's body with Markdown links to the published posts they point to,
+// failing if any of them haven't been published
+func resolveWikiLinks(repoPath string, body string) (string, error) {
+	links := findWikiLinks(body)
+	if len(links) == 0 {
+		return body, nil
+	}
+
+	var resolved strings.Builder
+	previousEnd := 0
+	for _, link := range links {
+		url, title, err := getPublishedPostURL(repoPath, link.Target)
+		if err != nil {
+			return "", stacktrace.Propagate(err, "failed to resolve link '%s'", body[link.Start:link.End])
+		}
+		linkText := link.Text
+		if linkText == "" {
+			linkText = title
+		}
+		linkText = strings.NewReplacer("[", `\[`, "]", `\]`).Replace(linkText)
+
+		resolved.WriteString(body[previousEnd:link.Start])
+		fmt.Fprintf(&resolved, "[%s](<%s>)", linkText, url)
+		previousEnd = link.End
+	}
+	resolved.WriteString(body[previousEnd:])
+	return resolved.String(), nil
+}
+
+// getPublishedPostURL returns the URL and title of a post that's been published, i.e. merged into main
+func getPublishedPostURL(repoPath string, postDir string) (string, string, error) {
+	if err := checkPostPublished(repoPath, postDir); err != nil {
+		return "", "", err
+	}
+	content, err := readPostFromBranch(repoPath, MainBranchName, postDir)
+	if err != nil {
+		return "", "", err
+	}
+	frontMatter, _, err := parsePost(content)
+	if err != nil {
+		return "", "", stacktrace.Propagate(err, "failed to parse post '%s' on %s", postDir, MainBranchName)
+	}
+
+	title := frontMatter.Title
+	if title == "" {
+		title = path.Base(postDir)
+	}
+	if frontMatter.URL != "" {
+		return frontMatter.URL, title, nil
+	}
+	baseURL := strings.TrimSuffix(getSubstackBaseURL(), "/")
+	if baseURL == "" {
+		return "", "", stacktrace.NewError("can't tell where '%s' was published; set 'url' in its front matter, or %s in %s", postDir, SubstackURLEnvVar, EnvFilename)
+	}
+	return baseURL + "/p/" + path.Base(postDir), title, nil
+}
+
+// checkPostPublished returns an error if the post isn't on main
+func checkPostPublished(repoPath string, postDir string) error {
+	checkPostCmd := exec.Command("git", "-C", repoPath, "cat-file", "-e", fmt.Sprintf("%s:%s/%s", MainBranchName, postDir, PostFilename))
+	if err := checkPostCmd.Run(); err != nil {
+		return stacktrace.NewError("'%s' hasn't been published; no post found on %s at: %s", postDir, MainBranchName, postDir)
+	}
+	return nil
+}
@@ -1,11 +1,14 @@
 module github.com/odyssey/opwrite
 
-go 1.23
+go 1.23.0
 
 require (
 	github.com/joho/godotenv v1.5.1
 	github.com/kurtosis-tech/stacktrace v0.0.0-20211028211901-1c67a77b5409
 	github.com/spf13/cobra v1.8.1
+	github.com/yuin/goldmark v1.8.6
+	golang.org/x/image v0.25.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
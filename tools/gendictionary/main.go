@@ -0,0 +1,720 @@
+// Command gendictionary generates cmd/en_US.dic, the English dictionary that 'opwriting spell' uses by
+// default. Run it with 'go generate ./cmd' from the root of the repo; the checked-in dictionary was
+// generated with Go 1.27.1, and other versions of Go give slightly different word counts.
+//
+// The dictionary is built from:
+//
+//   - The hand-written lists in words/, which belong to this repo
+//   - Words that are used often in the comments of the Go source tree, and in the copy of Newton's
+//     Opticks in its testdata, found with 'go env GOROOT' (BSD-3-Clause; see licenses/go.txt)
+//   - The correctly spelled words from the lists of common misspellings in github.com/golangci/misspell,
+//     which started from Wikipedia's list of common misspellings (MIT; see licenses/misspell.txt)
+//   - The word list, first names and place names in github.com/brianvoe/gofakeit (MIT; see
+//     licenses/gofakeit.txt)
+//   - The list of English words in github.com/ccojocar/zxcvbn-go, which is Wiktionary's frequency list of
+//     the words used in American TV and films (MIT; see licenses/zxcvbn.txt)
+//
+// The modules are fetched with 'go mod download'. The affix flags given to words must stay in sync with
+// the suffix rules in cmd/en_US.aff.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/kurtosis-tech/stacktrace"
+)
+
+const (
+	misspellModule = "github.com/golangci/misspell@v0.8.0"
+	gofakeitModule = "github.com/brianvoe/gofakeit/v6@v6.28.0"
+	zxcvbnModule   = "github.com/ccojocar/zxcvbn-go@v1.0.4"
+
+	opticksRelFilepath = "src/testdata/Isaac.Newton-Opticks.txt"
+
+	// Sources that words are counted in, so that words that only one of them uses can be held to a higher bar
+	goCommentsSource = "go"
+	opticksSource    = "opticks"
+
+	// A lowercase word in the corpus makes it into the dictionary if this many files use it...
+	minFileCount = 12
+	// ...or this many do, and it's in both sources
+	minFileCountInBothSources = 3
+
+	// Words further down zxcvbn's list than this are only taken if another source has them too, since the
+	// tail of the list is mostly names and slang from the subtitles it was built from
+	maxUnconfirmedZxcvbnRank = 20000
+
+	// Shorter words than this don't get affix flags
+	minFlaggedWordLength = 4
+)
+
+var (
+	wordRegex = regexp.MustCompile(`[A-Za-z]+(?:'[A-Za-z]+)*`)
+
+	lowercaseRegex = regexp.MustCompile(`^[a-z]+$`)
+
+	// A gofakeit data entry, e.g. '"first": {"Aaliyah", "Aaron", ...},'
+	gofakeitEntryRegex = regexp.MustCompile(`^\s*"(\w+)":\s*\{(.*)\}`)
+	quotedStringRegex  = regexp.MustCompile(`"([^"]+)"`)
+	nameRegex          = regexp.MustCompile(`^[A-Z][a-z]{2,}$`)
+	misspellWordRegex  = regexp.MustCompile(`^[a-z]+(?:'[a-z]+)?$`)
+	gofakeitWordRegex  = regexp.MustCompile(`"([a-z]+)"`)
+
+	alphaRegex          = regexp.MustCompile(`^[A-Za-z]+$`)
+	vowelGroupRegex     = regexp.MustCompile(`[aeiouy]+`)
+	sibilantEndRegex    = regexp.MustCompile(`(s|x|z|ch|sh)$`)
+	consonantLeEndRegex = regexp.MustCompile(`[^aeiou]le$`)
+
+	// Verbs whose final consonant is doubled before -ed and -ing, even though they have more than one syllable
+	doublingVerbs = map[string]bool{}
+)
+
+func init() {
+	for _, verb := range strings.Fields("admit commit occur refer prefer regret control permit omit compel patrol equip propel transmit submit confer defer deter infer incur recur rebel repel excel expel upset forbid outwit acquit abet allot befit embed program babysit debug overlap outbid outfit overrun overstep eavesdrop bootleg lollygag recap sidestep sublet underpin unplug unwrap zigzag dispel extol corral offset outstrip transfer") {
+		doublingVerbs[verb] = true
+	}
+}
+
+// wordCounts records how many files use each word, and which sources they're from
+type wordCounts struct {
+	fileCounts map[string]int
+	sources    map[string]map[string]bool
+}
+
+func main() {
+	wordsDirpath := flag.String("words", "", "Directory with the hand-written word lists")
+	outputFilepath := flag.String("out", "", "Path to write the .dic file to")
+	flag.Parse()
+	if *wordsDirpath == "" || *outputFilepath == "" {
+		log.Fatal("both -words and -out are required")
+	}
+
+	if err := generateDictionary(*wordsDirpath, *outputFilepath); err != nil {
+		log.Fatalf("failed to generate dictionary: %v", err)
+	}
+}
+
+func generateDictionary(wordsDirpath string, outputFilepath string) error {
+	goRootDirpath, err := getGoRootDirpath()
+	if err != nil {
+		return err
+	}
+	counts, err := countGoSourceWords(goRootDirpath)
+	if err != nil {
+		return stacktrace.Propagate(err, "failed to count the words in the Go source tree")
+	}
+
+	misspellWords, misspellings, err := getMisspellWords()
+	if err != nil {
+		return stacktrace.Propagate(err, "failed to get words from misspell")
+	}
+	gofakeitWords, names, err := getGofakeitWords()
+	if err != nil {
+		return stacktrace.Propagate(err, "failed to get words from gofakeit")
+	}
+	zxcvbnWords, err := getZxcvbnWords()
+	if err != nil {
+		return stacktrace.Propagate(err, "failed to get words from zxcvbn")
+	}
+	listWords, err := readWordLists(wordsDirpath)
+	if err != nil {
+		return stacktrace.Propagate(err, "failed to read the word lists in: %s", wordsDirpath)
+	}
+	otherWords, err := readWordList(filepath.Join(wordsDirpath, "other.txt"))
+	if err != nil {
+		return err
+	}
+	notWords, err := readWordList(filepath.Join(wordsDirpath, "not-words.txt"))
+	if err != nil {
+		return err
+	}
+	for _, word := range misspellings {
+		if !misspellWords[word] {
+			notWords = append(notWords, word)
+		}
+	}
+
+	words := map[string]bool{}
+	for _, source := range []map[string]bool{misspellWords, gofakeitWords, listWords} {
+		for word := range source {
+			words[word] = true
+		}
+	}
+	for word, fileCount := range counts.fileCounts {
+		if isCommonLowercaseWord(word, fileCount, len(counts.sources[word])) {
+			words[word] = true
+		}
+	}
+	zxcvbnRanks := map[string]int{}
+	for rank, word := range zxcvbnWords {
+		zxcvbnRanks[word] = rank
+	}
+	var confirmedZxcvbnWords []string
+	for rank, word := range zxcvbnWords {
+		// Subtitles spell out drawn-out words and dropped g's, e.g. 'nooooo' and 'nothin'
+		if !lowercaseRegex.MatchString(word) || hasTripledLetter(word) {
+			continue
+		}
+		if _, found := zxcvbnRanks[word+"g"]; found && strings.HasSuffix(word, "in") {
+			continue
+		}
+
+		switch {
+		// Short words in subtitles are often bits of slang, like 'gon' from 'gonna', which would hide typos
+		case len(word) <= 3:
+			if words[word] {
+				confirmedZxcvbnWords = append(confirmedZxcvbnWords, word)
+			}
+		case rank < maxUnconfirmedZxcvbnRank || words[word] || counts.fileCounts[word] > 0:
+			confirmedZxcvbnWords = append(confirmedZxcvbnWords, word)
+		}
+	}
+	for _, word := range confirmedZxcvbnWords {
+		words[word] = true
+	}
+
+	for name := range names {
+		if !words[strings.ToLower(name)] {
+			words[name] = true
+		}
+	}
+	for _, word := range notWords {
+		delete(words, word)
+		delete(words, capitalize(word))
+	}
+	// The hand-written list of words with no other forms has the last word
+	for _, word := range otherWords {
+		words[word] = true
+	}
+
+	return writeDictionary(outputFilepath, words, zxcvbnRanks, counts.fileCounts)
+}
+
+// isCommonLowercaseWord returns true if a word from the corpus is used often enough to be a real word
+// rather than an identifier, abbreviation or typo
+func isCommonLowercaseWord(word string, fileCount int, sourceCount int) bool {
+	if word != strings.ToLower(word) || strings.Contains(word, "'") {
+		return false
+	}
+	// Short tokens in comments are mostly identifiers and abbreviations, which would hide typos
+	if len(word) <= 3 {
+		return false
+	}
+	return fileCount >= minFileCount || (fileCount >= minFileCountInBothSources && sourceCount >= 2)
+}
+
+// writeDictionary writes the words in Hunspell's format, most common first so that suggestions can prefer
+// them, folding words' -s, -ed and -ing forms into affix flags where they're regular. zxcvbn's ranking is
+// used where it has the word, since it reflects everyday English better than the Go source tree does.
+func writeDictionary(outputFilepath string, words map[string]bool, zxcvbnRanks map[string]int, fileCounts map[string]int) error {
+	flags := map[string]string{}
+	derived := map[string]bool{}
+	for word := range words {
+		if len(word) < minFlaggedWordLength || word != strings.ToLower(word) || !isAlpha(word) {
+			continue
+		}
+		forms := getSuffixForms(word)
+		wordFlags := map[string]bool{}
+		for suffixFlag, form := range forms {
+			if words[form] {
+				wordFlags[suffixFlag] = true
+			}
+		}
+		// Anything with regular -ed and -ing forms is a verb, so it gets -s too
+		if wordFlags["D"] && wordFlags["G"] {
+			wordFlags["S"] = true
+		}
+		if len(wordFlags) == 0 {
+			continue
+		}
+
+		var flagChars []string
+		for suffixFlag := range wordFlags {
+			flagChars = append(flagChars, suffixFlag)
+			derived[forms[suffixFlag]] = true
+		}
+		sort.Strings(flagChars)
+		flags[word] = strings.Join(flagChars, "")
+	}
+
+	var entries []string
+	for word := range words {
+		if _, isFlagged := flags[word]; !derived[word] || isFlagged {
+			entries = append(entries, word)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		rankI, isRankedI := zxcvbnRanks[entries[i]]
+		rankJ, isRankedJ := zxcvbnRanks[entries[j]]
+		if isRankedI && isRankedJ {
+			return rankI < rankJ
+		}
+		if isRankedI != isRankedJ {
+			return isRankedI
+		}
+		if fileCounts[entries[i]] != fileCounts[entries[j]] {
+			return fileCounts[entries[i]] > fileCounts[entries[j]]
+		}
+		return entries[i] < entries[j]
+	})
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "%d\n", len(entries))
+	for _, word := range entries {
+		builder.WriteString(word)
+		if wordFlags, found := flags[word]; found {
+			builder.WriteString("/" + wordFlags)
+		}
+		builder.WriteString("\n")
+	}
+	if err := os.WriteFile(outputFilepath, []byte(builder.String()), 0644); err != nil {
+		return stacktrace.Propagate(err, "failed to write dictionary: %s", outputFilepath)
+	}
+	fmt.Printf("Wrote %d entries (%d words) to %s\n", len(entries), len(words), outputFilepath)
+	return nil
+}
+
+// getSuffixForms returns the word with each of the suffixes in en_US.aff applied, keyed by flag
+func getSuffixForms(word string) map[string]string {
+	forms := map[string]string{}
+	switch {
+	case endsInConsonantY(word):
+		forms["S"] = word[:len(word)-1] + "ies"
+	case strings.ContainsAny(word[len(word)-1:], "sxzh"):
+		forms["S"] = word + "es"
+	default:
+		forms["S"] = word + "s"
+	}
+
+	switch {
+	case strings.HasSuffix(word, "e"):
+		forms["D"] = word + "d"
+	case endsInConsonantY(word):
+		forms["D"] = word[:len(word)-1] + "ied"
+	default:
+		forms["D"] = word + "ed"
+	}
+
+	if strings.HasSuffix(word, "e") && !strings.HasSuffix(word, "ee") {
+		forms["G"] = word[:len(word)-1] + "ing"
+	} else {
+		forms["G"] = word + "ing"
+	}
+	return forms
+}
+
+// countGoSourceWords counts how many files use each word, in the comments of the Go source tree and in the
+// copy of Opticks in its testdata
+func countGoSourceWords(goRootDirpath string) (*wordCounts, error) {
+	counts := &wordCounts{fileCounts: map[string]int{}, sources: map[string]map[string]bool{}}
+
+	srcDirpath := filepath.Join(goRootDirpath, "src")
+	err := filepath.WalkDir(srcDirpath, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if entry.Name() == "testdata" || entry.Name() == "vendor" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(path) != ".go" {
+			return nil
+		}
+
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return stacktrace.Propagate(err, "failed to read: %s", path)
+		}
+		counts.add(goCommentsSource, getLineComments(string(contents)))
+		return nil
+	})
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to walk the Go source tree: %s", srcDirpath)
+	}
+
+	opticksFilepath := filepath.Join(goRootDirpath, opticksRelFilepath)
+	opticks, err := os.ReadFile(opticksFilepath)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to read: %s", opticksFilepath)
+	}
+	counts.add(opticksSource, string(opticks))
+	return counts, nil
+}
+
+// getLineComments returns the prose in a Go file's line comments, leaving out code examples in doc comments
+func getLineComments(contents string) string {
+	var comments []string
+	for _, line := range strings.Split(contents, "\n") {
+		commentStart := strings.Index(line, "//")
+		// The '//' in a string like "https://" doesn't start a comment
+		if commentStart < 0 || strings.ContainsAny(line[:commentStart], "\"`") {
+			continue
+		}
+		comment := line[commentStart+2:]
+		// Code in doc comments is indented with a tab
+		if strings.Contains(comment[:min(2, len(comment))], "\t") {
+			continue
+		}
+		comments = append(comments, comment)
+	}
+	return strings.Join(comments, "\n")
+}
+
+func (counts *wordCounts) add(source string, text string) {
+	text = strings.ReplaceAll(text, "’", "'")
+	seen := map[string]bool{}
+	for _, word := range wordRegex.FindAllString(text, -1) {
+		if seen[word] {
+			continue
+		}
+		seen[word] = true
+		counts.fileCounts[word]++
+		if counts.sources[word] == nil {
+			counts.sources[word] = map[string]bool{}
+		}
+		counts.sources[word][source] = true
+	}
+}
+
+// getMisspellWords returns the correctly spelled words in misspell's lists, along with every misspelling
+// of them
+func getMisspellWords() (map[string]bool, []string, error) {
+	moduleDirpath, err := downloadModule(misspellModule)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	words := map[string]bool{}
+	var misspellings []string
+	for _, listFilename := range []string{"main.json", "us.json"} {
+		listFilepath := filepath.Join(moduleDirpath, "internal", "gen", "sources", listFilename)
+		listBytes, err := os.ReadFile(listFilepath)
+		if err != nil {
+			return nil, nil, stacktrace.Propagate(err, "failed to read: %s", listFilepath)
+		}
+		// Each correct spelling maps to the ways it gets misspelled
+		var list map[string][]string
+		if err := json.Unmarshal(listBytes, &list); err != nil {
+			return nil, nil, stacktrace.Propagate(err, "failed to parse: %s", listFilepath)
+		}
+		for word, wordMisspellings := range list {
+			if misspellWordRegex.MatchString(word) {
+				words[word] = true
+			}
+			misspellings = append(misspellings, wordMisspellings...)
+		}
+	}
+	return words, misspellings, nil
+}
+
+// getGofakeitWords returns gofakeit's list of common words, along with its first names and place names. Its
+// last names are left out, since plenty of them are made up.
+func getGofakeitWords() (map[string]bool, map[string]bool, error) {
+	moduleDirpath, err := downloadModule(gofakeitModule)
+	if err != nil {
+		return nil, nil, err
+	}
+	dataDirpath := filepath.Join(moduleDirpath, "data")
+
+	wordFilepath := filepath.Join(dataDirpath, "word.go")
+	wordBytes, err := os.ReadFile(wordFilepath)
+	if err != nil {
+		return nil, nil, stacktrace.Propagate(err, "failed to read: %s", wordFilepath)
+	}
+	wordData := string(wordBytes)
+	wordListStart := strings.Index(wordData, "var Word")
+	if wordListStart < 0 {
+		return nil, nil, stacktrace.NewError("couldn't find the word list in: %s", wordFilepath)
+	}
+	words := map[string]bool{}
+	for _, match := range gofakeitWordRegex.FindAllStringSubmatch(wordData[wordListStart:], -1) {
+		words[match[1]] = true
+	}
+
+	names := map[string]bool{}
+	for filename, keys := range map[string][]string{"person.go": {"first"}, "address.go": {"country", "state", "city"}} {
+		dataFilepath := filepath.Join(dataDirpath, filename)
+		dataBytes, err := os.ReadFile(dataFilepath)
+		if err != nil {
+			return nil, nil, stacktrace.Propagate(err, "failed to read: %s", dataFilepath)
+		}
+		for _, line := range strings.Split(string(dataBytes), "\n") {
+			match := gofakeitEntryRegex.FindStringSubmatch(line)
+			if match == nil || !contains(keys, match[1]) {
+				continue
+			}
+			for _, value := range quotedStringRegex.FindAllStringSubmatch(match[2], -1) {
+				// Multi-word places, like 'New York', count as separate words
+				for _, name := range strings.Fields(value[1]) {
+					if nameRegex.MatchString(name) {
+						names[name] = true
+					}
+				}
+			}
+		}
+	}
+	return words, names, nil
+}
+
+// getZxcvbnWords returns zxcvbn's list of English words, most common first, which is Wiktionary's frequency
+// list of the words used in American TV and films
+func getZxcvbnWords() ([]string, error) {
+	moduleDirpath, err := downloadModule(zxcvbnModule)
+	if err != nil {
+		return nil, err
+	}
+
+	listFilepath := filepath.Join(moduleDirpath, "data", "data", "English.json")
+	listBytes, err := os.ReadFile(listFilepath)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to read: %s", listFilepath)
+	}
+	var list struct {
+		List []string
+	}
+	if err := json.Unmarshal(listBytes, &list); err != nil {
+		return nil, stacktrace.Propagate(err, "failed to parse: %s", listFilepath)
+	}
+	return list.List, nil
+}
+
+// readWordLists reads the hand-written lists of nouns, verbs, adjectives and adverbs, adding each word's
+// regular forms
+func readWordLists(wordsDirpath string) (map[string]bool, error) {
+	words := map[string]bool{}
+	addForms := map[string]func(word string) []string{
+		"nouns.txt":           func(word string) []string { return []string{getPlural(word)} },
+		"verbs.txt":           func(word string) []string { return getVerbForms(word, true) },
+		"irregular-verbs.txt": func(word string) []string { return getVerbForms(word, false) },
+		"adjectives.txt":      getAdjectiveForms,
+		"adverbs.txt":         func(word string) []string { return nil },
+	}
+	for listFilename, getForms := range addForms {
+		listWords, err := readWordList(filepath.Join(wordsDirpath, listFilename))
+		if err != nil {
+			return nil, err
+		}
+		for _, word := range listWords {
+			words[word] = true
+			for _, form := range getForms(word) {
+				words[form] = true
+			}
+		}
+	}
+	return words, nil
+}
+
+// readWordList reads a list of whitespace-separated words, skipping '#' comment lines
+func readWordList(listFilepath string) ([]string, error) {
+	listBytes, err := os.ReadFile(listFilepath)
+	if err != nil {
+		return nil, stacktrace.Propagate(err, "failed to read word list: %s", listFilepath)
+	}
+
+	var words []string
+	for _, line := range strings.Split(string(listBytes), "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, strings.Fields(line)...)
+	}
+	return words, nil
+}
+
+func getPlural(noun string) string {
+	switch {
+	case sibilantEndRegex.MatchString(noun):
+		return noun + "es"
+	case endsInConsonantY(noun):
+		return noun[:len(noun)-1] + "ies"
+	default:
+		return noun + "s"
+	}
+}
+
+// getVerbForms returns the verb's -s and -ing forms, and its -ed form if it's regular
+func getVerbForms(verb string, isRegular bool) []string {
+	forms := []string{getPlural(verb)}
+	switch {
+	case strings.HasSuffix(verb, "ie"):
+		forms = append(forms, verb[:len(verb)-2]+"ying")
+	case strings.HasSuffix(verb, "ee") || strings.HasSuffix(verb, "oe") || strings.HasSuffix(verb, "ye"):
+		forms = append(forms, verb+"ing")
+	case strings.HasSuffix(verb, "e"):
+		forms = append(forms, verb[:len(verb)-1]+"ing")
+	case strings.HasSuffix(verb, "ic"):
+		forms = append(forms, verb+"king")
+	case doublesFinalConsonant(verb):
+		forms = append(forms, verb+verb[len(verb)-1:]+"ing")
+	default:
+		forms = append(forms, verb+"ing")
+	}
+	if !isRegular {
+		return forms
+	}
+
+	switch {
+	case strings.HasSuffix(verb, "e"):
+		forms = append(forms, verb+"d")
+	case endsInConsonantY(verb):
+		forms = append(forms, verb[:len(verb)-1]+"ied")
+	case strings.HasSuffix(verb, "ic"):
+		forms = append(forms, verb+"ked")
+	case doublesFinalConsonant(verb):
+		forms = append(forms, verb+verb[len(verb)-1:]+"ed")
+	default:
+		forms = append(forms, verb+"ed")
+	}
+	return forms
+}
+
+// getAdjectiveForms returns the adjective's -ly and -ness forms, and its comparative and superlative if
+// it's short enough to have them
+func getAdjectiveForms(adjective string) []string {
+	var forms []string
+	if !strings.HasSuffix(adjective, "ly") {
+		switch {
+		case endsInConsonantY(adjective) && !contains([]string{"shy", "sly", "dry", "coy"}, adjective):
+			forms = append(forms, adjective[:len(adjective)-1]+"ily")
+		case consonantLeEndRegex.MatchString(adjective):
+			forms = append(forms, adjective[:len(adjective)-1]+"y")
+		case strings.HasSuffix(adjective, "ic") && adjective != "public":
+			forms = append(forms, adjective+"ally")
+		case strings.HasSuffix(adjective, "ll"):
+			forms = append(forms, adjective+"y")
+		case strings.HasSuffix(adjective, "ue"):
+			forms = append(forms, adjective[:len(adjective)-1]+"ly")
+		default:
+			forms = append(forms, adjective+"ly")
+		}
+	}
+
+	switch {
+	case endsInConsonantY(adjective) && len(adjective) > 3:
+		forms = append(forms, adjective[:len(adjective)-1]+"iness")
+	case hasAnySuffix(adjective, "ful", "ous", "ive"):
+		forms = append(forms, adjective+"ness")
+	case !hasAnySuffix(adjective, "al", "ic", "ed", "ing", "ent", "ant", "ary"):
+		forms = append(forms, adjective+"ness")
+	}
+
+	syllables := countSyllables(adjective)
+	if syllables == 1 || (syllables == 2 && endsInConsonantY(adjective)) {
+		switch {
+		case strings.HasSuffix(adjective, "e"):
+			forms = append(forms, adjective+"r", adjective+"st")
+		case endsInConsonantY(adjective):
+			stem := adjective[:len(adjective)-1]
+			forms = append(forms, stem+"ier", stem+"iest")
+		case doublesFinalConsonant(adjective):
+			stem := adjective + adjective[len(adjective)-1:]
+			forms = append(forms, stem+"er", stem+"est")
+		default:
+			forms = append(forms, adjective+"er", adjective+"est")
+		}
+	}
+	return forms
+}
+
+// doublesFinalConsonant returns true if the word's final consonant is doubled before a suffix, like 'stop'
+// becoming 'stopped'
+func doublesFinalConsonant(word string) bool {
+	if doublingVerbs[word] {
+		return true
+	}
+	if len(word) < 3 || strings.ContainsAny(word[len(word)-1:], "wxy") || countSyllables(word) != 1 {
+		return false
+	}
+	last, secondLast, thirdLast := word[len(word)-1:], word[len(word)-2:len(word)-1], word[len(word)-3:len(word)-2]
+	// The 'u' in 'qu' is part of the consonant, as in 'quit'
+	isQu := thirdLast == "u" && len(word) >= 4 && word[len(word)-4:len(word)-3] == "q"
+	return !isVowel(last) && isVowel(secondLast) && !isVowel(thirdLast) && !isQu
+}
+
+func countSyllables(word string) int {
+	// A leading 'y' is a consonant, as in 'yes'
+	if strings.HasPrefix(word, "y") {
+		word = word[1:]
+	}
+	return max(1, len(vowelGroupRegex.FindAllString(word, -1)))
+}
+
+func hasTripledLetter(word string) bool {
+	for idx := 2; idx < len(word); idx++ {
+		if word[idx] == word[idx-1] && word[idx] == word[idx-2] {
+			return true
+		}
+	}
+	return false
+}
+
+func endsInConsonantY(word string) bool {
+	return len(word) >= 2 && strings.HasSuffix(word, "y") && !isVowel(word[len(word)-2:len(word)-1])
+}
+
+func isVowel(letter string) bool {
+	return strings.Contains("aeiou", letter)
+}
+
+func isAlpha(word string) bool {
+	return alphaRegex.MatchString(word)
+}
+
+func hasAnySuffix(word string, suffixes ...string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(word, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+func capitalize(word string) string {
+	if word == "" {
+		return word
+	}
+	return strings.ToUpper(word[:1]) + word[1:]
+}
+
+func getGoRootDirpath() (string, error) {
+	output, err := exec.Command("go", "env", "GOROOT").Output()
+	if err != nil {
+		return "", stacktrace.Propagate(err, "failed to find the Go source tree")
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// downloadModule fetches the module (if it isn't already in the module cache) and returns its directory
+func downloadModule(module string) (string, error) {
+	output, err := exec.Command("go", "mod", "download", "-json", module).Output()
+	if err != nil {
+		return "", stacktrace.Propagate(err, "failed to download module '%s'", module)
+	}
+	var download struct {
+		Dir string
+	}
+	if err := json.Unmarshal(output, &download); err != nil {
+		return "", stacktrace.Propagate(err, "failed to parse the download info for module '%s'", module)
+	}
+	return download.Dir, nil
+}